@@ -0,0 +1,678 @@
+// Package apidoc describes the routes registered on a gin engine as an
+// OpenAPI document. The methods, paths and path parameters come from the
+// router itself; what each handler accepts and answers is annotated here,
+// keyed by the handler function, with request and response schemas taken
+// from the binding structs and models the handlers use. A route whose
+// handler has no annotation is listed without a summary, which
+// router/openapi_test.go rejects.
+package apidoc
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"event-analytics/controllers"
+	"event-analytics/handler"
+	"event-analytics/middlewares"
+	"event-analytics/models"
+	"event-analytics/pkg/ical"
+	"event-analytics/pkg/openapi"
+	"event-analytics/pkg/problem"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Security scheme names
+const (
+	sessionAuth = "session"
+	bearerAuth  = "bearer"
+)
+
+const csrfDescription = "Token from the csrf_token cookie set when the page was rendered"
+
+const twoFactorRedirect = "To /user/profile with an error when a role of the user requires two-factor authentication they have not enabled"
+
+// annotation describes the operation of a route served by one handler.
+// path is the gin path of the route, for handlers that serve several.
+type annotation func(op *openapi.Operation, path string)
+
+// annotations maps handler function names to their annotation
+type annotations map[string]annotation
+
+// add annotates every route served by handler, a function or a method
+// expression such as (*controllers.EventController).CreateEvent
+func (a annotations) add(handler interface{}, describe annotation) {
+	a[handlerName(runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name())] = describe
+}
+
+// handlerName drops the suffix Go gives method values, so a route
+// registered with events.CreateEvent finds the annotation of the method
+func handlerName(name string) string {
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// specServer builds the document of an engine's routes on first request,
+// once every route is registered
+type specServer struct {
+	engine *gin.Engine
+	once   sync.Once
+	spec   *openapi.Document
+}
+
+// ServeSpec returns the handler serving the OpenAPI document of r as JSON
+func ServeSpec(r *gin.Engine) gin.HandlerFunc {
+	return (&specServer{engine: r}).serve
+}
+
+func (s *specServer) serve(c *gin.Context) {
+	s.once.Do(func() {
+		s.spec = Build(s.engine.Routes())
+	})
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.spec)
+}
+
+// Build creates the OpenAPI document of routes
+func Build(routes gin.RoutesInfo) *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:       "Event Analytics",
+		Version:     "1.0.0",
+		Description: "Server rendered pages and form posts used by the web UI, and the versioned JSON API under /api/v1.",
+	})
+	d.Tags = []openapi.Tag{
+		{Name: "Auth", Description: "Login, registration and password recovery pages"},
+		{Name: "User", Description: "Dashboard, profile and personal credentials"},
+		{Name: "Events", Description: "Event pages and forms"},
+		{Name: "Calendar", Description: "iCalendar feeds"},
+		{Name: "Admin", Description: "Pages for users with the admin role"},
+		{Name: "API", Description: "Versioned JSON API. Errors are RFC 9457 problem documents. Users whose role requires two-factor authentication get 403 until they enroll."},
+		{Name: "Static", Description: "Static assets and uploaded images"},
+	}
+
+	d.AddSecurityScheme(sessionAuth, &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        "session_token",
+		Description: "Session cookie set by POST /auth/login",
+	})
+	d.AddSecurityScheme(bearerAuth, &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "eat_ personal access token",
+		Description:  "Personal access token created on the profile page. Token scopes: " + strings.Join(models.Scopes, ", ") + ".",
+	})
+
+	d.DefineType(uuid.UUID{}, openapi.Format("uuid"))
+	d.DefineType(gorm.DeletedAt{}, &openapi.Schema{Type: "string", Format: "date-time", Nullable: true})
+
+	a := make(annotations)
+	authRoutes(a)
+	userRoutes(a)
+	eventRoutes(a)
+	calendarRoutes(a)
+	adminRoutes(a)
+	apiRoutes(a, d)
+	liveRoutes(a, d)
+	a.add((*specServer).serve, func(op *openapi.Operation, path string) {
+		op.Summarize("This document").
+			ID("getOpenAPI").Tag("API").
+			Returns(http.StatusOK, openapi.MIMEJSON, "OpenAPI 3 document", &openapi.Schema{Type: "object"})
+	})
+
+	for _, route := range routes {
+		op := d.Route(route.Method, route.Path, "")
+		if strings.HasSuffix(route.Path, "/*filepath") {
+			static(op, route.Path)
+			continue
+		}
+		if describe, ok := a[handlerName(route.Handler)]; ok {
+			describe(op, route.Path)
+			continue
+		}
+		op.Responses["default"] = &openapi.Response{Description: "Not documented, " + route.Handler + " has no annotation in apidoc"}
+	}
+	return d
+}
+
+// page documents a GET that renders a template for a signed in user
+func page(op *openapi.Operation, path, summary, id, tag string) *openapi.Operation {
+	return enrolled(op.Summarize(summary).
+		ID(id).Tag(tag).
+		Secured(sessionAuth).
+		HTML(http.StatusOK, "Rendered page").
+		Redirect("To /auth/login when the session is missing or expired"), path)
+}
+
+// form documents a CSRF protected form post by a signed in user
+func form(op *openapi.Operation, path, summary, id, tag string) *openapi.Operation {
+	return enrolled(op.Summarize(summary).
+		ID(id).Tag(tag).
+		Secured(sessionAuth).
+		FormField("csrf_token", csrfDescription, true).
+		HTML(http.StatusForbidden, "Invalid CSRF token").
+		Redirect("To /auth/login when the session is missing or expired"), path)
+}
+
+// enrolled documents the redirect of middlewares.TwoFactorEnrolled on the
+// routes it guards
+func enrolled(op *openapi.Operation, path string) *openapi.Operation {
+	if middlewares.TwoFactorExempt(path) {
+		return op
+	}
+	return op.Redirect(twoFactorRedirect)
+}
+
+// static documents a route registered with gin's Static, whose handler is
+// gin's own
+func static(op *openapi.Operation, path string) {
+	prefix := strings.TrimSuffix(path, "/*filepath")
+	op.Summarize("Serve a file from "+prefix).
+		Tag("Static").
+		Returns(http.StatusOK, "application/octet-stream", "File contents, content type from the file extension", openapi.Format("binary")).
+		Empty(http.StatusNotFound, "No such file")
+}
+
+func authRoutes(a annotations) {
+	messages := func(op *openapi.Operation) *openapi.Operation {
+		return op.
+			Query("error", "Error code or message to show", openapi.String()).
+			Query("success", "Success code to show", openapi.String())
+	}
+
+	a.add(handler.ShowLoginPage, func(op *openapi.Operation, path string) {
+		id := "showLogin"
+		if path == "/" {
+			id = "showHome"
+		}
+		messages(op.Summarize("Login page").
+			ID(id).Tag("Auth").
+			HTML(http.StatusOK, "Login form").
+			Redirect("To /user/dashboard when already signed in"))
+	})
+
+	a.add((*controllers.AuthController).Login, func(op *openapi.Operation, path string) {
+		op.Summarize("Sign in").
+			ID("login").Tag("Auth").
+			Form(controllers.LoginInput{}).
+			Redirect("To /user/dashboard, setting the session_token cookie").
+			Redirect("To /auth/two-factor, setting the pending_2fa cookie instead, when the user has two-factor authentication enabled").
+			ResponseHeader(http.StatusFound, "Set-Cookie", "session_token or pending_2fa cookie").
+			HTML(http.StatusBadRequest, "Missing fields").
+			HTML(http.StatusUnauthorized, "Unknown user, wrong password or unverified email").
+			HTML(http.StatusTooManyRequests, "Two-factor login locked after too many invalid codes").
+			HTML(http.StatusInternalServerError, "Session could not be created")
+	})
+
+	a.add(handler.ShowTwoFactorPage, func(op *openapi.Operation, path string) {
+		op.Summarize("Two-factor code page").
+			ID("showTwoFactor").Tag("Auth").
+			HTML(http.StatusOK, "Code form").
+			Redirect("To /auth/login without a pending_2fa cookie, or with error=two_factor_expired when the pending login expired")
+	})
+
+	a.add((*controllers.AuthController).TwoFactor, func(op *openapi.Operation, path string) {
+		op.Summarize("Finish signing in with a TOTP or recovery code").
+			ID("twoFactor").Tag("Auth").
+			Form(controllers.TwoFactorInput{}).
+			FormField("csrf_token", csrfDescription, true).
+			Redirect("To /user/dashboard, setting the session_token cookie").
+			Redirect("To /auth/login?error=two_factor_expired when the pending login expired, error=two_factor_failed after five invalid codes, or error=two_factor_locked after ten invalid codes for the user in fifteen minutes").
+			ResponseHeader(http.StatusFound, "Set-Cookie", "session_token cookie").
+			HTML(http.StatusBadRequest, "Missing code").
+			HTML(http.StatusUnauthorized, "Invalid or already used code, with the attempts left").
+			HTML(http.StatusForbidden, "Invalid CSRF token").
+			HTML(http.StatusInternalServerError, "Code could not be checked or session could not be created")
+	})
+
+	a.add(handler.ShowRegistrationPage, func(op *openapi.Operation, path string) {
+		op.Summarize("Registration page").
+			ID("showRegister").Tag("Auth").
+			HTML(http.StatusOK, "Registration form").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add((*controllers.AuthController).Register, func(op *openapi.Operation, path string) {
+		op.Summarize("Create an account").
+			ID("register").Tag("Auth").
+			Form(controllers.RegisterInput{}).
+			HTML(http.StatusOK, "Account created, a verification email was sent").
+			HTML(http.StatusBadRequest, "Invalid fields, or the username or email is taken").
+			HTML(http.StatusInternalServerError, "Account could not be created").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add(controllers.Verify, func(op *openapi.Operation, path string) {
+		op.Summarize("Verify an email address").
+			ID("verifyEmail").Tag("Auth").
+			RequiredQuery("token", "Token from the verification email", openapi.String()).
+			HTML(http.StatusOK, "Email verified").
+			HTML(http.StatusBadRequest, "Missing, unknown, expired or already used token").
+			HTML(http.StatusInternalServerError, "Verification failed").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add(handler.ShowResendVerificationPage, func(op *openapi.Operation, path string) {
+		op.Summarize("Resend verification email page").
+			ID("showResendVerification").Tag("Auth").
+			HTML(http.StatusOK, "Email form").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add((*controllers.AuthController).ResendVerification, func(op *openapi.Operation, path string) {
+		op.Summarize("Send a new email verification link").
+			Describe("Mails a new link to an unverified account, three times an hour at most. The answer does not tell whether the address belongs to an account.").
+			ID("resendVerification").Tag("Auth").
+			Form(controllers.ResendVerificationInput{}).
+			FormField("csrf_token", csrfDescription, true).
+			HTML(http.StatusOK, "Link sent if the address belongs to an unverified account").
+			HTML(http.StatusBadRequest, "Malformed form").
+			HTML(http.StatusTooManyRequests, "Too many links requested for the account").
+			HTML(http.StatusInternalServerError, "Verification link could not be queued").
+			HTML(http.StatusForbidden, "Invalid CSRF token").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add(handler.ShowForgotPasswordPage, func(op *openapi.Operation, path string) {
+		op.Summarize("Forgot password page").
+			ID("showForgotPassword").Tag("Auth").
+			HTML(http.StatusOK, "Email form").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add((*controllers.AuthController).ForgotPassword, func(op *openapi.Operation, path string) {
+		op.Summarize("Send a password reset email").
+			ID("forgotPassword").Tag("Auth").
+			Form(controllers.ForgotPasswordInput{}).
+			FormField("csrf_token", csrfDescription, true).
+			HTML(http.StatusOK, "Reset link sent").
+			HTML(http.StatusBadRequest, "Malformed form or email not found").
+			HTML(http.StatusInternalServerError, "Reset link could not be queued").
+			HTML(http.StatusForbidden, "Invalid CSRF token").
+			Redirect("To /user/dashboard when already signed in")
+	})
+
+	a.add(handler.ShowResetPasswordPage, func(op *openapi.Operation, path string) {
+		op.Summarize("Reset password page").
+			ID("showResetPassword").Tag("Auth").
+			RequiredQuery("token", "Token from the password reset email", openapi.String()).
+			HTML(http.StatusOK, "New password form").
+			Redirect("To /auth/login?error=invalid_token for unknown, expired or used tokens, or to /user/dashboard when already signed in")
+	})
+
+	a.add((*controllers.AuthController).ResetPassword, func(op *openapi.Operation, path string) {
+		op.Summarize("Set a new password").
+			ID("resetPassword").Tag("Auth").
+			Form(controllers.ResetPasswordInput{}).
+			FormField("csrf_token", csrfDescription, true).
+			HTML(http.StatusOK, "Form shown again with the validation error").
+			HTML(http.StatusBadRequest, "Malformed form").
+			HTML(http.StatusForbidden, "Invalid CSRF token").
+			Redirect("To /auth/login?success=password_reset, or to /auth/login?error=invalid_reset_token for an unknown, expired or used token")
+	})
+}
+
+func userRoutes(a annotations) {
+	a.add((*handler.Pages).Dashboard, func(op *openapi.Operation, path string) {
+		page(op, path, "Dashboard with the visible events", "showDashboard", "User").
+			Query("page", "Page of events, 4 per page", openapi.Integer(1, 0)).
+			Query("error", "Error message to show", openapi.String()).
+			Header("HX-Request", "Set by htmx to receive only the event cards fragment")
+	})
+
+	a.add(controllers.Logout, func(op *openapi.Operation, path string) {
+		op.Summarize("Sign out").
+			ID("logout").Tag("User").
+			Secured(sessionAuth).
+			Redirect("To /auth/login, clearing the session_token cookie")
+	})
+
+	a.add(handler.ShowProfilePage, func(op *openapi.Operation, path string) {
+		page(op, path, "Profile page", "showProfile", "User").
+			Query("error", "Error message to show", openapi.String())
+	})
+
+	a.add(controllers.EditProfile, func(op *openapi.Operation, path string) {
+		form(op, path, "Update the profile", "editProfile", "User").
+			Form(controllers.ProfileInput{}).
+			HTML(http.StatusOK, "Profile updated").
+			HTML(http.StatusBadRequest, "Invalid fields").
+			HTML(http.StatusConflict, "Username or email taken").
+			HTML(http.StatusInternalServerError, "Profile could not be saved")
+	})
+
+	a.add(handler.ShowChangePasswordPage, func(op *openapi.Operation, path string) {
+		page(op, path, "Change password page", "showChangePassword", "User")
+	})
+
+	a.add((*controllers.AuthController).ChangePassword, func(op *openapi.Operation, path string) {
+		form(op, path, "Change the password", "changePassword", "User").
+			Form(controllers.ChangePasswordInput{}).
+			HTML(http.StatusOK, "Password changed").
+			HTML(http.StatusBadRequest, "New password too short or equal to the old one").
+			HTML(http.StatusUnauthorized, "Old password is wrong").
+			HTML(http.StatusInternalServerError, "Password could not be changed")
+	})
+
+	a.add(controllers.RegenerateCalendarFeed, func(op *openapi.Operation, path string) {
+		form(op, path, "Create or regenerate the calendar feed URL", "regenerateCalendarFeed", "User").
+			Redirect("To /user/profile, which shows the new URL once")
+	})
+
+	a.add(controllers.RevokeCalendarFeed, func(op *openapi.Operation, path string) {
+		form(op, path, "Revoke the calendar feed URL", "revokeCalendarFeed", "User").
+			Redirect("To /user/profile")
+	})
+
+	a.add(controllers.CreateAPIToken, func(op *openapi.Operation, path string) {
+		form(op, path, "Create a personal access token", "createAPIToken", "User").
+			Form(controllers.APITokenInput{}).
+			Redirect("To /user/profile, which shows the new token once, or with an error")
+	})
+
+	a.add(controllers.RevokeAPIToken, func(op *openapi.Operation, path string) {
+		form(op, path, "Revoke a personal access token", "revokeAPIToken", "User").
+			PathParam("id", "Token ID", openapi.Format("uuid")).
+			Redirect("To /user/profile")
+	})
+
+	a.add(handler.ShowTwoFactorSetupPage, func(op *openapi.Operation, path string) {
+		page(op, path, "Two-factor setup page with the QR code of the new secret", "showTwoFactorSetup", "User").
+			Query("error", "Error message to show", openapi.String()).
+			Redirect("To /user/profile when no enrollment was started")
+	})
+
+	a.add((*controllers.AuthController).EnrollTwoFactor, func(op *openapi.Operation, path string) {
+		form(op, path, "Start enrolling in two-factor authentication", "enrollTwoFactor", "User").
+			Redirect("To /user/two-factor, or to /user/profile with an error when already enabled")
+	})
+
+	a.add((*controllers.AuthController).ConfirmTwoFactor, func(op *openapi.Operation, path string) {
+		form(op, path, "Enable two-factor authentication with a code from the new secret", "confirmTwoFactor", "User").
+			Form(controllers.TwoFactorInput{}).
+			Redirect("To /user/profile, which shows the recovery codes once, or to /user/two-factor with an error")
+	})
+
+	a.add((*controllers.AuthController).DisableTwoFactor, func(op *openapi.Operation, path string) {
+		form(op, path, "Disable two-factor authentication", "disableTwoFactor", "User").
+			Form(controllers.TwoFactorInput{}).
+			Redirect("To /user/profile, with an error for an invalid code or when a role of the user requires two-factor authentication")
+	})
+
+	a.add((*controllers.AuthController).RegenerateRecoveryCodes, func(op *openapi.Operation, path string) {
+		form(op, path, "Replace the recovery codes", "regenerateRecoveryCodes", "User").
+			Form(controllers.TwoFactorInput{}).
+			Redirect("To /user/profile, which shows the new codes once, or with an error for an invalid code")
+	})
+}
+
+func adminRoutes(a annotations) {
+	a.add((*handler.Pages).ShowOutboxPage, func(op *openapi.Operation, path string) {
+		page(op, path, "Email outbox", "showOutbox", "Admin").
+			Query("status", "Only messages with this status", openapi.Enum(models.OutboxStatuses...)).
+			Query("error", "Error message to show", openapi.String()).
+			Redirect("To /user/dashboard?error=Permission+denied for users without the admin role, or to /admin/outbox for an unknown status")
+	})
+
+	a.add((*controllers.OutboxController).RequeueOutboxMessage, func(op *openapi.Operation, path string) {
+		form(op, path, "Requeue an unsent email", "requeueOutboxMessage", "Admin").
+			PathParam("id", "Outbox message ID", openapi.Integer(1, 0)).
+			Redirect("To /admin/outbox, with an error when the message is unknown, already sent or dead without its body, or to /user/dashboard without the admin role")
+	})
+
+	a.add(handler.ShowTwoFactorAdminPage, func(op *openapi.Operation, path string) {
+		page(op, path, "Roles that require two-factor authentication", "showTwoFactorRoles", "Admin").
+			Query("error", "Error message to show", openapi.String()).
+			Redirect("To /user/dashboard?error=Permission+denied for users without the admin role")
+	})
+
+	a.add(controllers.UpdateTwoFactorRoles, func(op *openapi.Operation, path string) {
+		form(op, path, "Set the roles that require two-factor authentication", "updateTwoFactorRoles", "Admin").
+			Redirect("To /admin/two-factor, or to /user/dashboard without the admin role")
+		for _, role := range services.TwoFactorRoles {
+			op.FormField("require_"+role, "\"on\" to require two-factor authentication for the "+role+" role, absent otherwise", false)
+		}
+	})
+}
+
+func eventRoutes(a annotations) {
+	eventID := func(op *openapi.Operation) *openapi.Operation {
+		return op.PathParam("id", "Event ID", openapi.Format("uuid"))
+	}
+	occurrence := func(op *openapi.Operation) *openapi.Operation {
+		return eventID(op).PathParam("start", "Original start of the occurrence in Unix seconds", openapi.Integer(0, 0))
+	}
+
+	a.add(handler.ShowCreateEventPage, func(op *openapi.Operation, path string) {
+		page(op, path, "New event form", "showCreateEvent", "Events").
+			Query("error", "Error message to show, the form is refilled from the form_data cookie", openapi.String())
+	})
+
+	a.add((*controllers.EventController).CreateEvent, func(op *openapi.Operation, path string) {
+		form(op, path, "Create an event", "createEvent", "Events").
+			Form(controllers.EventInput{}).
+			File("image", "Optional event image", false).
+			Redirect("To /user/dashboard, or back to /events/new with an error")
+	})
+
+	a.add(handler.ShowImportPage, func(op *openapi.Operation, path string) {
+		page(op, path, "Import events page", "showImportEvents", "Events").
+			Query("error", "Error message to show", openapi.String())
+	})
+
+	a.add((*controllers.EventController).PreviewImport, func(op *openapi.Operation, path string) {
+		form(op, path, "Validate an .ics or .csv file", "previewImport", "Events").
+			File("file", "iCalendar or CSV file, at most 5MB", true).
+			HTML(http.StatusOK, "Per-row preview of the file").
+			Redirect("Back to /events/import with an error")
+	})
+
+	a.add((*controllers.EventController).CommitImport, func(op *openapi.Operation, path string) {
+		form(op, path, "Create the previewed events", "commitImport", "Events").
+			Form(controllers.ImportCommitInput{}).
+			Redirect("To /user/dashboard, or back to /events/import with an error")
+	})
+
+	a.add((*handler.Pages).ShowEventDetails, func(op *openapi.Operation, path string) {
+		page(op, path, "Event details", "showEvent", "Events").
+			PathParam("id", "Event ID. With an .ics suffix the event is downloaded as iCalendar instead", openapi.String()).
+			Query("error", "Error message to show", openapi.String()).
+			Returns(http.StatusOK, ical.ContentType, "Event as iCalendar, for /events/{id}.ics", openapi.String()).
+			HTML(http.StatusNotFound, "Event not found")
+	})
+
+	a.add((*handler.Pages).ShowEventCard, func(op *openapi.Operation, path string) {
+		eventID(op.Summarize("Dashboard card of an event").
+			ID("showEventCard").Tag("Events").
+			Describe("HTML fragment the dashboard fetches when it is told over the WebSocket that an event changed.").
+			Secured(sessionAuth).
+			HTML(http.StatusOK, "Card fragment").
+			Redirect("To /auth/login when the session is missing or expired").
+			Empty(http.StatusNotFound, "Event missing or not listed on the user's dashboard"))
+	})
+
+	a.add((*handler.Pages).ShowEditEventPage, func(op *openapi.Operation, path string) {
+		eventID(page(op, path, "Edit event form", "showEditEvent", "Events")).
+			Query("error", "Error message to show", openapi.String()).
+			Redirect("To /user/dashboard when the event is missing or not editable")
+	})
+
+	a.add((*controllers.EventController).UpdateEvent, func(op *openapi.Operation, path string) {
+		eventID(form(op, path, "Update an event", "updateEvent", "Events")).
+			Form(controllers.EventInput{}).
+			File("image", "Optional replacement image", false).
+			Redirect("To /user/dashboard, or back to /events/edit/{id} with an error")
+	})
+
+	a.add((*controllers.EventController).DeleteEvent, func(op *openapi.Operation, path string) {
+		eventID(form(op, path, "Delete an event", "deleteEvent", "Events")).
+			Redirect("To /user/dashboard")
+	})
+
+	a.add((*handler.Pages).ShowEventAnalytics, func(op *openapi.Operation, path string) {
+		eventID(page(op, path, "Event analytics page", "showEventAnalytics", "Events")).
+			Query("days", "Days of daily traffic to show", openapi.Integer(1, 365)).
+			Redirect("To /user/dashboard when the event is missing or not owned by the user")
+	})
+
+	errorJSON := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{"error": openapi.String()}}
+	a.add((*controllers.EventController).TrackInteraction, func(op *openapi.Operation, path string) {
+		eventID(form(op, path, "Record a custom interaction", "trackInteraction", "Events")).
+			Form(controllers.InteractionInput{}).
+			Empty(http.StatusNoContent, "Interaction recorded").
+			Returns(http.StatusBadRequest, openapi.MIMEJSON, "Missing interaction name", errorJSON).
+			Returns(http.StatusNotFound, openapi.MIMEJSON, "Event not found", errorJSON)
+	})
+
+	a.add((*controllers.EventController).RSVP, func(op *openapi.Operation, path string) {
+		eventID(form(op, path, "RSVP to an event", "rsvp", "Events")).
+			Form(controllers.RSVPInput{}).
+			Redirect("To /events/{id}, with an error when the status is invalid or the event is closed")
+	})
+
+	a.add((*controllers.EventController).CancelRSVP, func(op *openapi.Operation, path string) {
+		eventID(form(op, path, "Cancel an RSVP", "cancelRSVP", "Events")).
+			Redirect("To /events/{id}")
+	})
+
+	a.add((*handler.Pages).ShowEditOccurrencePage, func(op *openapi.Operation, path string) {
+		occurrence(page(op, path, "Edit occurrence form", "showEditOccurrence", "Events")).
+			Query("error", "Error message to show", openapi.String()).
+			Redirect("To the event page when the occurrence does not exist")
+	})
+
+	a.add((*controllers.EventController).UpdateOccurrence, func(op *openapi.Operation, path string) {
+		occurrence(form(op, path, "Change one occurrence, or it and all following", "updateOccurrence", "Events")).
+			Form(controllers.OccurrenceInput{}).
+			Redirect("To /events/{id}, or back to the edit form with an error")
+	})
+
+	a.add((*controllers.EventController).CancelOccurrence, func(op *openapi.Operation, path string) {
+		occurrence(form(op, path, "Cancel one occurrence, or it and all following", "cancelOccurrence", "Events")).
+			Form(controllers.CancelOccurrenceInput{}).
+			Redirect("To /events/{id}")
+	})
+}
+
+func calendarRoutes(a annotations) {
+	a.add(handler.CalendarFeed, func(op *openapi.Operation, path string) {
+		op.Summarize("Subscribe to a user's calendar feed").
+			ID("calendarFeed").Tag("Calendar").
+			PathParam("token", "Secret feed token, optionally with an .ics suffix", openapi.String()).
+			Returns(http.StatusOK, ical.ContentType, "Published events and the user's drafts", openapi.String()).
+			Returns(http.StatusNotFound, "text/plain", "Unknown or revoked token", openapi.String())
+	})
+}
+
+func apiRoutes(a annotations, d *openapi.Document) {
+	problems := func(op *openapi.Operation, statuses ...int) *openapi.Operation {
+		for _, status := range append([]int{http.StatusUnauthorized}, statuses...) {
+			op.Returns(status, problem.ContentType, http.StatusText(status), d.Schema(problem.Details{}))
+		}
+		return op
+	}
+	api := func(op *openapi.Operation, summary, id, scope string) *openapi.Operation {
+		return op.Summarize(summary).
+			ID(id).Tag("API").
+			Secured(bearerAuth, scope).
+			Secured(sessionAuth)
+	}
+	eventID := func(op *openapi.Operation) *openapi.Operation {
+		return op.PathParam("id", "Event ID", openapi.Format("uuid"))
+	}
+
+	a.add((*controllers.EventController).APIListEvents, func(op *openapi.Operation, path string) {
+		problems(api(op, "List events", "apiListEvents", models.ScopeEventsRead).
+			Describe("Published events and the user's own drafts, every event for admins, ordered by start time.").
+			Query("status", "Comma separated statuses: draft, published, expired", openapi.String()).
+			Query("from", "Only events that end at or after this RFC 3339 time or YYYY-MM-DD date", openapi.String()).
+			Query("to", "Only events that start before this RFC 3339 time or YYYY-MM-DD date", openapi.String()).
+			Query("page", "Page number", openapi.Integer(1, 0)).
+			Query("per_page", "Events per page, 20 by default", openapi.Integer(1, 100)).
+			JSON(http.StatusOK, "A page of events", controllers.APIEventList{}),
+			http.StatusBadRequest, http.StatusForbidden)
+	})
+
+	a.add((*controllers.EventController).APICreateEvent, func(op *openapi.Operation, path string) {
+		problems(api(op, "Create an event", "apiCreateEvent", models.ScopeEventsWrite).
+			JSONBody(controllers.APIEventInput{}).
+			JSON(http.StatusCreated, "Event created", controllers.APIEventResponse{}).
+			ResponseHeader(http.StatusCreated, "Location", "URL of the new event"),
+			http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	})
+
+	a.add((*controllers.EventController).APIGetEvent, func(op *openapi.Operation, path string) {
+		problems(eventID(api(op, "Get an event", "apiGetEvent", models.ScopeEventsRead)).
+			JSON(http.StatusOK, "The event", controllers.APIEventResponse{}),
+			http.StatusForbidden, http.StatusNotFound)
+	})
+
+	a.add((*controllers.EventController).APIUpdateEvent, func(op *openapi.Operation, path string) {
+		problems(eventID(api(op, "Replace an event", "apiUpdateEvent", models.ScopeEventsWrite)).
+			JSONBody(controllers.APIEventInput{}).
+			JSON(http.StatusOK, "Event updated", controllers.APIEventResponse{}),
+			http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	})
+
+	a.add((*controllers.EventController).APIDeleteEvent, func(op *openapi.Operation, path string) {
+		problems(eventID(api(op, "Delete an event", "apiDeleteEvent", models.ScopeEventsWrite)).
+			Empty(http.StatusNoContent, "Event deleted"),
+			http.StatusForbidden, http.StatusNotFound)
+	})
+
+	a.add((*controllers.EventController).APIEventAnalytics, func(op *openapi.Operation, path string) {
+		problems(eventID(api(op, "Get an event's analytics", "apiGetEventAnalytics", models.ScopeAnalyticsRead)).
+			Query("days", "Days of daily traffic, 30 by default", openapi.Integer(1, 365)).
+			JSON(http.StatusOK, "Analytics report", controllers.APIAnalyticsResponse{}),
+			http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)
+	})
+
+	a.add((*controllers.EventController).APITrackInteraction, func(op *openapi.Operation, path string) {
+		problems(eventID(api(op, "Record a custom interaction", "apiTrackEventInteraction", models.ScopeAnalyticsWrite)).
+			JSONBody(controllers.InteractionInput{}).
+			Empty(http.StatusNoContent, "Interaction recorded"),
+			http.StatusForbidden, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	})
+}
+
+func liveRoutes(a annotations, d *openapi.Document) {
+	// The streams sit behind the API's authentication, whose errors are
+	// problem documents
+	problems := func(op *openapi.Operation) *openapi.Operation {
+		return op.Returns(http.StatusUnauthorized, problem.ContentType, "No valid session or API token", d.Schema(problem.Details{})).
+			Returns(http.StatusForbidden, problem.ContentType, "Two-factor authentication required by the user's role and not enabled, or an API token without the events:read scope", d.Schema(problem.Details{}))
+	}
+
+	a.add((*handler.Pages).WebSocketHandler, func(op *openapi.Operation, path string) {
+		problems(op).Summarize("Open a live update WebSocket").
+			ID("openWebSocket").Tag("Events").
+			Describe(`Send {"action": "subscribe", "topic": "..."} for dashboard, event:<id> or user:<id>. `+
+				`Updates arrive as {"topic", "type", "data"} objects. Only same-origin pages may connect.`).
+			Secured(sessionAuth).
+			Secured(bearerAuth, models.ScopeEventsRead).
+			Empty(http.StatusSwitchingProtocols, "Connection upgraded to a WebSocket").
+			Empty(http.StatusForbidden, "Cross-origin request")
+	})
+
+	a.add((*handler.Pages).StreamEvents, func(op *openapi.Operation, path string) {
+		problems(op).Summarize("Stream live updates as Server-Sent Events").
+			ID("streamEvents").Tag("Events").
+			Describe(`Fallback for networks that block WebSockets. Streams the same {"id", "topic", "type", "data"} `+
+				`messages as /ws for every topic given. A reconnecting client sends the last id it saw as `+
+				`Last-Event-ID and first receives what it missed; a "reset" event means the gap was too long to replay.`).
+			Secured(sessionAuth).
+			Secured(bearerAuth, models.ScopeEventsRead).
+			RequiredQuery("topic", "Topic to follow, repeat for several: dashboard, event:<id> or user:<id>",
+				&openapi.Schema{Type: "array", Items: openapi.String()}).
+			Query("last_event_id", "Resume after this id when the Last-Event-ID header cannot be set", openapi.String()).
+			Header("Last-Event-ID", "Id of the last event received, sent by browsers when they reconnect").
+			Returns(http.StatusOK, "text/event-stream", "Event stream", openapi.String()).
+			Returns(http.StatusBadRequest, "text/plain", "Missing, unknown or too many topics", openapi.String()).
+			Returns(http.StatusForbidden, "text/plain", "A topic may not be followed", openapi.String())
+	})
+}
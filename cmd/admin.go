@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/services"
+	"event-analytics/utils"
+)
+
+// runCreateAdmin gives an existing user the admin role
+func runCreateAdmin(args []string) {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: event-analytics create-admin <email>")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	email := flags.Arg(0)
+
+	config.LoadSettings()
+	config.InitDB()
+	utils.InitializeRoles()
+
+	user, granted, err := services.GrantRole(email, models.RoleAdmin)
+	if errors.Is(err, services.ErrUserNotFound) {
+		log.Fatalf("No user is registered with %s", email)
+	} else if err != nil {
+		log.Fatalf("Failed to grant admin role: %v", err)
+	}
+	if !granted {
+		log.Printf("%s (%s) is already an admin", user.Username, user.Email)
+		return
+	}
+	log.Printf("%s (%s) is now an admin", user.Username, user.Email)
+}
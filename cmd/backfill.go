@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/services"
+)
+
+// parseBackfillRange turns the inclusive YYYY-MM-DD dates passed on the
+// command line into a half-open [from, to) range. An empty end date means today.
+func parseBackfillRange(fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	const dateFormat = "2006-01-02"
+
+	from, err := time.ParseInLocation(dateFormat, fromStr, now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: %w", fromStr, err)
+	}
+
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if toStr != "" {
+		to, err = time.ParseInLocation(dateFormat, toStr, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: %w", toStr, err)
+		}
+	}
+	to = to.AddDate(0, 0, 1)
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("end date must not be before start date")
+	}
+	return from, to, nil
+}
+
+// runBackfill recomputes the analytics rollups for a date range
+func runBackfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromStr := flags.String("from", "", "first date (YYYY-MM-DD) to recompute, required")
+	toStr := flags.String("to", "", "last date (YYYY-MM-DD) to recompute; defaults to today")
+	flags.Parse(args)
+	if *fromStr == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	from, to, err := parseBackfillRange(*fromStr, *toStr, time.Now())
+	if err != nil {
+		log.Fatalf("Invalid backfill range: %v", err)
+	}
+
+	config.LoadSettings()
+	config.InitDB()
+
+	log.Printf("Backfilling analytics rollups from %s to %s", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+	err = services.BackfillRollups(from, to, func(day time.Time, rows int64) {
+		log.Printf("Backfilled %s: %d rollup rows", day.Format("2006-01-02"), rows)
+	})
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+	log.Println("Backfill complete")
+}
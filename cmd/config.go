@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"event-analytics/config"
+)
+
+// runConfig prints the effective configuration with secrets redacted, then
+// any problems with it
+func runConfig(args []string) {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: event-analytics config print")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || flags.Arg(0) != "print" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config.LoadEnv()
+	cfg, loadErr := config.Load(os.LookupEnv)
+
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		log.Fatalf("Failed to render configuration: %v", err)
+	}
+	os.Stdout.Write(out)
+
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "\nInvalid configuration:\n%v\n", loadErr)
+		os.Exit(1)
+	}
+}
@@ -1,149 +1,66 @@
+// Command event-analytics runs the web server and its maintenance tasks.
+//
+// Usage:
+//
+//	event-analytics [command] [arguments]
+//
+// Without a command it serves, as "serve" does.
 package main
 
 import (
-	"event-analytics/config"
-	"event-analytics/controllers"
-	"event-analytics/cron"
-	"event-analytics/handler"
-	"event-analytics/middlewares"
-	"event-analytics/pkg/csrf"
-	"event-analytics/pkg/ratelimit"
-	"event-analytics/utils"
-	"context"
 	"fmt"
-	"html/template"
-	"log"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
-// Format functions for templates
-func formatAsDate(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	year, month, day := t.Date()
-	return fmt.Sprintf("%d/%02d/%02d", year, month, day)
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string)
 }
 
-func formatDatetime(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02T15:04")
+var commands = []command{
+	{name: "serve", summary: "run the web server and cron jobs (default)", run: runServe},
+	{name: "migrate", args: "up|down|status", summary: "apply, revert or list schema migrations", run: runMigrate},
+	{name: "seed", summary: "create demo users and events", run: runSeed},
+	{name: "create-admin", args: "<email>", summary: "give a registered user the admin role", run: runCreateAdmin},
+	{name: "config", args: "print", summary: "show the effective configuration, secrets redacted", run: runConfig},
+	{name: "backfill", args: "-from YYYY-MM-DD [-to YYYY-MM-DD]", summary: "recompute analytics rollups for a date range", run: runBackfill},
 }
 
-func formatForDisplay(t time.Time) string {
-	if t.IsZero() {
-		return ""
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: event-analytics [command] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(os.Stderr, "  %-14s   %s %s\n", "", cmd.name, cmd.args)
+		}
 	}
-	return t.Format("Jan 2, 2006 3:04 PM")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "event-analytics <command> -h" for a command's flags.`)
 }
 
 func main() {
-	// Initialize database and Redis
-	config.Init()
-	utils.InitializeRoles()
-
-	r := gin.Default()
-
-	// Set template functions
-	r.SetFuncMap(template.FuncMap{
-		"formatDate":     formatAsDate,
-		"formatDatetime": formatDatetime,
-		"formatDisplay":  formatForDisplay,
-	})
-
-	// Start the cron jobs
-	go cron.StartCronJobs()
-
-	// Load all templates
-	r.LoadHTMLGlob("templates/*")
-
-	// Serve static files
-	r.Static("/static", "./static")
-	r.Static("/uploads", "./uploads")
-
-	// Use middleware
-	r.Use(middlewares.Recovery())
-	r.Use(middlewares.Logger())
-	r.Use(middlewares.ErrorHandler())
-	r.Use(ratelimit.Middleware(config.RedisClient, 100, time.Minute))
-	r.Use(middlewares.UserMiddleware())
-	r.Use(middlewares.FlashMiddleware())
-	r.Use(csrf.Middleware())
-
-	// Routes
-	r.GET("/", middlewares.PreventAuthenticatedAccess(), handler.ShowLoginPage)
-
-	userRoutes := r.Group("/auth")
-	userRoutes.Use(middlewares.PreventAuthenticatedAccess())
-	{
-		userRoutes.GET("/login", handler.ShowLoginPage)
-		userRoutes.POST("/login", controllers.Login)
-		userRoutes.GET("/register", handler.ShowRegistrationPage)
-		userRoutes.POST("/register", controllers.Register)
-		userRoutes.GET("/verify", controllers.Verify)
-		userRoutes.GET("/forgot-password", handler.ShowForgotPasswordPage)
-		userRoutes.POST("/forgot-password", controllers.ForgotPassword)
-		userRoutes.GET("/reset-password", handler.ShowResetPasswordPage)
-		userRoutes.POST("/reset-password", controllers.ResetPassword)
-	}
-
-	protected := r.Group("/user")
-	protected.Use(middlewares.AuthRequired())
-	{
-		protected.GET("/dashboard", handler.Dashboard)
-		protected.POST("/logout", controllers.Logout)
-		protected.GET("/profile", handler.ShowProfilePage)
-		protected.POST("/profile", controllers.EditProfile)
-		protected.GET("/change-password", handler.ShowChangePasswordPage)
-		protected.POST("/change-password", controllers.ChangePassword)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
 	}
 
-	protected_event := r.Group("/events")
-	protected_event.Use(middlewares.AuthRequired())
-	{
-		protected_event.GET("/new", handler.ShowCreateEventPage)
-		protected_event.POST("/create", controllers.CreateEvent)
-		protected_event.GET("/:id", handler.ShowEventDetails)
-		protected_event.GET("/edit/:id", handler.ShowEditEventPage)
-		protected_event.POST("/update/:id", controllers.UpdateEvent)
-		protected_event.POST("/delete/:id", controllers.DeleteEvent)
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
 	}
-
-	r.GET("/ws", handler.WebSocketHandler)
-
-	// Start the WebSocket hub
-	go handler.Hub.Run()
-
-	// Graceful shutdown
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
-	}
-
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(args)
+			return
 		}
-	}()
-
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
 	}
 
-	log.Println("Server exited")
-}
\ No newline at end of file
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
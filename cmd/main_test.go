@@ -5,85 +5,57 @@ import (
 	"time"
 )
 
-func TestFormatAsDate(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    time.Time
-		expected string
-	}{
-		{
-			name:     "zero time",
-			input:    time.Time{},
-			expected: "",
-		},
-		{
-			name:     "valid date",
-			input:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
-			expected: "2024/01/15",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatAsDate(tt.input)
-			if result != tt.expected {
-				t.Errorf("formatAsDate() = %v, want %v", result, tt.expected)
-			}
-		})
-	}
-}
+func TestParseBackfillRange(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
 
-func TestFormatDatetime(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    time.Time
-		expected string
+		name      string
+		from      string
+		to        string
+		wantFrom  time.Time
+		wantTo    time.Time
+		wantError bool
 	}{
 		{
-			name:     "zero time",
-			input:    time.Time{},
-			expected: "",
+			name:     "explicit range",
+			from:     "2024-03-01",
+			to:       "2024-03-05",
+			wantFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC),
 		},
 		{
-			name:     "valid datetime",
-			input:    time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC),
-			expected: "2024-01-15T14:30",
+			name:     "defaults to today",
+			from:     "2024-03-09",
+			wantFrom: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatDatetime(tt.input)
-			if result != tt.expected {
-				t.Errorf("formatDatetime() = %v, want %v", result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestFormatForDisplay(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    time.Time
-		expected string
-	}{
 		{
-			name:     "zero time",
-			input:    time.Time{},
-			expected: "",
+			name:      "invalid start",
+			from:      "03/01/2024",
+			wantError: true,
 		},
 		{
-			name:     "valid display time",
-			input:    time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC),
-			expected: "Jan 15, 2024 2:30 PM",
+			name:      "end before start",
+			from:      "2024-03-05",
+			to:        "2024-03-01",
+			wantError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatForDisplay(tt.input)
-			if result != tt.expected {
-				t.Errorf("formatForDisplay() = %v, want %v", result, tt.expected)
+			from, to, err := parseBackfillRange(tt.from, tt.to, now)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("parseBackfillRange() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBackfillRange() unexpected error: %v", err)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("parseBackfillRange() = %v, %v, want %v, %v", from, to, tt.wantFrom, tt.wantTo)
 			}
 		})
 	}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/pkg/migrate"
+)
+
+// runMigrate applies, reverts or lists schema migrations. A dry run prints
+// the SQL instead of executing it.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations down reverts")
+	dryRun := flags.Bool("dry-run", false, "print the SQL up or down would run without executing it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: event-analytics migrate up|down|status [flags]")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flags.Parse(args)
+		flags.Usage()
+		os.Exit(2)
+	}
+	command := args[0]
+	flags.Parse(args[1:])
+
+	config.LoadSettings()
+	config.ConnectDB()
+
+	migrator, err := config.NewMigrator()
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator.DryRun = *dryRun
+	ctx := context.Background()
+
+	var ran []migrate.Migration
+	switch command {
+	case "up":
+		ran, err = migrator.Up(ctx)
+	case "down":
+		ran, err = migrator.Down(ctx, *steps)
+	case "status":
+		printMigrationStatus(ctx, migrator)
+		return
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	for _, migration := range ran {
+		if !*dryRun {
+			log.Printf("Migrated %s %s", command, migration)
+			continue
+		}
+		sql := migration.Up
+		if command == "down" {
+			sql = migration.Down
+		}
+		fmt.Printf("-- %s %s\n%s\n", migration, command, sql)
+	}
+	if len(ran) == 0 {
+		log.Println("No migrations to run")
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Modified {
+			state += " (file changed since)"
+		}
+		if status.Missing {
+			state += " (file missing)"
+		}
+		fmt.Printf("%s\t%s\n", status.Migration, state)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/services"
+	"event-analytics/utils"
+)
+
+// runSeed fills the database with demo users and events. It only runs
+// with -demo, so it is never run against a real database by accident.
+func runSeed(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	demo := flags.Bool("demo", false, "confirm that this database is for development and may hold demo accounts")
+	flags.Parse(args)
+	if !*demo {
+		fmt.Fprintln(flags.Output(), "seed creates demo accounts and only runs with -demo, never use it on a production database")
+		os.Exit(2)
+	}
+
+	config.LoadSettings()
+	config.InitDB()
+	utils.InitializeRoles()
+
+	result, err := services.SeedDemo(time.Now())
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	log.Printf("Seeded %d users and %d events", len(result.Created), result.Events)
+	for _, user := range result.Created {
+		log.Printf("Demo login: %s / %s", user.Email, result.Password)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"event-analytics/config"
+	"event-analytics/cron"
+	"event-analytics/middlewares"
+	"event-analytics/pkg/csrf"
+	"event-analytics/pkg/ratelimit"
+	"event-analytics/repository"
+	"event-analytics/router"
+	"event-analytics/utils"
+)
+
+// runServe starts the cron jobs and the web server and blocks until
+// SIGINT or SIGTERM
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	// Initialize database and Redis
+	config.Init()
+	utils.InitializeRoles()
+	repos := repository.New(config.DB)
+
+	r := router.New("templates/*")
+
+	// Start the cron jobs
+	go cron.StartCronJobs()
+
+	// Use middleware
+	r.Use(middlewares.Recovery())
+	r.Use(middlewares.Logger())
+	r.Use(middlewares.ErrorHandler())
+	r.Use(ratelimit.Middleware(config.RedisClient, config.Settings.RateLimit.Requests, config.Settings.RateLimit.Window))
+	r.Use(csrf.Middleware())
+
+	// Routes, see the router package
+	router.Register(r, repos)
+
+	// Graceful shutdown
+	srv := &http.Server{
+		Addr:    config.Settings.Server.Addr,
+		Handler: r,
+	}
+	// WebSocket and SSE streams never finish on their own; ending them
+	// lets Shutdown return once the other requests are done
+	srv.RegisterOnShutdown(config.Hub.Close)
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("listen: %s\n", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Settings.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	// Flush buffered analytics before exiting
+	config.Tracker.Close()
+	config.HubBridge.Close()
+	config.Outbox.Close()
+
+	log.Println("Server exited")
+}
@@ -1,105 +1,210 @@
 package config
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"log"
-	"os"
-	"strings"
 	"time"
 
+	"event-analytics/migrations"
 	"event-analytics/models"
+	"event-analytics/pkg/dialect"
+	"event-analytics/pkg/mailer"
+	"event-analytics/pkg/migrate"
+	"event-analytics/pkg/outbox"
+	"event-analytics/pkg/ratelimit"
+	"event-analytics/pkg/realtime"
 	"event-analytics/pkg/session"
+	"event-analytics/pkg/tracking"
+	"event-analytics/pkg/uniques"
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
+
+// Dialect is the backend of DB, for the SQL that differs between them
+var Dialect dialect.Dialect
+
 var RedisClient *redis.Client
-var SessionStore *session.Store
+var SessionStore session.Manager
+var Tracker *tracking.Tracker
+var UniqueVisitors *uniques.Counter
+
+// TwoFactorPending holds logins waiting for a second factor, five minutes
+// and five codes at most
+var TwoFactorPending *session.PendingStore
+
+// TwoFactorFailures counts wrong codes per user across logins and locks
+// the second step after ten in fifteen minutes
+var TwoFactorFailures *ratelimit.Limiter
+
+// Hub fans out live updates to WebSocket subscribers, at most 50 topics each
+var Hub = realtime.NewHub(50)
+
+// HubBridge relays Hub messages to the other instances through Redis
+var HubBridge *realtime.Bridge
+
+// Mailer sends email, to the log until InitMailer runs
+var Mailer mailer.Mailer = mailer.NewLog(log.Default())
 
-// Initialize the database connection and run migrations
+// Outbox sends the queued email through Mailer, see InitOutbox
+var Outbox *outbox.Dispatcher
+
+// VerificationResends allows three new verification links per account an
+// hour
+var VerificationResends *ratelimit.Limiter
+
+// Initialize the database connection and apply pending migrations
 func InitDB() {
-	log.Printf("DATABASE_URL: %s", os.Getenv("DATABASE_URL"))
-	dsn := os.Getenv("DATABASE_URL")
+	ConnectDB()
+	Migrate()
+}
+
+// Connect to the database, creating it when it does not exist yet. The
+// DSN scheme picks the dialect: sqlite: and file: DSNs open an SQLite file,
+// anything else PostgreSQL.
+func ConnectDB() {
+	dsn := Settings.Database.URL
+	log.Printf("DATABASE_URL: %s", redactDSN(dsn))
 	if dsn == "" {
 		log.Fatal("DATABASE_URL environment variable not set")
 	}
 
-	// Extract database name from DSN
-	var dbName string
-	if strings.Contains(dsn, "database=") {
-		parts := strings.Split(dsn, "database=")
-		if len(parts) > 1 {
-			dbName = strings.Split(parts[1], " ")[0]
-		}
-	} else if strings.Contains(dsn, "/") {
-		parts := strings.Split(dsn, "/")
-		if len(parts) > 0 {
-			dbName = strings.Split(parts[len(parts)-1], "?")[0]
-		}
-	}
-
-	// Try to connect, if database doesn't exist, create it
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil && strings.Contains(err.Error(), "does not exist") {
-		// Connect to postgres database to create the target database
-		adminDSN := strings.Replace(dsn, dbName, "postgres", 1)
-		sqlDB, err := sql.Open("postgres", adminDSN)
-		if err != nil {
-			log.Fatalf("Failed to connect to postgres: %v", err)
-		}
-		defer sqlDB.Close()
-
-		_, err = sqlDB.Exec(fmt.Sprintf("CREATE DATABASE %s", dbName))
-		if err != nil {
-			log.Fatalf("Failed to create database: %v", err)
-		}
-		log.Printf("Database %s created successfully", dbName)
-
-		// Now connect to the newly created database
-		DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-	} else if err != nil {
+	Dialect, DB, err = dialect.Open(dsn, &gorm.Config{})
+	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+}
 
-	err = DB.AutoMigrate(
-		&models.User{}, 
-		&models.Event{}, 
-		&models.VerificationToken{}, 
-		&models.PasswordReset{},
-		&models.Role{},
-		&models.UserRole{},
-		&models.PasswordHistory{},
-		&models.UserLog{},
-	)
+// NewMigrator returns a migrator for the SQL files of the dialect in the
+// migrations package
+func NewMigrator() (*migrate.Migrator, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	files, err := migrations.For(Dialect.Name())
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, Dialect, files)
+}
+
+// Apply pending migrations. Replicas booting together wait for each other
+// on the migration lock.
+func Migrate() {
+	migrator, err := NewMigrator()
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	applied, err := migrator.Up(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
+	for _, migration := range applied {
+		log.Printf("Applied migration %s", migration)
+	}
+}
+
+// Initialize the buffered analytics writer
+func InitTracker() {
+	Tracker = tracking.NewTracker(DB, 10000, 100, 5*time.Second)
+	if UniqueVisitors != nil {
+		// Feed every written batch into the HyperLogLog visitor sketches
+		Tracker.OnFlush(func(batch []models.AnalyticsEvent) {
+			visits := make([]uniques.Visit, 0, len(batch))
+			for _, record := range batch {
+				visits = append(visits, uniques.Visit{
+					EventID:   record.EventID.String(),
+					VisitorID: record.VisitorID,
+					At:        record.CreatedAt,
+				})
+			}
+			if err := UniqueVisitors.Add(context.Background(), visits...); err != nil {
+				log.Printf("Failed to record unique visitors: %v", err)
+			}
+		})
+	}
+	Tracker.Start()
 }
 
 // Initialize Redis
 func InitRedis() {
 	RedisClient = redis.NewClient(&redis.Options{
-		Addr: os.Getenv("REDIS_ADDR"),
+		Addr:     Settings.Redis.Addr,
+		Password: Settings.Redis.Password,
+		DB:       Settings.Redis.DB,
 	})
 	SessionStore = session.NewStore(RedisClient, 24*time.Hour)
+	TwoFactorPending = session.NewPendingStore(RedisClient, 5*time.Minute, 5)
+	TwoFactorFailures = ratelimit.NewLimiter(RedisClient, "ratelimit:two-factor:", 10, 15*time.Minute)
+	UniqueVisitors = uniques.NewCounter(RedisClient)
+	VerificationResends = ratelimit.NewLimiter(RedisClient, "ratelimit:verification:", 3, time.Hour)
 }
 
-func Init() {
+// Start relaying live updates between instances and keep the last
+// thousand messages, up to ten minutes old, for clients that reconnect
+func InitRealtime() {
+	Hub.SetReplay(realtime.NewRedisReplay(RedisClient, 1000, 10*time.Minute))
+	HubBridge = realtime.NewRedisBridge(RedisClient, Hub)
+	HubBridge.Start()
+}
+
+// Select the mail backend of the settings
+func InitMailer() {
+	mail := Settings.Mail
+	switch mail.BackendName() {
+	case MailSMTP:
+		Mailer = mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     mail.Host,
+			Port:     mail.Port,
+			TLS:      mail.TLS,
+			Username: mail.Username,
+			Password: mail.Password,
+		})
+	case MailFile:
+		fileMailer, err := mailer.NewFile(mail.Dir)
+		if err != nil {
+			log.Fatalf("Failed to create mail directory: %v", err)
+		}
+		Mailer = fileMailer
+	default:
+		Mailer = mailer.NewLog(log.Default())
+	}
+	log.Printf("Sending email with the %s backend", mail.BackendName())
+}
+
+// Start sending the email queued in the outbox
+func InitOutbox() {
+	settings := Settings.Outbox
+	Outbox = outbox.NewDispatcher(DB, Mailer, Settings.Mail.From(), outbox.Options{
+		Workers:      settings.Workers,
+		MaxAttempts:  settings.MaxAttempts,
+		RetryDelay:   settings.RetryDelay,
+		MaxDelay:     settings.MaxRetryDelay,
+		PollInterval: settings.PollInterval,
+	})
+	Outbox.Start()
+}
+
+// Load environment variables from .env when present
+func LoadEnv() {
     err := godotenv.Load()
     if err != nil {
         log.Printf("Error loading .env file: %v", err)
     } else {
         log.Println(".env file loaded successfully")
     }
+}
+
+func Init() {
+    LoadSettings()
     InitDB()
     InitRedis()
+    InitTracker()
+    InitRealtime()
+    InitMailer()
+    InitOutbox()
 }
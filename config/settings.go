@@ -0,0 +1,335 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"event-analytics/pkg/mailer"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when present and CONFIG_FILE is not set
+const DefaultConfigFile = "config.yaml"
+
+// Config holds every setting of the application. Values come from the
+// defaults, then the YAML file, then the environment, each overriding the
+// one before. The env tag names the variable, secret marks values that
+// `config print` redacts.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Mail      MailConfig      `yaml:"mail"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr" env:"LISTEN_ADDR"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" env:"DATABASE_URL" secret:"dsn"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+}
+
+// MailConfig selects how email is sent. Backend is smtp, file (.eml files
+// in Dir) or log; when empty it is smtp if Host is set and log otherwise.
+type MailConfig struct {
+	Backend  string `yaml:"backend" env:"MAIL_BACKEND"`
+	Host     string `yaml:"host" env:"MAIL_HOST"`
+	Port     int    `yaml:"port" env:"MAIL_PORT"`
+	TLS      string `yaml:"tls" env:"MAIL_TLS"` // starttls, tls or none
+	Username string `yaml:"username" env:"MAIL_USERNAME"`
+	Password string `yaml:"password" env:"MAIL_PASSWORD" secret:"true"`
+	Sender   string `yaml:"sender" env:"MAIL_SENDER"` // Display name of the From address
+	Dir      string `yaml:"dir" env:"MAIL_DIR"`
+}
+
+// Mail backends
+const (
+	MailSMTP = "smtp"
+	MailFile = "file"
+	MailLog  = "log"
+)
+
+// BackendName returns the configured backend, resolving the empty default
+func (m MailConfig) BackendName() string {
+	switch {
+	case m.Backend != "":
+		return m.Backend
+	case m.Host != "":
+		return MailSMTP
+	}
+	return MailLog
+}
+
+// From is the From header of every email
+func (m MailConfig) From() string {
+	return fmt.Sprintf("%s <%s>", m.Sender, m.Username)
+}
+
+// OutboxConfig tunes the dispatcher sending queued email. A failed message
+// is retried after RetryDelay, doubled after every further failure up to
+// MaxRetryDelay, and marked dead after MaxAttempts.
+type OutboxConfig struct {
+	Workers       int           `yaml:"workers" env:"OUTBOX_WORKERS"`
+	MaxAttempts   int           `yaml:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+	RetryDelay    time.Duration `yaml:"retry_delay" env:"OUTBOX_RETRY_DELAY"`
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay" env:"OUTBOX_MAX_RETRY_DELAY"`
+	PollInterval  time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+}
+
+// RateLimitConfig allows Requests per Window from each client
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests" env:"RATE_LIMIT_REQUESTS"`
+	Window   time.Duration `yaml:"window" env:"RATE_LIMIT_WINDOW"`
+}
+
+// Settings is the loaded configuration. It holds the defaults until
+// LoadSettings runs.
+var Settings = Defaults()
+
+// Defaults returns the configuration used for anything not set elsewhere
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Mail: MailConfig{
+			Port:   587,
+			TLS:    mailer.TLSStartTLS,
+			Sender: "Event Analytics",
+			Dir:    "mail",
+		},
+		Outbox: OutboxConfig{
+			Workers:       4,
+			MaxAttempts:   8,
+			RetryDelay:    30 * time.Second,
+			MaxRetryDelay: time.Hour,
+			PollInterval:  5 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Requests: 100,
+			Window:   time.Minute,
+		},
+	}
+}
+
+// LoadSettings loads .env and the configuration into Settings, exiting with
+// every problem found when it is invalid
+func LoadSettings() {
+	LoadEnv()
+	cfg, err := Load(os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	Settings = cfg
+}
+
+// Load builds the configuration from the defaults, the YAML file named by
+// CONFIG_FILE (or config.yaml when it exists) and the variables returned
+// by lookup. The error lists every invalid setting, one per line.
+func Load(lookup func(string) (string, bool)) (*Config, error) {
+	cfg, problems := load(lookup)
+	problems = append(problems, cfg.Validate()...)
+	if len(problems) > 0 {
+		return cfg, errors.Join(problems...)
+	}
+	return cfg, nil
+}
+
+func load(lookup func(string) (string, bool)) (*Config, []error) {
+	cfg := Defaults()
+
+	path, explicit := lookup("CONFIG_FILE")
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return cfg, []error{fmt.Errorf("%s: %w", path, err)}
+		}
+	} else if explicit || !errors.Is(err, os.ErrNotExist) {
+		return cfg, []error{fmt.Errorf("CONFIG_FILE: %w", err)}
+	}
+
+	var problems []error
+	eachField(reflect.ValueOf(cfg).Elem(), func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return
+		}
+		// Empty variables, as in the example .env, keep the earlier value
+		raw, _ := lookup(name)
+		if raw = strings.TrimSpace(raw); raw == "" {
+			return
+		}
+		if err := setField(value, raw); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+		}
+	})
+	return cfg, problems
+}
+
+// Validate returns a problem for every invalid setting
+func (c *Config) Validate() []error {
+	var problems []error
+	check := func(ok bool, name, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s: "+format, append([]interface{}{name}, args...)...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "LISTEN_ADDR", "%q is not a host:port address", c.Server.Addr)
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT", "must be positive")
+
+	check(c.Database.URL != "", "DATABASE_URL", "is required")
+
+	check(c.Redis.Addr != "", "REDIS_ADDR", "is required")
+	check(c.Redis.DB >= 0, "REDIS_DB", "must not be negative")
+
+	check(c.Mail.Port > 0 && c.Mail.Port <= 65535, "MAIL_PORT", "%d is not a port", c.Mail.Port)
+	check(oneOf(c.Mail.TLS, mailer.TLSModes...), "MAIL_TLS", "%q is not one of %s", c.Mail.TLS, strings.Join(mailer.TLSModes, ", "))
+	switch backend := c.Mail.BackendName(); backend {
+	case MailSMTP:
+		check(c.Mail.Host != "", "MAIL_HOST", "is required by the smtp mail backend")
+	case MailFile:
+		check(c.Mail.Dir != "", "MAIL_DIR", "is required by the file mail backend")
+	case MailLog:
+	default:
+		check(false, "MAIL_BACKEND", "%q is not one of smtp, file, log", backend)
+	}
+	if c.Mail.Host != "" {
+		check(c.Mail.Username != "", "MAIL_USERNAME", "is required when MAIL_HOST is set")
+	}
+
+	check(c.Outbox.Workers > 0, "OUTBOX_WORKERS", "must be positive")
+	check(c.Outbox.MaxAttempts > 0, "OUTBOX_MAX_ATTEMPTS", "must be positive")
+	check(c.Outbox.RetryDelay > 0, "OUTBOX_RETRY_DELAY", "must be positive")
+	check(c.Outbox.MaxRetryDelay >= c.Outbox.RetryDelay, "OUTBOX_MAX_RETRY_DELAY", "must not be shorter than OUTBOX_RETRY_DELAY")
+	check(c.Outbox.PollInterval > 0, "OUTBOX_POLL_INTERVAL", "must be positive")
+
+	check(c.RateLimit.Requests > 0, "RATE_LIMIT_REQUESTS", "must be positive")
+	check(c.RateLimit.Window > 0, "RATE_LIMIT_WINDOW", "must be positive")
+	return problems
+}
+
+// Redacted returns a copy that is safe to print, with secrets masked
+func (c *Config) Redacted() *Config {
+	copied := *c
+	eachField(reflect.ValueOf(&copied).Elem(), func(field reflect.StructField, value reflect.Value) {
+		if value.Kind() != reflect.String || value.String() == "" {
+			return
+		}
+		switch field.Tag.Get("secret") {
+		case "true":
+			value.SetString(redacted)
+		case "dsn":
+			value.SetString(redactDSN(value.String()))
+		}
+	})
+	return &copied
+}
+
+// YAML renders the configuration in the format of the config file
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`(password=)\S+`)
+
+// redactDSN masks the password of a postgres:// URL or key=value DSN
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// eachField calls fn for every leaf field of the struct v, descending into
+// nested structs
+func eachField(v reflect.Value, fn func(field reflect.StructField, value reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if value.Kind() == reflect.Struct {
+			eachField(value, fn)
+			continue
+		}
+		fn(field, value)
+	}
+}
+
+func setField(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 1m", raw)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
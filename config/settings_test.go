@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(env(map[string]string{
+		"CONFIG_FILE":  filepath.Join(t.TempDir(), "missing.yaml"),
+		"DATABASE_URL": "postgres://localhost/events",
+	}))
+	require.Error(t, err, "an explicit CONFIG_FILE must exist")
+	assert.Contains(t, err.Error(), "CONFIG_FILE")
+
+	cfg, err = Load(env(map[string]string{"DATABASE_URL": "postgres://localhost/events"}))
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, 587, cfg.Mail.Port)
+	assert.Equal(t, 100, cfg.RateLimit.Requests)
+	assert.Equal(t, time.Minute, cfg.RateLimit.Window)
+}
+
+func TestLoadLayersFileThenEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  addr: ":9000"
+database:
+  url: postgres://file/events
+mail:
+  host: smtp.example.com
+  username: events@example.com
+  port: 2525
+rate_limit:
+  window: 30s
+`), 0o600))
+
+	cfg, err := Load(env(map[string]string{
+		"CONFIG_FILE":   path,
+		"MAIL_PORT":     "465",
+		"MAIL_PASSWORD": "hunter2",
+		"REDIS_ADDR":    "", // Empty values keep the default
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, "postgres://file/events", cfg.Database.URL)
+	assert.Equal(t, 465, cfg.Mail.Port, "the environment overrides the file")
+	assert.Equal(t, "hunter2", cfg.Mail.Password)
+	assert.Equal(t, 30*time.Second, cfg.RateLimit.Window)
+	assert.Equal(t, "localhost:6379", cfg.Redis.Addr)
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, err := Load(env(map[string]string{
+		"LISTEN_ADDR":         "8080",
+		"MAIL_PORT":           "smtp",
+		"MAIL_HOST":           "smtp.example.com",
+		"OUTBOX_WORKERS":      "0",
+		"RATE_LIMIT_REQUESTS": "0",
+		"RATE_LIMIT_WINDOW":   "soon",
+	}))
+	require.Error(t, err)
+
+	lines := strings.Split(err.Error(), "\n")
+	for _, name := range []string{"LISTEN_ADDR", "MAIL_PORT", "MAIL_USERNAME", "DATABASE_URL", "OUTBOX_WORKERS", "RATE_LIMIT_REQUESTS", "RATE_LIMIT_WINDOW"} {
+		found := false
+		for _, line := range lines {
+			found = found || strings.HasPrefix(line, name+":")
+		}
+		assert.True(t, found, "%s is reported in:\n%s", name, err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.Database.URL = "postgres://app:s3cret@db:5432/events?sslmode=disable"
+	cfg.Mail.Password = "hunter2"
+	cfg.Mail.Username = "events@example.com"
+
+	out, err := cfg.Redacted().YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "s3cret")
+	assert.NotContains(t, string(out), "hunter2")
+	assert.Contains(t, string(out), "postgres://app:REDACTED@db:5432/events")
+	assert.Contains(t, string(out), "events@example.com")
+	assert.Equal(t, "hunter2", cfg.Mail.Password, "the original is untouched")
+
+	assert.Equal(t, "host=db user=app password=REDACTED dbname=events", redactDSN("host=db user=app password=s3cret dbname=events"))
+	assert.Equal(t, "postgres://db/events", redactDSN("postgres://db/events"))
+}
+
+func TestMailBackend(t *testing.T) {
+	base := map[string]string{"DATABASE_URL": "postgres://localhost/events"}
+	load := func(vars map[string]string) (*Config, error) {
+		merged := map[string]string{}
+		for k, v := range base {
+			merged[k] = v
+		}
+		for k, v := range vars {
+			merged[k] = v
+		}
+		return Load(env(merged))
+	}
+
+	cfg, err := load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, MailLog, cfg.Mail.BackendName(), "without a host email is logged")
+
+	cfg, err = load(map[string]string{"MAIL_HOST": "smtp.example.com", "MAIL_USERNAME": "events@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, MailSMTP, cfg.Mail.BackendName())
+	assert.Equal(t, "Event Analytics <events@example.com>", cfg.Mail.From())
+
+	cfg, err = load(map[string]string{"MAIL_BACKEND": "file", "MAIL_DIR": "/tmp/mail"})
+	require.NoError(t, err)
+	assert.Equal(t, MailFile, cfg.Mail.BackendName())
+
+	_, err = load(map[string]string{"MAIL_BACKEND": "pigeon", "MAIL_TLS": "ssl"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAIL_BACKEND:")
+	assert.Contains(t, err.Error(), "MAIL_TLS:")
+
+	_, err = load(map[string]string{"MAIL_BACKEND": "smtp"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAIL_HOST: is required by the smtp mail backend")
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InteractionInput struct {
+	Name string `form:"name" json:"name" binding:"required,max=100"`
+}
+
+// TrackInteraction records a custom client-side interaction for an event
+func (ec *EventController) TrackInteraction(c *gin.Context) {
+	var input InteractionInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Interaction name is required"})
+		return
+	}
+
+	event, ok, err := ec.loadEvent(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load event"})
+		return
+	} else if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	services.TrackEventAction(c, event, models.ActionInteraction, input.Name)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"event-analytics/models"
+	"event-analytics/pkg/problem"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIAnalyticsResponse wraps an event's analytics report
+type APIAnalyticsResponse struct {
+	Data services.EventAnalyticsSummary `json:"data"`
+}
+
+// APIEventAnalytics returns the analytics report shown on the event
+// analytics page. Only the event owner or an admin may read it.
+func (ec *EventController) APIEventAnalytics(c *gin.Context) {
+	event, ok := ec.loadAPIEvent(c)
+	if !ok {
+		return
+	}
+	if !ec.canManage(currentUser(c), event) {
+		problem.AbortWithStatus(c, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		problem.AbortWithStatus(c, http.StatusBadRequest, "days must be between 1 and 365")
+		return
+	}
+
+	summary, err := services.GetEventAnalyticsSummary(event, days)
+	if err != nil {
+		log.Printf("API: failed to build analytics for event %s: %v", event.ID, err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to load analytics")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIAnalyticsResponse{Data: *summary})
+}
+
+// APITrackInteraction records a custom interaction, like TrackInteraction
+func (ec *EventController) APITrackInteraction(c *gin.Context) {
+	event, ok := ec.loadAPIEvent(c)
+	if !ok {
+		return
+	}
+
+	var input InteractionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		p := problem.New(http.StatusUnprocessableEntity, "Interaction name is required")
+		p.Errors = map[string]string{"name": "is required, at most 100 characters"}
+		problem.Abort(c, p)
+		return
+	}
+
+	services.TrackEventAction(c, event, models.ActionInteraction, input.Name)
+	c.Status(http.StatusNoContent)
+}
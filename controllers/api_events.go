@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/pkg/problem"
+	"event-analytics/repository"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	apiDefaultPerPage = 20
+	apiMaxPerPage     = 100
+)
+
+// APIEventInput is the JSON body for creating or replacing an event
+type APIEventInput struct {
+	Title          string     `json:"title" binding:"required"`
+	Description    string     `json:"description" binding:"required"`
+	StartTime      time.Time  `json:"start_time" binding:"required"`
+	EndTime        time.Time  `json:"end_time" binding:"required"`
+	Location       string     `json:"location" binding:"required"`
+	Status         string     `json:"status" binding:"required,oneof=draft published"`
+	PublishedDate  *time.Time `json:"published_date"`
+	Capacity       *int       `json:"capacity"`
+	RecurrenceRule string     `json:"recurrence_rule"`
+}
+
+// eventInput converts the body into the form input the event rules work on.
+// Times are stored in UTC with minute precision, like the HTML forms.
+func (in APIEventInput) eventInput() EventInput {
+	const formFormat = "2006-01-02T15:04"
+	input := EventInput{
+		Title:          in.Title,
+		Description:    in.Description,
+		StartTime:      in.StartTime.UTC().Format(formFormat),
+		EndTime:        in.EndTime.UTC().Format(formFormat),
+		Location:       in.Location,
+		Status:         in.Status,
+		RecurrenceRule: in.RecurrenceRule,
+	}
+	if in.PublishedDate != nil {
+		input.PublishedDate = in.PublishedDate.UTC().Format(formFormat)
+	}
+	if in.Capacity != nil {
+		input.Capacity = strconv.Itoa(*in.Capacity)
+	}
+	return input
+}
+
+// APIPagination describes the page returned by a list endpoint
+type APIPagination struct {
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+// APIEventList is the response of GET /api/v1/events
+type APIEventList struct {
+	Data []models.Event `json:"data"`
+	Meta APIPagination  `json:"meta"`
+}
+
+// APIEventResponse wraps a single event
+type APIEventResponse struct {
+	Data models.Event `json:"data"`
+}
+
+// loadAPIEvent loads the event in the URL, answering 404 for unknown events
+// and for drafts the user cannot see
+func (ec *EventController) loadAPIEvent(c *gin.Context) (*models.Event, bool) {
+	event, ok, err := ec.loadEvent(c)
+	if err != nil {
+		log.Printf("API: failed to load event %s: %v", c.Param("id"), err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to load event")
+		return nil, false
+	}
+	if !ok || (event.Status == "draft" && !ec.canManage(currentUser(c), event)) {
+		problem.AbortWithStatus(c, http.StatusNotFound, "Event not found")
+		return nil, false
+	}
+	return event, true
+}
+
+// prepareAPIEvents fills the virtual fields of events for the current user
+func (ec *EventController) prepareAPIEvents(c *gin.Context, events []models.Event) {
+	user := currentUser(c)
+	for i := range events {
+		events[i].IsEditable = ec.canManage(user, &events[i])
+		events[i].Recurrence = services.DescribeRecurrence(&events[i])
+	}
+	if err := services.ApplyRSVPCounts(events); err != nil {
+		log.Printf("API: failed to load RSVP counts: %v", err)
+	}
+}
+
+// bindAPIEventInput binds the JSON body, answering 400 for malformed JSON
+// and 422 with per-field errors for missing or invalid fields
+func bindAPIEventInput(c *gin.Context) (*APIEventInput, bool) {
+	var input APIEventInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var errs validator.ValidationErrors
+		if !errors.As(err, &errs) {
+			problem.AbortWithStatus(c, http.StatusBadRequest, "Malformed JSON body: "+err.Error())
+			return nil, false
+		}
+
+		p := problem.New(http.StatusUnprocessableEntity, "Please fill all required fields correctly")
+		p.Errors = make(map[string]string)
+		inputType := reflect.TypeOf(input)
+		for _, fe := range errs {
+			name := fe.Field()
+			if field, ok := inputType.FieldByName(fe.StructField()); ok {
+				name = strings.Split(field.Tag.Get("json"), ",")[0]
+			}
+			if fe.Tag() == "required" {
+				p.Errors[name] = "is required"
+			} else {
+				p.Errors[name] = "is invalid"
+			}
+		}
+		problem.Abort(c, p)
+		return nil, false
+	}
+	return &input, true
+}
+
+// abortEventRuleError answers a failed event rule check
+func abortEventRuleError(c *gin.Context, err error) {
+	if errors.Is(err, errTitleTaken) {
+		problem.AbortWithStatus(c, http.StatusConflict, userMessage(err))
+		return
+	}
+	problem.AbortWithStatus(c, http.StatusUnprocessableEntity, userMessage(err))
+}
+
+// parseAPITime reads an RFC 3339 timestamp or a YYYY-MM-DD date (UTC midnight)
+func parseAPITime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// APIListEvents lists the events visible to the user. Supported filters:
+// status (comma separated), from and to (events overlapping the range),
+// page and per_page.
+func (ec *EventController) APIListEvents(c *gin.Context) {
+	user := currentUser(c)
+
+	var filter repository.EventFilter
+	if !ec.isAdmin(user) {
+		filter.VisibleTo = &user.ID
+	}
+
+	if status := c.Query("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if s != "draft" && s != "published" && s != "expired" {
+				problem.AbortWithStatus(c, http.StatusBadRequest, "status must be draft, published or expired")
+				return
+			}
+		}
+		filter.Statuses = statuses
+	}
+
+	if from := c.Query("from"); from != "" {
+		fromTime, err := parseAPITime(from)
+		if err != nil {
+			problem.AbortWithStatus(c, http.StatusBadRequest, "from must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			return
+		}
+		filter.From = &fromTime
+	}
+	if to := c.Query("to"); to != "" {
+		toTime, err := parseAPITime(to)
+		if err != nil {
+			problem.AbortWithStatus(c, http.StatusBadRequest, "to must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			return
+		}
+		filter.To = &toTime
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		problem.AbortWithStatus(c, http.StatusBadRequest, "page must be a positive integer")
+		return
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(apiDefaultPerPage)))
+	if err != nil || perPage < 1 || perPage > apiMaxPerPage {
+		problem.AbortWithStatus(c, http.StatusBadRequest, "per_page must be between 1 and 100")
+		return
+	}
+
+	filter.Offset = (page - 1) * perPage
+	filter.Limit = perPage
+	events, total, err := ec.events.List(filter)
+	if err != nil {
+		log.Printf("API: failed to list events: %v", err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to fetch events")
+		return
+	}
+	ec.prepareAPIEvents(c, events)
+
+	c.JSON(http.StatusOK, APIEventList{
+		Data: events,
+		Meta: APIPagination{Page: page, PerPage: perPage, Total: total},
+	})
+}
+
+// APIGetEvent returns a single event
+func (ec *EventController) APIGetEvent(c *gin.Context) {
+	event, ok := ec.loadAPIEvent(c)
+	if !ok {
+		return
+	}
+
+	events := []models.Event{*event}
+	ec.prepareAPIEvents(c, events)
+	c.JSON(http.StatusOK, APIEventResponse{Data: events[0]})
+}
+
+// APICreateEvent creates an event with the same rules as CreateEvent
+func (ec *EventController) APICreateEvent(c *gin.Context) {
+	input, ok := bindAPIEventInput(c)
+	if !ok {
+		return
+	}
+
+	event, err := eventFromInput(ec.events, input.eventInput(), currentUser(c).ID)
+	if err != nil {
+		abortEventRuleError(c, err)
+		return
+	}
+
+	if err := ec.events.Create(event); err != nil {
+		log.Printf("API: failed to create event: %v", err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to create event")
+		return
+	}
+	services.PublishEventChange(services.EventCreated, event)
+
+	events := []models.Event{*event}
+	ec.prepareAPIEvents(c, events)
+	c.Header("Location", "/api/v1/events/"+event.ID.String())
+	c.JSON(http.StatusCreated, APIEventResponse{Data: events[0]})
+}
+
+// APIUpdateEvent replaces an event's fields with the same rules as UpdateEvent
+func (ec *EventController) APIUpdateEvent(c *gin.Context) {
+	event, ok := ec.loadAPIEvent(c)
+	if !ok {
+		return
+	}
+	if !ec.canManage(currentUser(c), event) {
+		problem.AbortWithStatus(c, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	input, ok := bindAPIEventInput(c)
+	if !ok {
+		return
+	}
+
+	changes, err := applyEventUpdate(ec.events, event, input.eventInput())
+	if err != nil {
+		abortEventRuleError(c, err)
+		return
+	}
+
+	if err := ec.events.Update(event); err != nil {
+		log.Printf("API: failed to update event %s: %v", event.ID, err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to update event")
+		return
+	}
+	afterEventUpdate(c, event, changes)
+
+	events := []models.Event{*event}
+	ec.prepareAPIEvents(c, events)
+	c.JSON(http.StatusOK, APIEventResponse{Data: events[0]})
+}
+
+// APIDeleteEvent deletes an event
+func (ec *EventController) APIDeleteEvent(c *gin.Context) {
+	event, ok := ec.loadAPIEvent(c)
+	if !ok {
+		return
+	}
+	if !ec.canManage(currentUser(c), event) {
+		problem.AbortWithStatus(c, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	if err := ec.events.Delete(event.ID); err != nil {
+		log.Printf("API: failed to delete event %s: %v", event.ID, err)
+		problem.AbortWithStatus(c, http.StatusInternalServerError, "Failed to delete event")
+		return
+	}
+	services.TrackEventAction(c, event, models.ActionDelete, "")
+	services.PublishEventChange(services.EventDeleted, event)
+
+	c.Status(http.StatusNoContent)
+}
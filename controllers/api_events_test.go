@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIEventInputConversion(t *testing.T) {
+	capacity := 25
+	published := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	in := APIEventInput{
+		Title:          "Go meetup",
+		Description:    "Monthly talk",
+		StartTime:      time.Date(2024, 5, 1, 18, 30, 45, 0, time.FixedZone("CET", 3600)),
+		EndTime:        time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC),
+		Location:       "Hall A",
+		Status:         "published",
+		PublishedDate:  &published,
+		Capacity:       &capacity,
+		RecurrenceRule: "FREQ=WEEKLY",
+	}
+
+	input := in.eventInput()
+	assert.Equal(t, "2024-05-01T17:30", input.StartTime)
+	assert.Equal(t, "2024-05-01T20:00", input.EndTime)
+	assert.Equal(t, "2024-05-01T09:00", input.PublishedDate)
+	assert.Equal(t, "25", input.Capacity)
+	assert.Equal(t, "FREQ=WEEKLY", input.RecurrenceRule)
+
+	in.PublishedDate, in.Capacity = nil, nil
+	input = in.eventInput()
+	assert.Empty(t, input.PublishedDate)
+	assert.Empty(t, input.Capacity)
+}
+
+func TestParseAPITime(t *testing.T) {
+	got, err := parseAPITime("2024-05-01T18:00:00+02:00")
+	require.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2024, 5, 1, 16, 0, 0, 0, time.UTC)))
+
+	got, err = parseAPITime("2024-05-01")
+	require.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = parseAPITime("May 1st")
+	assert.Error(t, err)
+}
+
+func TestBindAPIEventInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantErrors map[string]string
+	}{
+		{
+			name: "valid",
+			body: `{"title":"Go meetup","description":"Talk","start_time":"2024-05-01T18:00:00Z",` +
+				`"end_time":"2024-05-01T20:00:00Z","location":"Hall A","status":"draft"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "malformed json",
+			body:       `{"title":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "missing and invalid fields",
+			body: `{"title":"Go meetup","description":"Talk","start_time":"2024-05-01T18:00:00Z",` +
+				`"end_time":"2024-05-01T20:00:00Z","status":"archived"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{"location": "is required", "status": "is invalid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			input, ok := bindAPIEventInput(c)
+			if tt.wantStatus == http.StatusOK {
+				require.True(t, ok)
+				assert.Equal(t, "Go meetup", input.Title)
+				return
+			}
+
+			require.False(t, ok)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+			var p problem.Details
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.wantStatus, p.Status)
+			assert.Equal(t, "/api/v1/events", p.Instance)
+			assert.Equal(t, tt.wantErrors, p.Errors)
+		})
+	}
+}
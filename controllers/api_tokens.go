@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"event-analytics/models"
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APITokenInput is the profile page form for a new API token. Access is
+// "none", "read" or "write" (read-write) for each resource.
+type APITokenInput struct {
+	Name            string `form:"name" binding:"required,max=100"`
+	EventsAccess    string `form:"events_access" binding:"omitempty,oneof=none read write"`
+	AnalyticsAccess string `form:"analytics_access" binding:"omitempty,oneof=none read write"`
+}
+
+// apiTokenScopes turns the per-resource access levels into token scopes
+func apiTokenScopes(input APITokenInput) []string {
+	var scopes []string
+	add := func(access, read, write string) {
+		switch access {
+		case "read":
+			scopes = append(scopes, read)
+		case "write":
+			scopes = append(scopes, read, write)
+		}
+	}
+	add(input.EventsAccess, models.ScopeEventsRead, models.ScopeEventsWrite)
+	add(input.AnalyticsAccess, models.ScopeAnalyticsRead, models.ScopeAnalyticsWrite)
+	return scopes
+}
+
+// CreateAPIToken issues a personal access token for the current user
+func CreateAPIToken(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	var input APITokenInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Token name is required (at most 100 characters)"))
+		return
+	}
+
+	token, _, err := services.CreateAPIToken(user.ID, input.Name, apiTokenScopes(input))
+	if err != nil {
+		if errors.Is(err, services.ErrNoScopes) {
+			c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Give the token read or read-write access to events or analytics"))
+			return
+		}
+		log.Printf("API token: failed to create token for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Failed to create API token"))
+		return
+	}
+
+	// The plain token is never stored, so hand it to the profile page once
+	c.SetCookie("api_token", token, 300, "/user/profile", "", false, true)
+	c.SetCookie("flash", "API token created. Copy it now, it will not be shown again", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
+
+// RevokeAPIToken deletes one of the current user's API tokens
+func RevokeAPIToken(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("API token not found"))
+		return
+	}
+
+	if err := services.RevokeAPIToken(user.ID, tokenID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("API token not found"))
+			return
+		}
+		log.Printf("API token: failed to revoke token %s: %v", tokenID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Failed to revoke API token"))
+		return
+	}
+
+	c.SetCookie("flash", "API token revoked", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"testing"
+
+	"event-analytics/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokenScopes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input APITokenInput
+		want  []string
+	}{
+		{name: "no access", input: APITokenInput{EventsAccess: "none"}, want: nil},
+		{
+			name:  "read-write events",
+			input: APITokenInput{EventsAccess: "write"},
+			want:  []string{models.ScopeEventsRead, models.ScopeEventsWrite},
+		},
+		{
+			name:  "read events and analytics",
+			input: APITokenInput{EventsAccess: "read", AnalyticsAccess: "read"},
+			want:  []string{models.ScopeEventsRead, models.ScopeAnalyticsRead},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, apiTokenScopes(tt.input))
+		})
+	}
+}
@@ -4,22 +4,36 @@ import (
 	"event-analytics/config"
 	"event-analytics/models"
 	"event-analytics/render"
+	"event-analytics/repository"
 	"event-analytics/utils"
 	"event-analytics/services"
+	"errors"
 	"net/http"
+	"net/url"
 
 	"fmt"
 	"log"
-    "strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func Login(c *gin.Context) {
-	var input struct {
-		Identifier string `form:"identifier" binding:"required"` // Changed to a single identifier for username/email
-		Password   string `form:"password" binding:"required"`
-	}
+type LoginInput struct {
+	Identifier string `form:"identifier" binding:"required"` // Changed to a single identifier for username/email
+	Password   string `form:"password" binding:"required"`
+}
+
+// AuthController serves the login, registration and password reset forms
+type AuthController struct {
+	users repository.UserRepository
+}
+
+func NewAuthController(users repository.UserRepository) *AuthController {
+	return &AuthController{users: users}
+}
+
+func (ac *AuthController) Login(c *gin.Context) {
+	var input LoginInput
 
 	if err := c.ShouldBind(&input); err != nil {
 		c.HTML(http.StatusBadRequest, "login.html", gin.H{
@@ -29,9 +43,9 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
 	// Check if the identifier is an email or username
-	if err := config.DB.Where("username = ? OR email = ?", input.Identifier, input.Identifier).First(&user).Error; err != nil {
+	user, err := ac.users.FindByLogin(input.Identifier)
+	if err != nil {
 		c.HTML(http.StatusUnauthorized, "login.html", gin.H{
 			"error": "Invalid credentials",
 			"title": "Login",
@@ -55,7 +69,36 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	sessionToken, err := config.SessionStore.Create(c.Request.Context(), user.ID.String())
+	// With two-factor authentication the session waits for the code, see
+	// AuthController.TwoFactor
+	if user.TwoFactorEnabled() {
+		if twoFactorLocked(c.Request.Context(), user.ID.String()) {
+			c.HTML(http.StatusTooManyRequests, "login.html", gin.H{
+				"error": "Too many invalid two-factor codes. Please try again later.",
+				"title": "Login",
+			})
+			return
+		}
+		pending, err := config.TwoFactorPending.Create(c.Request.Context(), user.ID.String())
+		if err != nil {
+			log.Printf("Login: failed to store pending two-factor login: %v", err)
+			c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+				"error": "Failed to create session",
+				"title": "Login",
+			})
+			return
+		}
+		c.SetCookie("pending_2fa", pending, 300, "/auth", "", false, true)
+		c.Redirect(http.StatusFound, "/auth/two-factor")
+		return
+	}
+
+	ac.startSession(c, user.ID.String())
+}
+
+// startSession signs the user in and sends them to the dashboard
+func (ac *AuthController) startSession(c *gin.Context, userID string) {
+	sessionToken, err := config.SessionStore.Create(c.Request.Context(), userID)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
 			"error": "Failed to create session",
@@ -87,12 +130,14 @@ func Logout(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/auth/login")
 }
 
-func Register(c *gin.Context) {
-	var input struct {
-		Username string `form:"username" binding:"required"`
-		Email    string `form:"email" binding:"required,email"`
-		Password string `form:"password" binding:"required,min=6"`
-	}
+type RegisterInput struct {
+	Username string `form:"username" binding:"required"`
+	Email    string `form:"email" binding:"required,email"`
+	Password string `form:"password" binding:"required,min=6"`
+}
+
+func (ac *AuthController) Register(c *gin.Context) {
+	var input RegisterInput
 
 	if err := c.ShouldBind(&input); err != nil {
 		c.HTML(http.StatusBadRequest, "register.html", gin.H{
@@ -102,8 +147,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := config.DB.Where("username = ?", input.Username).First(&user).Error; err == nil {
+	if _, err := ac.users.FindByUsername(input.Username); err == nil {
 		c.HTML(http.StatusBadRequest, "register.html", gin.H{
 			"error": "Username already taken",
 			"title": "Register",
@@ -111,7 +155,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err == nil {
+	if _, err := ac.users.FindByEmail(input.Email); err == nil {
 		c.HTML(http.StatusBadRequest, "register.html", gin.H{
 			"error": "Email already taken",
 			"title": "Register",
@@ -119,7 +163,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	user = models.User{
+	user := models.User{
 		Username: input.Username,
 		Email:    input.Email,
 		Password: input.Password,
@@ -133,84 +177,25 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	tx := config.DB.Begin() // Start a transaction
-
-	if err := tx.Create(&user).Error; err != nil {
-        tx.Rollback() // Rollback if user creation fails
-        c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-            "error": "Failed to create user",
-            "title": "Register",
-        })
-        return
-    }
-
-    // Create password history entry
-	passwordHistory := models.PasswordHistory{
-		UserID:    user.ID,
-		Password:  user.Password, // Already hashed
-	}
+	baseURL := utils.GetBaseURL(c.Request)
 
-	if err := tx.Create(&passwordHistory).Error; err != nil {
-		tx.Rollback()
+	// Creates the user, the first password history entry, the 'user' role,
+	// the verification token and its email together
+	err := ac.users.Transaction(func(users repository.UserRepository, tx *gorm.DB) error {
+		if err := users.Create(&user, models.RoleUser); err != nil {
+			return err
+		}
+		return queueVerification(tx, baseURL, &user)
+	})
+	if err != nil {
+		log.Printf("Register: failed to create user %s: %v", input.Username, err)
 		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-			"error": "Failed to create password history",
+			"error": "Unable to complete registration. Please try again.",
 			"title": "Register",
 		})
 		return
 	}
-
-    // Assign 'user' role to the registered user
-    var userRole models.Role
-    if err := tx.Where("name = ?", "user").First(&userRole).Error; err != nil {
-        tx.Rollback() // Rollback if role assignment fails
-        c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-            "error": "Failed to assign role.",
-            "title": "Register",
-        })
-        return
-    }
-
-    userRoleMapping := models.UserRole{
-        UserID: user.ID,
-        RoleID: userRole.ID,
-    }
-
-    if err := tx.Create(&userRoleMapping).Error; err != nil {
-        tx.Rollback() // Rollback if role mapping fails
-        c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-            "error": "Failed to assign role.",
-            "title": "Register",
-        })
-        return
-    }
-
-    // Commit the transaction after all operations are successful
-    if err := tx.Commit().Error; err != nil {
-        c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-            "error": "Unable to complete registration. Please try again.",
-            "title": "Register",
-        })
-        return
-    }
-
-	token := utils.GenerateRandomToken()
-	if err := config.DB.Exec(
-		"INSERT INTO verification_tokens (user_id, token) VALUES (?, ?)", user.ID, token,
-	).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-			"error": "Failed to generate verification token",
-			"title": "Register"})
-		return
-	}
-
-	baseURL := utils.GetBaseURL(c.Request)
-	verifyURL := fmt.Sprintf("%s/auth/verify?token=%s", baseURL, token)
-
-	body := utils.RenderTemplate("templates/verification.html", map[string]interface{}{
-		"VerifyURL": verifyURL,
-	})
-
-	utils.SendEmailAsync(c.Request, input.Email, "Verify Your Email", body)
+	config.Outbox.Notify()
 
 	// c.Redirect(http.StatusFound, "/auth/login")
 	c.HTML(http.StatusOK, "register.html", gin.H{
@@ -229,49 +214,111 @@ func Verify(c *gin.Context) {
 		return
 	}
 
-	var verification struct {
-		UserID string
-		Token  string
-	}
-	if err := config.DB.Raw(
-		"SELECT user_id, token FROM verification_tokens WHERE token = ?", token,
-	).Scan(&verification).Error; err != nil {
-		c.HTML(http.StatusBadRequest, "verify.html", gin.H{
-			"error": "Invalid or expired verification token",
+	// Uses up the token and marks the user verified together
+	if err := services.VerifyEmail(token); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.HTML(http.StatusBadRequest, "verify.html", gin.H{
+				"error": "Invalid or expired verification token",
+				"title": "User account verification",
+			})
+			return
+		}
+		log.Printf("Verify: failed to verify email: %v", err)
+		c.HTML(http.StatusInternalServerError, "verify.html", gin.H{
+			"error": "Failed to update verification status",
 			"title": "User account verification",
 		})
 		return
 	}
 
-	if err := config.DB.Exec(
-		"DELETE FROM verification_tokens WHERE token = ?", token,
-	).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "verify.html", gin.H{
-			"error": "Failed to validate verification",
-			"title": "User account verification",
-		})
-		return
+	c.HTML(http.StatusOK, "verify.html", gin.H{
+		"success": "Your email has been successfully verified. You can now log in.",
+		"title":   "User account verification",
+	})
+}
+
+// queueVerification creates a verification token for user and queues the
+// email with its link in tx
+func queueVerification(tx *gorm.DB, baseURL string, user *models.User) error {
+	token, err := services.CreateVerificationToken(tx, user.ID)
+	if err != nil {
+		return err
 	}
 
-	// Update the user's verification status
-	if err := config.DB.Model(&models.User{}).Where("id = ?", verification.UserID).Update("is_verified", true).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "verify.html", gin.H{
-			"error": "Failed to update verification status",
-			"title": "User account verification",
+	verifyURL := fmt.Sprintf("%s/auth/verify?token=%s", baseURL, url.QueryEscape(token))
+	body := utils.RenderTemplate("templates/verification.html", map[string]interface{}{
+		"VerifyURL": verifyURL,
+	})
+	return utils.QueueEmail(tx, user.Email, "Verify Your Email", body)
+}
+
+type ResendVerificationInput struct {
+	Email string `form:"email" binding:"required,email"`
+}
+
+// ResendVerification mails a new verification link to an unverified
+// account, at most config.VerificationResends allows. The answer is the
+// same whether or not the address belongs to one, so the form does not
+// reveal accounts.
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	var input ResendVerificationInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.HTML(http.StatusBadRequest, "resend_verification.html", gin.H{
+			"error": "Please enter a valid email address",
+			"title": "Resend Verification Email",
 		})
 		return
 	}
 
-	c.HTML(http.StatusOK, "verify.html", gin.H{
-		"success": "Your email has been successfully verified. You can now log in.",
-		"title":   "User account verification",
+	user, err := ac.users.FindByEmail(input.Email)
+	if err == nil && !user.IsVerified {
+		allowed, err := config.VerificationResends.Allow(c.Request.Context(), user.ID.String())
+		if err != nil {
+			log.Printf("ResendVerification: rate limit check failed for %s: %v", user.ID, err)
+		} else if !allowed {
+			c.HTML(http.StatusTooManyRequests, "resend_verification.html", gin.H{
+				"error": "Too many verification emails requested. Please try again later.",
+				"title": "Resend Verification Email",
+			})
+			return
+		}
+
+		baseURL := utils.GetBaseURL(c.Request)
+		err = ac.users.Transaction(func(_ repository.UserRepository, tx *gorm.DB) error {
+			return queueVerification(tx, baseURL, user)
+		})
+		if err != nil {
+			log.Printf("ResendVerification: failed to queue the verification link for %s: %v", user.ID, err)
+			c.HTML(http.StatusInternalServerError, "resend_verification.html", gin.H{
+				"error": "Unable to send the verification link. Please try again.",
+				"title": "Resend Verification Email",
+			})
+			return
+		}
+		config.Outbox.Notify()
+	}
+
+	c.HTML(http.StatusOK, "resend_verification.html", gin.H{
+		"success": "If an unverified account uses this address, a new verification link has been sent to it.",
+		"title":   "Resend Verification Email",
 	})
 }
 
-func ForgotPassword(c *gin.Context) {
-	email := c.PostForm("email")
-	var user models.User
-	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+type ForgotPasswordInput struct {
+	Email string `form:"email"`
+}
+
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.HTML(http.StatusBadRequest, "forgot_password.html", gin.H{
+			"error": "Please enter your email",
+			"title": "Forget Password",
+		})
+		return
+	}
+	email := input.Email
+	if _, err := ac.users.FindByEmail(email); err != nil {
 		c.HTML(http.StatusBadRequest, "forgot_password.html", gin.H{
 			"error": "Email not found",
 			"title": "Forget Password",
@@ -279,27 +326,56 @@ func ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	token := utils.GenerateRandomToken()
-	config.DB.Exec("INSERT INTO password_resets (email, token) VALUES (?, ?)", email, token)
-
 	baseURL := utils.GetBaseURL(c.Request)
-	verifyURL := fmt.Sprintf("%s/auth/reset-password?token=%s", baseURL, token)
-
-	body := utils.RenderTemplate("templates/password_reset_mail.html", map[string]interface{}{
-		"VerifyURL": verifyURL,
+	err := ac.users.Transaction(func(_ repository.UserRepository, tx *gorm.DB) error {
+		token, err := services.CreatePasswordReset(tx, email)
+		if err != nil {
+			return err
+		}
+
+		verifyURL := fmt.Sprintf("%s/auth/reset-password?token=%s", baseURL, url.QueryEscape(token))
+		body := utils.RenderTemplate("templates/password_reset_mail.html", map[string]interface{}{
+			"VerifyURL": verifyURL,
+		})
+		return utils.QueueEmail(tx, email, "Reset your password", body)
 	})
+	if err != nil {
+		log.Printf("ForgotPassword: failed to queue the reset link for %s: %v", email, err)
+		c.HTML(http.StatusInternalServerError, "forgot_password.html", gin.H{
+			"error": "Unable to send the reset link. Please try again.",
+			"title": "Forget Password",
+		})
+		return
+	}
+	config.Outbox.Notify()
 
-	utils.SendEmailAsync(c.Request, email, "Reset your password", body)
 	c.HTML(http.StatusOK, "forgot_password.html", gin.H{
 		"success": "Reset link sent to your email",
 		"title":   "Forget Password",
 	})
 }
 
-func ResetPassword(c *gin.Context) {
-	token := c.PostForm("token")
-	newPassword := c.PostForm("password")
-	confirmPassword := c.PostForm("confirm_password")
+// errPasswordUsed rolls back a password reset to a password in the history
+var errPasswordUsed = errors.New("password used before")
+
+type ResetPasswordInput struct {
+	Token           string `form:"token"`
+	Password        string `form:"password"`
+	ConfirmPassword string `form:"confirm_password"`
+}
+
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.HTML(http.StatusBadRequest, "reset_password.html", gin.H{
+			"error": "Invalid request, open the link from the email again",
+			"title": "Reset Password",
+		})
+		return
+	}
+	token := input.Token
+	newPassword := input.Password
+	confirmPassword := input.ConfirmPassword
 
 	if newPassword != confirmPassword {
         render.Render(c, gin.H{
@@ -310,73 +386,58 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Start transaction
-	tx := config.DB.Begin()
-
-	// Get email from reset token
-	var reset struct {
-		Email string
-	}
-	if err := tx.Raw("SELECT email FROM password_resets WHERE token = ?", token).Scan(&reset).Error; err != nil {
-		tx.Rollback()
+	// Use up the reset token and save the new password together, a
+	// failure makes the token usable again
+	var tokenErr error
+	err := ac.users.Transaction(func(users repository.UserRepository, tx *gorm.DB) error {
+		email, err := services.UsePasswordReset(tx, token)
+		if err != nil {
+			tokenErr = err
+			return err
+		}
+
+		user, err := users.FindByEmail(email)
+		if err != nil {
+			return err
+		}
+
+		// Compare the plain new password with previous passwords using the model function
+		passwordHistories, err := users.PasswordHistory(user.ID)
+		if err != nil {
+			return err
+		}
+		if user.IsPasswordUsed(newPassword, passwordHistories) {
+			return errPasswordUsed
+		}
+
+		user.Password = newPassword
+		if err := user.HashPassword(); err != nil {
+			return err
+		}
+		return users.ChangePassword(user)
+	})
+	switch {
+	case tokenErr != nil:
+		if !errors.Is(tokenErr, services.ErrInvalidToken) {
+			log.Printf("Reset password: Failed to check token: %v", tokenErr)
+		}
 		c.Redirect(http.StatusFound, "/auth/login?error=invalid_reset_token")
 		c.Abort()
 		return
-	}
-
-	// Find user by email
-	var user models.User
-	if err := tx.Where("email = ?", reset.Email).First(&user).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: User not found for email %s: %v", reset.Email, err)
+	case errors.Is(err, repository.ErrNotFound):
+		log.Printf("Reset password: User not found for the token's email")
 		c.Redirect(http.StatusFound, "/auth/login?error=user_not_found")
 		c.Abort()
 		return
-	}
-
-	// Fetch the last 5 password histories
-	var passwordHistories []models.PasswordHistory
-	if err := tx.Where("user_id = ?", user.ID).
-		Order("created_at DESC").
-		// Limit(5).
-		Find(&passwordHistories).Error; err != nil {
-		tx.Rollback()
-        render.Render(c, gin.H{
-            "error": "Unable to verify password history. Please try again",
-            "title": "Reset Password",
-            "token": token,
-        }, "reset_password.html")
-		return
-	}
-
-	// Compare the plain new password with previous passwords using the model function
-    if user.IsPasswordUsed(newPassword, passwordHistories) {
-        tx.Rollback()
+	case errors.Is(err, errPasswordUsed):
         render.Render(c, gin.H{
             "error": "This password has been used recently. Please choose a different password",
             "title": "Reset Password",
             "token": token,
         }, "reset_password.html")
-        return
-    }
-
-	// Update user's password
-	user.Password = newPassword
-	if err := user.HashPassword(); err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: Failed to hash password: %v", err)
-        render.Render(c, gin.H{
-			"error": "An error occurred while updating password",
-			"title": "Reset Password",
-			"token": token,
-		}, "reset_password.html")
 		return
-	}
-
-	// Save updated user
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: Failed to save user: %v", err)
+	case err != nil:
+		log.Printf("Reset password: Failed to save password: %v", err)
         render.Render(c, gin.H{
 			"error": "An error occurred while saving password",
 			"title": "Reset Password",
@@ -385,92 +446,16 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Save the new password to history
-	newHistory := models.PasswordHistory{
-		UserID:    user.ID,
-		Password:  user.Password,
-	}
-
-	if err := tx.Create(&newHistory).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: Failed to save password history: %v", err)
-        render.Render(c, gin.H{
-			"error": "An error occurred while saving password history",
-			"title": "Reset Password",
-			"token": token,
-		}, "reset_password.html")
-		return
-	}
-
-	// Delete the used token
-	if err := tx.Exec("DELETE FROM password_resets WHERE token = ?", token).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: Failed to delete token: %v", err)
-        render.Render(c, gin.H{
-			"error": "An error occurred while completing password reset",
-			"title": "Reset Password",
-			"token": token,
-		}, "reset_password.html")
-		return
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		log.Printf("Reset password: Failed to commit transaction: %v", err)
-        render.Render(c, gin.H{
-			"error": "An error occurred while finalizing password reset",
-			"title": "Reset Password",
-			"token": token,
-		}, "reset_password.html")
-		return
-	}
-
 	// Redirect to login with success message
 	c.Redirect(http.StatusFound, "/auth/login?success=password_reset")
 }
 
-func Dashboard(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists || user == nil {
-		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
-		c.Abort()
-		return
-	}
-
-    currentUser, ok := user.(*models.User)
-    if !ok {
-        c.Redirect(http.StatusFound, "/auth/login?error=invalid_user")
-        c.Abort()
-        return
-    }
-
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit := 12 // Number of events per page
-	offset := (page - 1) * limit
-
-	var events []models.Event
-	if err := config.DB.Order("created_at DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "dashboard.html", gin.H{
-			"error": "Failed to fetch events",
-			"title": "Dashboard",
-			"user":  user,
-		})
-		return
-	}
-
-    // Check editability for each event
-    for i := range events {
-        events[i].IsEditable = services.CheckEventEditPermission(&events[i], currentUser)
-    }
-
-	data := gin.H{
-		"events": events,
-		"title":  "Dashboard",
-		"user":   user,
-	}
-
-	c.HTML(http.StatusOK, "dashboard.html", data)
+type ProfileInput struct {
+	FirstName string `form:"firstName" binding:"required"`
+	LastName  string `form:"lastName" binding:"required"`
+	Address   string `form:"address" binding:"required"`
+	Username  string `form:"username" binding:"required"`
+	Email     string `form:"email" binding:"required,email"`
 }
 
 func EditProfile(c *gin.Context) {
@@ -482,13 +467,7 @@ func EditProfile(c *gin.Context) {
 	}
 
 	// Parse and validate the input form
-	var input struct {
-		FirstName string `form:"firstName" binding:"required"`
-		LastName  string `form:"lastName" binding:"required"`
-		Address   string `form:"address" binding:"required"`
-		Username  string `form:"username" binding:"required"`
-		Email     string `form:"email" binding:"required,email"`
-	}
+	var input ProfileInput
 
 	if err := c.ShouldBind(&input); err != nil {
 		c.HTML(http.StatusBadRequest, "profile.html", gin.H{
@@ -540,7 +519,12 @@ func EditProfile(c *gin.Context) {
 	})
 }
 
-func ChangePassword(c *gin.Context) {
+type ChangePasswordInput struct {
+	OldPassword string `form:"old_password" binding:"required"`
+	NewPassword string `form:"new_password" binding:"required,min=6"`
+}
+
+func (ac *AuthController) ChangePassword(c *gin.Context) {
 	// Retrieve the user from the session
 	user, err := utils.GetUserFromSession(c)
 	if err != nil {
@@ -549,10 +533,7 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	// Bind input
-	var input struct {
-		OldPassword string `form:"old_password" binding:"required"`
-		NewPassword string `form:"new_password" binding:"required,min=6"`
-	}
+	var input ChangePasswordInput
 	if err := c.ShouldBind(&input); err != nil {
 		c.HTML(http.StatusBadRequest, "change_password.html", gin.H{
 			"error": "Please ensure your new password is at least 6 characters long",
@@ -563,8 +544,8 @@ func ChangePassword(c *gin.Context) {
 	}
 
 	// Fetch the latest user record from the database
-	var freshUser models.User
-	if err := config.DB.First(&freshUser, "id = ?", user.ID).Error; err != nil {
+	freshUser, err := ac.users.FindByID(user.ID)
+	if err != nil {
 		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
 			"error": "Unable to verify user credentials. Please try again",
 			"title": "Change Password",
@@ -572,7 +553,7 @@ func ChangePassword(c *gin.Context) {
 		})
 		return
 	}
-	user = &freshUser
+	user = freshUser
 
 	// Validate the old password
 	if err := user.CheckPassword(input.OldPassword); err != nil {
@@ -594,12 +575,9 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Fetch the last 5 password histories
-	var passwordHistories []models.PasswordHistory
-	if err := config.DB.Where("user_id = ?", user.ID).
-		Order("created_at DESC").
-		// Limit(5).
-		Find(&passwordHistories).Error; err != nil {
+	// Fetch the password history
+	passwordHistories, err := ac.users.PasswordHistory(user.ID)
+	if err != nil {
 		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
 			"error": "Unable to verify password history. Please try again",
 			"title": "Change Password",
@@ -618,13 +596,9 @@ func ChangePassword(c *gin.Context) {
         return
     }
 
-	// Begin a transaction
-	tx := config.DB.Begin()
-
 	// Update the user's password
 	user.Password = input.NewPassword
 	if err := user.HashPassword(); err != nil {
-		tx.Rollback()
 		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
 			"error": "An error occurred while securing your password. Please try again",
 			"title": "Change Password",
@@ -632,23 +606,9 @@ func ChangePassword(c *gin.Context) {
 		})
 		return
 	}
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
-			"error": "Unable to update your password. Please try again",
-			"title": "Change Password",
-			"user":  user,
-		})
-		return
-	}
 
-	// Add the old password to the password history
-	newHistory := models.PasswordHistory{
-		UserID:   user.ID,
-		Password: user.Password,
-	}
-	if err := tx.Create(&newHistory).Error; err != nil {
-		tx.Rollback()
+	// Save it and add it to the password history together
+	if err := ac.users.ChangePassword(user); err != nil {
 		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
 			"error": "Unable to update your password. Please try again",
 			"title": "Change Password",
@@ -657,17 +617,6 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.HTML(http.StatusInternalServerError, "change_password.html", gin.H{
-			"error": "Unable to complete the password update. Please try again",
-			"title": "Change Password",
-			"user":  user,
-		})
-		return
-	}
-
 	c.HTML(http.StatusOK, "change_password.html", gin.H{
 		"success": "Your password has been successfully updated",
 		"title":   "Change Password",
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/ratelimit"
+	"event-analytics/pkg/session"
+	"event-analytics/repository/memory"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogin(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+	unverified := &models.User{Username: "bob", Email: "bob@example.com", Password: "password123"}
+	require.NoError(t, unverified.HashPassword())
+	require.NoError(t, app.repos.Users.Create(unverified))
+
+	tests := []struct {
+		name       string
+		identifier string
+		password   string
+		wantStatus int
+	}{
+		{name: "username", identifier: "alice", password: "password123", wantStatus: http.StatusFound},
+		{name: "email", identifier: alice.Email, password: "password123", wantStatus: http.StatusFound},
+		{name: "wrong password", identifier: "alice", password: "password", wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", identifier: "carol", password: "password123", wantStatus: http.StatusUnauthorized},
+		{name: "unverified", identifier: "bob", password: "password123", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"identifier": {tt.identifier}, "password": {tt.password}}
+			w := app.do(t, nil, http.MethodPost, "/auth/login", form)
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusFound {
+				return
+			}
+
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "session_token", cookies[0].Name)
+
+			// The session opens protected routes
+			req := httptest.NewRequest(http.MethodPost, "/events/create", strings.NewReader(eventForm(tt.name).Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookies[0])
+			w = httptest.NewRecorder()
+			app.router.ServeHTTP(w, req)
+			assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRegisterRejectsTakenNames(t *testing.T) {
+	app := newTestApp(t)
+	app.user(t, "alice")
+
+	w := app.do(t, nil, http.MethodPost, "/auth/register", url.Values{
+		"username": {"alice"}, "email": {"new@example.com"}, "password": {"password123"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Username already taken")
+
+	w = app.do(t, nil, http.MethodPost, "/auth/register", url.Values{
+		"username": {"new"}, "email": {"alice@example.com"}, "password": {"password123"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Email already taken")
+}
+
+func TestLoginWithTwoFactorWaitsForTheCode(t *testing.T) {
+	app := newTestApp(t)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	pending, failures := config.TwoFactorPending, config.TwoFactorFailures
+	config.TwoFactorPending = session.NewPendingStore(client, 5*time.Minute, 5)
+	config.TwoFactorFailures = ratelimit.NewLimiter(client, "ratelimit:two-factor:", 10, 15*time.Minute)
+	t.Cleanup(func() { config.TwoFactorPending, config.TwoFactorFailures = pending, failures })
+
+	alice := app.user(t, "alice")
+	enabled := time.Now()
+	alice.TOTPSecret, alice.TOTPEnabledAt = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", &enabled
+	require.NoError(t, app.repos.Users.Update(alice))
+
+	w := app.do(t, nil, http.MethodPost, "/auth/login", url.Values{"identifier": {"alice"}, "password": {"password123"}})
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/auth/two-factor", w.Header().Get("Location"))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1, "no session yet")
+	assert.Equal(t, "pending_2fa", cookies[0].Name)
+	token, err := url.QueryUnescape(cookies[0].Value)
+	require.NoError(t, err)
+	userID, err := config.TwoFactorPending.Get(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID.String(), userID)
+	assert.Equal(t, 5*time.Minute, server.TTL("2fa:"+token))
+}
+
+func TestTwoFactorEnrolledRequiresEnrollment(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.user(t, "admin", models.RoleAdmin)
+	users := app.repos.Users.(*memory.UserRepository)
+
+	w := app.do(t, admin, http.MethodPost, "/events/create", eventForm("Talk"))
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"), "optional by default")
+
+	users.RequireTwoFactor(models.RoleAdmin, true)
+	w = app.do(t, admin, http.MethodPost, "/events/create", eventForm("Other talk"))
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/user/profile?error="), w.Header().Get("Location"))
+
+	enabled := time.Now()
+	admin.TOTPEnabledAt = &enabled
+	require.NoError(t, users.Update(admin))
+	w = app.do(t, admin, http.MethodPost, "/events/create", eventForm("Other talk"))
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"), "enrolled admins get through")
+}
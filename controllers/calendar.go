@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegenerateCalendarFeed issues a new calendar feed URL for the current
+// user, revoking the previous one
+func RegenerateCalendarFeed(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	token, err := services.GenerateCalendarFeedToken(user.ID)
+	if err != nil {
+		log.Printf("Calendar: failed to generate feed token for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error=Failed to generate calendar feed")
+		return
+	}
+
+	// The plain token is never stored, so hand the URL to the profile page once
+	feedURL := utils.GetBaseURL(c.Request) + "/calendar/" + token + ".ics"
+	c.SetCookie("calendar_feed_url", feedURL, 300, "/user/profile", "", false, true)
+	c.SetCookie("flash", "Calendar feed URL generated. Copy it now, it will not be shown again", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
+
+// RevokeCalendarFeed disables the current user's calendar feed URL
+func RevokeCalendarFeed(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	if err := services.RevokeCalendarFeed(user.ID); err != nil {
+		log.Printf("Calendar: failed to revoke feed for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error=Failed to revoke calendar feed")
+		return
+	}
+
+	c.SetCookie("flash", "Calendar feed revoked", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
@@ -15,15 +15,75 @@ import (
 	"os"
 
 	// "path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"event-analytics/config"
+	"event-analytics/repository"
+	"event-analytics/services"
 	"event-analytics/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 )
 
+var errTitleTaken = errors.New("event title must be unique")
+
+// EventController serves the event pages and the event API. It reads and
+// writes events and users only through its repositories.
+type EventController struct {
+	events repository.EventRepository
+	users  repository.UserRepository
+}
+
+func NewEventController(events repository.EventRepository, users repository.UserRepository) *EventController {
+	return &EventController{events: events, users: users}
+}
+
+// currentUser returns the user set by AuthRequired or APIAuthRequired
+func currentUser(c *gin.Context) *models.User {
+	user, _ := c.Get("user")
+	currentUser, _ := user.(*models.User)
+	return currentUser
+}
+
+// canManage reports whether user may edit and delete event, as its creator
+// or an admin
+func (ec *EventController) canManage(user *models.User, event *models.Event) bool {
+	if user == nil {
+		return false
+	}
+	return event.CreatedBy == user.ID || ec.isAdmin(user)
+}
+
+func (ec *EventController) isAdmin(user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	admin, err := ec.users.HasRole(user.ID, models.RoleAdmin)
+	if err != nil {
+		log.Printf("Failed to load roles of user %s: %v", user.ID, err)
+	}
+	return admin
+}
+
+// loadEvent loads the event in the URL. ok is false when there is no such
+// event; err is set for other failures.
+func (ec *EventController) loadEvent(c *gin.Context) (event *models.Event, ok bool, err error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, false, nil
+	}
+	event, err = ec.events.FindByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return event, true, nil
+}
+
 type EventInput struct {
     Title           string `form:"title" binding:"required"`
     Description     string `form:"description" binding:"required"`
@@ -32,12 +92,19 @@ type EventInput struct {
     Location        string `form:"location" binding:"required"`
     Status          string `form:"status" binding:"required,oneof=draft published"`
     PublishedDate   string `form:"published_date"`
+    Capacity        string `form:"capacity"`
+    Repeat          string `form:"repeat"`
+    RepeatInterval  string `form:"repeat_interval"`
+    RepeatDays      []string `form:"repeat_days"`
+    RepeatCount     string `form:"repeat_count"`
+    RepeatUntil     string `form:"repeat_until"`
+    RecurrenceRule  string `form:"recurrence_rule"`
 }
 
 // GetEvents retrieves all events and renders the dashboard
-func GetEvents(c *gin.Context) {
-	var events []models.Event
-	if err := config.DB.Find(&events).Error; err != nil {
+func (ec *EventController) GetEvents(c *gin.Context) {
+	events, _, err := ec.events.List(repository.EventFilter{})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch events"})
 		return
 	}
@@ -47,10 +114,9 @@ func GetEvents(c *gin.Context) {
 	})
 }
 
-func CreateEvent(c *gin.Context) {
-    user, err := utils.GetUserFromSession(c)
-    if err != nil {
-        log.Printf("Failed to retrieve user from session: %v", err)
+func (ec *EventController) CreateEvent(c *gin.Context) {
+    user := currentUser(c)
+    if user == nil {
         c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
         return
     }
@@ -61,46 +127,12 @@ func CreateEvent(c *gin.Context) {
         return
     }
 
-    // Check title uniqueness
-    var count int64
-    config.DB.Model(&models.Event{}).Where("title = ?", input.Title).Count(&count)
-    if count > 0 {
-        formData, _ := json.Marshal(input)
-        c.SetCookie("form_data", string(formData), 300, "/", "", false, true)
-        c.Redirect(http.StatusFound, "/events/new?error=Event title must be unique")
-        return
-    }
-
-    startTime, err := parseDateTime(input.StartTime)
-    if err != nil {
-        handleRedirectWithFormData(c, input, "Invalid start datetime format")
-        return
-    }
-
-    endTime, err := parseDateTime(input.EndTime)
+    event, err := eventFromInput(ec.events, input, user.ID)
     if err != nil {
-        handleRedirectWithFormData(c, input, "Invalid end datetime format")
-        return
-    }
-
-    if endTime.Before(startTime) {
-        handleRedirectWithFormData(c, input, "End datetime must be after start datetime")
+        handleRedirectWithFormData(c, input, userMessage(err))
         return
     }
 
-    var publishedDate *time.Time
-    if input.Status == "published" {
-        now := time.Now()
-        publishedDate = &now
-    } else if input.Status == "draft" && input.PublishedDate != "" {
-        parsedDate, err := parseDateTime(input.PublishedDate)
-        if err != nil {
-            handleRedirectWithFormData(c, input, "Invalid publish date format")
-            return
-        }
-        publishedDate = &parsedDate
-    }
-
     file, _ := c.FormFile("image")
     imagePath := ""
     if file != nil {
@@ -122,86 +154,117 @@ func CreateEvent(c *gin.Context) {
         }
     }
 
-    event := models.Event{
-        Title:        input.Title,
-        Description:  input.Description,
-        StartTime:    startTime,
-        EndTime:      endTime,
-        Location:     input.Location,
-        Image:        imagePath,
-        Status:       input.Status,
-        CreatedBy:    user.ID,
-        PublishedDate: publishedDate,
-    }
+    event.Image = imagePath
 
-    if err := config.DB.Create(&event).Error; err != nil {
+    if err := ec.events.Create(event); err != nil {
         handleRedirectWithFormData(c, input, "Failed to create event")
         return
     }
+    services.PublishEventChange(services.EventCreated, event)
 
     c.SetCookie("flash", "Event created successfully", 300, "/", "", false, true)
     c.Redirect(http.StatusFound, "/user/dashboard")
 }
 
-func UpdateEvent(c *gin.Context) {
-    user, err := utils.GetUserFromSession(c)
+// eventFromInput applies the CreateEvent rules to bound input and builds the
+// event to insert. Errors carry the message to show the user, through
+// userMessage.
+func eventFromInput(events repository.EventRepository, input EventInput, createdBy uuid.UUID) (*models.Event, error) {
+    taken, err := events.TitleTaken(input.Title, uuid.Nil)
     if err != nil {
-        c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
-        return
+        log.Printf("Failed to check event title: %v", err)
+        return nil, errors.New("failed to create event")
     }
-
-    eventID := c.Param("id")
-    var existingEvent models.Event
-    if err := config.DB.First(&existingEvent, "id = ?", eventID).Error; err != nil {
-        c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
-        return
+    if taken {
+        return nil, errTitleTaken
     }
 
-    if !utils.IsAdminOrOwner(user, existingEvent) {
-        c.Redirect(http.StatusFound, "/user/dashboard?error=Permission denied")
-        return
+    startTime, err := parseDateTime(input.StartTime)
+    if err != nil {
+        return nil, errors.New("invalid start datetime format")
     }
 
-    var input EventInput
-    if err := c.ShouldBind(&input); err != nil {
-        handleRedirectWithFormData(c, input, fmt.Sprintf("/events/edit/%s?error=Invalid input", eventID))
-        return
+    endTime, err := parseDateTime(input.EndTime)
+    if err != nil {
+        return nil, errors.New("invalid end datetime format")
     }
 
-    var count int64
-    config.DB.Model(&models.Event{}).Where("title = ? AND id != ?", input.Title, eventID).Count(&count)
-    if count > 0 {
-        c.Redirect(http.StatusFound, fmt.Sprintf("/events/edit/%s?error=Event title must be unique", eventID))
-        return
+    if endTime.Before(startTime) {
+        return nil, errors.New("end datetime must be after start datetime")
     }
 
-    startTime, err := parseDateTime(input.StartTime)
+    capacity, err := parseCapacity(input.Capacity)
     if err != nil {
-        handleRedirectWithFormData(c, input, "Invalid start datetime format")
-        return
+        return nil, errors.New("capacity must be a whole number greater than zero")
     }
 
-    endTime, err := parseDateTime(input.EndTime)
+    recurrenceRule, err := buildRecurrenceRule(input, startTime)
     if err != nil {
-        handleRedirectWithFormData(c, input, "Invalid end datetime format")
-        return
-    }
-
-    if endTime.Before(startTime) {
-        handleRedirectWithFormData(c, input, "End datetime must be after start datetime")
-        return
+        return nil, err
     }
 
+    var publishedDate *time.Time
     if input.Status == "published" {
         now := time.Now()
-        existingEvent.PublishedDate = &now
+        publishedDate = &now
     } else if input.Status == "draft" && input.PublishedDate != "" {
         parsedDate, err := parseDateTime(input.PublishedDate)
         if err != nil {
-            handleRedirectWithFormData(c, input, "Invalid publish date format")
-            return
+            return nil, errors.New("invalid publish date format")
+        }
+        publishedDate = &parsedDate
+    }
+
+    event := &models.Event{
+        Title:         input.Title,
+        Description:   input.Description,
+        StartTime:     startTime,
+        EndTime:       endTime,
+        Location:      input.Location,
+        Status:        input.Status,
+        CreatedBy:     createdBy,
+        PublishedDate: publishedDate,
+        Capacity:      capacity,
+    }
+
+    if err := services.ApplyRecurrence(event, recurrenceRule); err != nil {
+        return nil, errors.New("invalid recurrence rule: " + err.Error())
+    }
+    return event, nil
+}
+
+func (ec *EventController) UpdateEvent(c *gin.Context) {
+    user := currentUser(c)
+    if user == nil {
+        c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+        return
+    }
+
+    eventID := c.Param("id")
+    existingEvent, ok, err := ec.loadEvent(c)
+    if !ok {
+        if err != nil {
+            log.Printf("Failed to load event %s: %v", eventID, err)
         }
-        existingEvent.PublishedDate = &parsedDate
+        c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+        return
+    }
+
+    if !ec.canManage(user, existingEvent) {
+        c.Redirect(http.StatusFound, "/user/dashboard?error=Permission denied")
+        return
+    }
+
+    var input EventInput
+    if err := c.ShouldBind(&input); err != nil {
+        handleRedirectWithFormData(c, input, fmt.Sprintf("/events/edit/%s?error=Invalid input", eventID))
+        return
+    }
+
+    changes, err := applyEventUpdate(ec.events, existingEvent, input)
+    if err != nil {
+        c.Redirect(http.StatusFound, fmt.Sprintf("/events/edit/%s?error=%s", eventID, url.QueryEscape(userMessage(err))))
+        return
     }
 
     // Handle image upload
@@ -228,45 +291,143 @@ func UpdateEvent(c *gin.Context) {
         existingEvent.Image = imagePath
     }
 
-    existingEvent.Title = input.Title
-    existingEvent.Description = input.Description
-    existingEvent.StartTime = startTime
-    existingEvent.EndTime = endTime
-    existingEvent.Location = input.Location
-    existingEvent.Status = input.Status
-
-    if err := config.DB.Save(&existingEvent).Error; err != nil {
+    if err := ec.events.Update(existingEvent); err != nil {
         handleRedirectWithFormData(c, input, "Failed to update event")
         return
     }
 
+    afterEventUpdate(c, existingEvent, changes)
+
     c.SetCookie("flash", "Event updated successfully", 300, "/", "", false, true)
     c.Redirect(http.StatusFound, "/user/dashboard")
 }
 
-func DeleteEvent(c *gin.Context) {
-    user, err := utils.GetUserFromSession(c)
+// eventChanges are the edits to an event that need more than a save
+type eventChanges struct {
+    schedule bool // Occurrence overrides are keyed by the old schedule
+    capacity bool // More seats may be free for the waitlist
+}
+
+// applyEventUpdate validates input with the UpdateEvent rules and copies it
+// onto event without saving
+func applyEventUpdate(events repository.EventRepository, event *models.Event, input EventInput) (eventChanges, error) {
+    taken, err := events.TitleTaken(input.Title, event.ID)
     if err != nil {
-        c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
-        return
+        log.Printf("Failed to check event title: %v", err)
+        return eventChanges{}, errors.New("failed to update event")
+    }
+    if taken {
+        return eventChanges{}, errTitleTaken
     }
 
-    // Get the event ID from the route parameter
-    eventID := c.Param("id")
+    startTime, err := parseDateTime(input.StartTime)
+    if err != nil {
+        return eventChanges{}, errors.New("invalid start datetime format")
+    }
+
+    endTime, err := parseDateTime(input.EndTime)
+    if err != nil {
+        return eventChanges{}, errors.New("invalid end datetime format")
+    }
+
+    if endTime.Before(startTime) {
+        return eventChanges{}, errors.New("end datetime must be after start datetime")
+    }
+
+    capacity, err := parseCapacity(input.Capacity)
+    if err != nil {
+        return eventChanges{}, errors.New("capacity must be a whole number greater than zero")
+    }
+
+    recurrenceRule, err := buildRecurrenceRule(input, startTime)
+    if err != nil {
+        return eventChanges{}, err
+    }
+
+    publishedDate := event.PublishedDate
+    if input.Status == "published" {
+        now := time.Now()
+        publishedDate = &now
+    } else if input.Status == "draft" && input.PublishedDate != "" {
+        parsedDate, err := parseDateTime(input.PublishedDate)
+        if err != nil {
+            return eventChanges{}, errors.New("invalid publish date format")
+        }
+        publishedDate = &parsedDate
+    }
+
+    updated := *event
+    updated.Title = input.Title
+    updated.Description = input.Description
+    updated.StartTime = startTime
+    updated.EndTime = endTime
+    updated.Location = input.Location
+    updated.Status = input.Status
+    updated.Capacity = capacity
+    updated.PublishedDate = publishedDate
+
+    if err := services.ApplyRecurrence(&updated, recurrenceRule); err != nil {
+        return eventChanges{}, errors.New("invalid recurrence rule: " + err.Error())
+    }
+
+    changes := eventChanges{
+        schedule: !event.StartTime.Equal(updated.StartTime) || event.RecurrenceRule != updated.RecurrenceRule,
+        capacity: !equalCapacity(event.Capacity, updated.Capacity),
+    }
+    *event = updated
+    return changes, nil
+}
+
+// equalCapacity compares optional capacities, nil being unlimited
+func equalCapacity(a, b *int) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return *a == *b
+}
+
+// afterEventUpdate runs the side effects of saving an edited event
+func afterEventUpdate(c *gin.Context, event *models.Event, changes eventChanges) {
+    if changes.schedule {
+        if err := services.ClearOverrides(event.ID); err != nil {
+            log.Printf("Failed to clear occurrence overrides for event %s: %v", event.ID, err)
+        }
+    }
+
+    services.TrackEventAction(c, event, models.ActionEdit, "")
+    services.PublishEventChange(services.EventUpdated, event)
 
-    // Fetch the event from the database
-    var event models.Event
-    if err := config.DB.First(&event, "id = ?", eventID).Error; err != nil {
-        if errors.Is(err, gorm.ErrRecordNotFound) {
-            c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
-        } else {
-            c.Redirect(http.StatusFound, "/user/dashboard?error=Error fetching event")
+    // A raised or removed capacity may free seats for waitlisted attendees
+    if changes.capacity {
+        promoted, err := services.FillFreedSeats(event.ID, queuePromotionEmails(c))
+        if err != nil {
+            log.Printf("Failed to promote waitlisted attendees for event %s: %v", event.ID, err)
+        }
+        if len(promoted) > 0 {
+            config.Outbox.Notify()
         }
+    }
+}
+
+func (ec *EventController) DeleteEvent(c *gin.Context) {
+    user := currentUser(c)
+    if user == nil {
+        c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+        return
+    }
+
+    // Fetch the event from the route parameter
+    event, ok, err := ec.loadEvent(c)
+    if err != nil {
+        c.Redirect(http.StatusFound, "/user/dashboard?error=Error fetching event")
+        return
+    } else if !ok {
+        c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
         return
     }
 
-    // Check permission using utility function
-    if !utils.IsAdminOrOwner(user, event) {
+    // Check permission
+    if !ec.canManage(user, event) {
         c.Redirect(http.StatusFound, fmt.Sprintf("/user/dashboard?error=Permission denied for event"))
         return
     }
@@ -282,16 +443,28 @@ func DeleteEvent(c *gin.Context) {
     } */
 
     // Delete the event
-    if err := config.DB.Delete(&event).Error; err != nil {
+    if err := ec.events.Delete(event.ID); err != nil {
         c.Redirect(http.StatusFound, fmt.Sprintf("/user/dashboard?error=Failed to delete event"))
         return
     }
 
+    services.TrackEventAction(c, event, models.ActionDelete, "")
+    services.PublishEventChange(services.EventDeleted, event)
+
     // Success message via flash cookie
     c.SetCookie("flash", "Event deleted successfully", 300, "/", "", false, true)
     c.Redirect(http.StatusFound, "/user/dashboard")
 }
 
+// userMessage turns a validation error into the sentence shown to the user
+func userMessage(err error) string {
+    msg := err.Error()
+    if msg == "" {
+        return msg
+    }
+    return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
 // Helper function to handle redirects with form data
 func handleRedirectWithFormData(c *gin.Context, input EventInput, errorMessage string) {
     formData, _ := json.Marshal(input)
@@ -303,4 +476,79 @@ func handleRedirectWithFormData(c *gin.Context, input EventInput, errorMessage s
 func parseDateTime(datetimeStr string) (time.Time, error) {
     const datetimeFormat = "2006-01-02T15:04"
     return time.Parse(datetimeFormat, datetimeStr)
-}
\ No newline at end of file
+}
+
+// Function to parse an optional capacity; empty means unlimited
+func parseCapacity(capacityStr string) (*int, error) {
+    if strings.TrimSpace(capacityStr) == "" {
+        return nil, nil
+    }
+    capacity, err := strconv.Atoi(strings.TrimSpace(capacityStr))
+    if err != nil || capacity < 1 {
+        return nil, errors.New("invalid capacity")
+    }
+    return &capacity, nil
+}
+
+// Function to build an RRULE from the repeat fields of the event form. The
+// raw recurrence_rule field is used when no repeat frequency is selected.
+func buildRecurrenceRule(input EventInput, start time.Time) (string, error) {
+    if input.Repeat == "" {
+        return strings.TrimSpace(input.RecurrenceRule), nil
+    }
+
+    var parts []string
+    switch input.Repeat {
+    case "daily":
+        parts = append(parts, "FREQ=DAILY")
+    case "weekly":
+        parts = append(parts, "FREQ=WEEKLY")
+        if len(input.RepeatDays) > 0 {
+            parts = append(parts, "BYDAY="+strings.Join(input.RepeatDays, ","))
+        }
+    case "monthly":
+        parts = append(parts, "FREQ=MONTHLY")
+    case "monthly_weekday":
+        // Same weekday of the month as the start, e.g. the 2nd Tuesday
+        nth := (start.Day()-1)/7 + 1
+        if nth == 5 {
+            nth = -1
+        }
+        day := strings.ToUpper(start.Weekday().String()[:2])
+        parts = append(parts, "FREQ=MONTHLY", fmt.Sprintf("BYDAY=%d%s", nth, day))
+    default:
+        return "", errors.New("invalid repeat frequency")
+    }
+
+    if interval := strings.TrimSpace(input.RepeatInterval); interval != "" {
+        n, err := strconv.Atoi(interval)
+        if err != nil || n < 1 {
+            return "", errors.New("repeat interval must be a whole number greater than zero")
+        }
+        if n > 1 {
+            parts = append(parts, "INTERVAL="+strconv.Itoa(n))
+        }
+    }
+
+    count := strings.TrimSpace(input.RepeatCount)
+    until := strings.TrimSpace(input.RepeatUntil)
+    if count != "" && until != "" {
+        return "", errors.New("choose either a number of occurrences or an end date, not both")
+    }
+    if count != "" {
+        n, err := strconv.Atoi(count)
+        if err != nil || n < 1 {
+            return "", errors.New("number of occurrences must be a whole number greater than zero")
+        }
+        parts = append(parts, "COUNT="+strconv.Itoa(n))
+    }
+    if until != "" {
+        untilDate, err := time.Parse("2006-01-02", until)
+        if err != nil || untilDate.Before(start.Truncate(24*time.Hour)) {
+            return "", errors.New("repeat end date must be on or after the start date")
+        }
+        parts = append(parts, "UNTIL="+untilDate.Add(24*time.Hour-time.Second).Format("20060102T150405Z"))
+    }
+
+    return strings.Join(parts, ";"), nil
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/middlewares"
+	"event-analytics/models"
+	"event-analytics/pkg/session"
+	"event-analytics/repository"
+	"event-analytics/repository/memory"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testApp is a router over in-memory repositories and sessions, so the
+// controllers run without PostgreSQL or Redis
+type testApp struct {
+	router *gin.Engine
+	repos  *repository.Repositories
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sessions := config.SessionStore
+	config.SessionStore = session.NewMemoryStore(time.Hour)
+	t.Cleanup(func() { config.SessionStore = sessions })
+
+	repos := memory.New()
+	events := NewEventController(repos.Events, repos.Users)
+	auth := NewAuthController(repos.Users)
+	outbox := NewOutboxController(repos.Outbox)
+
+	r := gin.New()
+	display := func(t time.Time) string { return t.Format("2006-01-02T15:04") }
+	r.SetFuncMap(template.FuncMap{"formatDate": display, "formatDatetime": display, "formatDisplay": display})
+	r.LoadHTMLGlob("../templates/*.html")
+	r.Use(middlewares.UserMiddleware(repos.Users))
+
+	r.POST("/auth/login", auth.Login)
+	r.POST("/auth/register", auth.Register)
+	protected := r.Group("/events", middlewares.AuthRequired(repos.Users), middlewares.TwoFactorEnrolled(repos.Users))
+	protected.POST("/create", events.CreateEvent)
+	protected.POST("/update/:id", events.UpdateEvent)
+	protected.POST("/delete/:id", events.DeleteEvent)
+	admin := r.Group("/admin", middlewares.AuthRequired(repos.Users), middlewares.RequireRole(repos.Users, models.RoleAdmin))
+	admin.POST("/outbox/:id/requeue", outbox.RequeueOutboxMessage)
+	api := r.Group("/api/v1", middlewares.APIAuthRequired(repos.Users))
+	api.GET("/events/:id", events.APIGetEvent)
+	api.DELETE("/events/:id", events.APIDeleteEvent)
+
+	return &testApp{router: r, repos: repos}
+}
+
+// user creates a verified user with password "password123"
+func (app *testApp) user(t *testing.T, name string, roles ...string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", Password: "password123", IsVerified: true}
+	require.NoError(t, user.HashPassword())
+	require.NoError(t, app.repos.Users.Create(user, roles...))
+	return user
+}
+
+func (app *testApp) event(t *testing.T, owner *models.User, title, status string) *models.Event {
+	t.Helper()
+	start := time.Date(2030, 5, 1, 18, 0, 0, 0, time.UTC)
+	event := &models.Event{
+		Title:       title,
+		Description: "Monthly talk",
+		StartTime:   start,
+		EndTime:     start.Add(2 * time.Hour),
+		Location:    "Hall A",
+		Status:      status,
+		CreatedBy:   owner.ID,
+	}
+	require.NoError(t, app.repos.Events.Create(event))
+	return event
+}
+
+// do sends a request, logged in as user unless it is nil
+func (app *testApp) do(t *testing.T, user *models.User, method, path string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if user != nil {
+		token, err := config.SessionStore.Create(context.Background(), user.ID.String())
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+	}
+	w := httptest.NewRecorder()
+	app.router.ServeHTTP(w, req)
+	return w
+}
+
+func eventForm(title string) url.Values {
+	return url.Values{
+		"title":       {title},
+		"description": {"Monthly talk"},
+		"start_time":  {"2030-05-01T18:00"},
+		"end_time":    {"2030-05-01T20:00"},
+		"location":    {"Hall A"},
+		"status":      {"published"},
+	}
+}
+
+func TestCreateEventSavesThroughRepository(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+
+	w := app.do(t, alice, http.MethodPost, "/events/create", eventForm("Go meetup"))
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+
+	events, total, err := app.repos.Events.List(repository.EventFilter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, "Go meetup", events[0].Title)
+	assert.Equal(t, alice.ID, events[0].CreatedBy)
+	assert.NotNil(t, events[0].PublishedDate)
+}
+
+func TestCreateEventRejectsTakenTitle(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+	app.event(t, alice, "Go meetup", "published")
+
+	w := app.do(t, alice, http.MethodPost, "/events/create", eventForm("Go meetup"))
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Contains(t, w.Header().Get("Location"), "error=Event+title+must+be+unique")
+}
+
+func TestCreateEventRequiresSession(t *testing.T) {
+	app := newTestApp(t)
+
+	w := app.do(t, nil, http.MethodPost, "/events/create", eventForm("Go meetup"))
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Contains(t, w.Header().Get("Location"), "/auth/login")
+}
+
+func TestUpdateEventPermissions(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+	bob := app.user(t, "bob")
+	admin := app.user(t, "admin", models.RoleAdmin)
+	event := app.event(t, alice, "Go meetup", "draft")
+
+	form := eventForm("Go meetup, renamed")
+	w := app.do(t, bob, http.MethodPost, "/events/update/"+event.ID.String(), form)
+	assert.Contains(t, w.Header().Get("Location"), "error=Permission denied")
+
+	w = app.do(t, admin, http.MethodPost, "/events/update/"+event.ID.String(), form)
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+
+	updated, err := app.repos.Events.FindByID(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Go meetup, renamed", updated.Title)
+	assert.Equal(t, "published", updated.Status)
+}
+
+func TestDeleteEvent(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+	bob := app.user(t, "bob")
+	event := app.event(t, alice, "Go meetup", "published")
+
+	w := app.do(t, bob, http.MethodPost, "/events/delete/"+event.ID.String(), nil)
+	assert.Contains(t, w.Header().Get("Location"), "error=Permission denied")
+
+	w = app.do(t, alice, http.MethodPost, "/events/delete/"+event.ID.String(), nil)
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+	_, err := app.repos.Events.FindByID(event.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	w = app.do(t, alice, http.MethodPost, "/events/delete/"+event.ID.String(), nil)
+	assert.Contains(t, w.Header().Get("Location"), "error=Event not found")
+}
+
+func TestAPIHidesOtherUsersDrafts(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.user(t, "alice")
+	bob := app.user(t, "bob")
+	draft := app.event(t, alice, "Go meetup", "draft")
+
+	w := app.do(t, bob, http.MethodGet, "/api/v1/events/"+draft.ID.String(), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = app.do(t, bob, http.MethodDelete, "/api/v1/events/"+draft.ID.String(), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = app.do(t, alice, http.MethodDelete, "/api/v1/events/"+draft.ID.String(), nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
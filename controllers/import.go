@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/pkg/ical"
+	"event-analytics/repository"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 1000
+)
+
+// csvImportColumns are the CSV headers read on import, named after the
+// event form fields. The first five are required.
+var csvImportColumns = []string{"title", "description", "start_time", "end_time", "location", "status", "published_date", "capacity", "recurrence_rule"}
+
+// ImportRow is one event read from an uploaded file
+type ImportRow struct {
+	Line    int         `json:"line"` // Line in the file, for error messages
+	Input   EventInput  `json:"input"`
+	ExDates []time.Time `json:"exdates,omitempty"` // Cancelled occurrences of an imported series
+	Error   string      `json:"error,omitempty"`   // Empty when the row can be imported
+}
+
+// PreviewImport parses an uploaded .ics or .csv file and shows every row
+// with the errors that would stop it from being imported
+func (ec *EventController) PreviewImport(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.Redirect(http.StatusFound, "/events/import?error=Please choose a file to import")
+		return
+	}
+	if file.Size > maxImportSize {
+		c.Redirect(http.StatusFound, "/events/import?error=File must be smaller than 5MB")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.Redirect(http.StatusFound, "/events/import?error=Failed to read file")
+		return
+	}
+	defer f.Close()
+
+	rows, err := parseImportFile(file.Filename, f)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/events/import?error="+url.QueryEscape(userMessage(err)))
+		return
+	}
+
+	validateImportRows(ec.events, rows, user.ID)
+
+	var valid []ImportRow
+	for _, row := range rows {
+		if row.Error == "" {
+			valid = append(valid, row)
+		}
+	}
+	payload, _ := json.Marshal(valid)
+
+	c.HTML(http.StatusOK, "event_import.html", gin.H{
+		"title":      "Import Events",
+		"user":       user,
+		"filename":   file.Filename,
+		"rows":       rows,
+		"validCount": len(valid),
+		"payload":    string(payload),
+		"csrf_token": c.GetString("csrf_token"),
+	})
+}
+
+// ImportCommitInput carries the rows accepted on the preview page
+type ImportCommitInput struct {
+	Rows string `form:"rows"` // JSON array of ImportRow
+}
+
+// CommitImport creates the previewed events in a single transaction. Rows
+// are validated again, since other events may have been created meanwhile.
+func (ec *EventController) CommitImport(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	var input ImportCommitInput
+	var rows []ImportRow
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, "/events/import?error=Nothing to import")
+		return
+	}
+	if err := json.Unmarshal([]byte(input.Rows), &rows); err != nil || len(rows) == 0 {
+		c.Redirect(http.StatusFound, "/events/import?error=Nothing to import")
+		return
+	}
+	if len(rows) > maxImportRows {
+		c.Redirect(http.StatusFound, "/events/import?error=Too many rows to import")
+		return
+	}
+
+	var created []models.Event
+	err := ec.events.Transaction(func(events repository.EventRepository, tx *gorm.DB) error {
+		// Each insert is visible to the next row's title check within the transaction
+		for i := range rows {
+			event, err := importEvent(events, &rows[i], user.ID)
+			if err != nil {
+				rows[i].Error = userMessage(err)
+				continue
+			}
+			if err := events.Create(event); err != nil {
+				return err
+			}
+			for _, exdate := range rows[i].ExDates {
+				override := models.EventOccurrenceOverride{EventID: event.ID, OccurrenceStart: exdate, Cancelled: true}
+				if err := tx.Create(&override).Error; err != nil {
+					return err
+				}
+			}
+			created = append(created, *event)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Import: failed to import events: %v", err)
+		c.Redirect(http.StatusFound, "/events/import?error=Failed to import events, nothing was saved")
+		return
+	}
+	services.PublishEventChanges(services.EventCreated, created)
+
+	imported := len(created)
+
+	flash := fmt.Sprintf("Imported %d events", imported)
+	if skipped := len(rows) - imported; skipped > 0 {
+		flash += fmt.Sprintf(", %d skipped because they are no longer valid", skipped)
+	}
+	c.SetCookie("flash", flash, 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/dashboard")
+}
+
+// validateImportRows records the error of every row that would fail to
+// import, including titles repeated within the file
+func validateImportRows(events repository.EventRepository, rows []ImportRow, createdBy uuid.UUID) {
+	seen := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			continue
+		}
+		if _, err := importEvent(events, row, createdBy); err != nil {
+			row.Error = userMessage(err)
+			continue
+		}
+		if line, ok := seen[row.Input.Title]; ok {
+			row.Error = fmt.Sprintf("Event title must be unique (also used on line %d)", line)
+			continue
+		}
+		seen[row.Input.Title] = row.Line
+	}
+}
+
+// importEvent applies the same checks as the create form to one row
+func importEvent(events repository.EventRepository, row *ImportRow, createdBy uuid.UUID) (*models.Event, error) {
+	if row.Error != "" {
+		return nil, errors.New(row.Error)
+	}
+	if err := binding.Validator.ValidateStruct(&row.Input); err != nil {
+		return nil, errors.New(describeBindingError(err))
+	}
+	return eventFromInput(events, row.Input, createdBy)
+}
+
+// fieldLabels names the form fields in the messages shown to users
+var fieldLabels = map[string]string{
+	"start_time":      "start time",
+	"end_time":        "end time",
+	"published_date":  "publish date",
+	"recurrence_rule": "recurrence rule",
+}
+
+// fieldLabel returns the readable name of a form field
+func fieldLabel(name string) string {
+	if label, ok := fieldLabels[name]; ok {
+		return label
+	}
+	return name
+}
+
+// describeBindingError names the form fields that failed validation
+func describeBindingError(err error) string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return "Please fill all required fields correctly"
+	}
+
+	inputType := reflect.TypeOf(EventInput{})
+	var fields []string
+	for _, fe := range errs {
+		name := fe.Field()
+		if field, ok := inputType.FieldByName(fe.StructField()); ok {
+			name = field.Tag.Get("form")
+		}
+		if fe.Tag() == "required" {
+			fields = append(fields, fieldLabel(name)+" is required")
+		} else {
+			fields = append(fields, fieldLabel(name)+" is invalid")
+		}
+	}
+	return strings.Join(fields, ", ")
+}
+
+// parseImportFile reads rows from a file, choosing the format by extension
+func parseImportFile(filename string, r io.Reader) ([]ImportRow, error) {
+	var (
+		rows []ImportRow
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ics", ".ical", ".ifb":
+		rows, err = parseICSImport(r)
+	case ".csv":
+		rows, err = parseCSVImport(r)
+	default:
+		return nil, errors.New("only .ics and .csv files can be imported")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file does not contain any events")
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("a file can contain at most %d events", maxImportRows)
+	}
+	return rows, nil
+}
+
+// parseCSVImport reads a CSV file with a header row naming csvImportColumns
+func parseCSVImport(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("the CSV file is empty or malformed")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range csvImportColumns[:5] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV file is missing the %q column", name)
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("the CSV file is malformed on line %d", parseErr.Line)
+			}
+			return nil, errors.New("the CSV file is malformed")
+		}
+		line, _ := reader.FieldPos(0)
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := ImportRow{Line: line, Input: EventInput{
+			Title:          get("title"),
+			Description:    get("description"),
+			StartTime:      normalizeImportDateTime(get("start_time")),
+			EndTime:        normalizeImportDateTime(get("end_time")),
+			Location:       get("location"),
+			Status:         strings.ToLower(get("status")),
+			PublishedDate:  normalizeImportDateTime(get("published_date")),
+			Capacity:       get("capacity"),
+			RecurrenceRule: get("recurrence_rule"),
+		}}
+		if row.Input.Status == "" {
+			row.Input.Status = "draft"
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// normalizeImportDateTime accepts "2006-01-02 15:04" and RFC 3339 values
+// besides the form's own format
+func normalizeImportDateTime(value string) string {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format("2006-01-02T15:04")
+	}
+	if len(value) == len("2006-01-02 15:04") && value[10] == ' ' {
+		return value[:10] + "T" + value[11:]
+	}
+	return value
+}
+
+// parseICSImport reads the VEVENTs of an iCalendar file
+func parseICSImport(r io.Reader) ([]ImportRow, error) {
+	components, err := ical.ParseEvents(r)
+	if err != nil {
+		return nil, errors.New("the iCalendar file is malformed: " + err.Error())
+	}
+
+	rows := make([]ImportRow, 0, len(components))
+	for _, vevent := range components {
+		rows = append(rows, icsImportRow(vevent))
+	}
+	return rows, nil
+}
+
+// icsImportRow maps a VEVENT onto the event form fields. All times are
+// converted to UTC, which is how the app stores them.
+func icsImportRow(vevent ical.Component) ImportRow {
+	row := ImportRow{Line: vevent.Line, Input: EventInput{
+		Title:          vevent.Text("SUMMARY"),
+		Description:    vevent.Text("DESCRIPTION"),
+		Location:       vevent.Text("LOCATION"),
+		Status:         "draft",
+		RecurrenceRule: vevent.Text("RRULE"),
+	}}
+
+	if vevent.Get("RECURRENCE-ID") != nil {
+		row.Error = "Changes to single occurrences of a series are not imported"
+		return row
+	}
+
+	switch strings.ToUpper(vevent.Text("STATUS")) {
+	case ical.StatusConfirmed:
+		row.Input.Status = "published"
+	case ical.StatusCancelled:
+		row.Error = "Cancelled events are not imported"
+		return row
+	}
+
+	dtstart := vevent.Get("DTSTART")
+	if dtstart == nil {
+		row.Error = "Start time is required"
+		return row
+	}
+	start, dateOnly, err := dtstart.Time()
+	if err != nil {
+		row.Error = "Invalid start datetime format"
+		return row
+	}
+
+	end := start
+	if dateOnly {
+		end = start.AddDate(0, 0, 1)
+	}
+	if dtend := vevent.Get("DTEND"); dtend != nil {
+		if end, _, err = dtend.Time(); err != nil {
+			row.Error = "Invalid end datetime format"
+			return row
+		}
+	} else if duration := vevent.Get("DURATION"); duration != nil {
+		d, err := ical.ParseDuration(duration.Value)
+		if err != nil {
+			row.Error = "Invalid event duration"
+			return row
+		}
+		end = start.Add(d)
+	}
+
+	row.Input.StartTime = start.UTC().Format("2006-01-02T15:04")
+	row.Input.EndTime = end.UTC().Format("2006-01-02T15:04")
+
+	for _, prop := range vevent.Properties {
+		if prop.Name != "EXDATE" {
+			continue
+		}
+		for _, value := range strings.Split(prop.Value, ",") {
+			exdate := ical.Property{Name: prop.Name, Params: prop.Params, Value: value}
+			if t, _, err := exdate.Time(); err == nil {
+				// Occurrences start on the minute, like the event itself
+				row.ExDates = append(row.ExDates, t.UTC().Truncate(time.Minute))
+			}
+		}
+	}
+	return row
+}
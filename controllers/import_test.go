@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSVImport(t *testing.T) {
+	input := "Title,Description,Start_Time,End_Time,Location,Status,Capacity\n" +
+		"Go meetup,Monthly talk,2024-05-01 18:00,2024-05-01 20:00,Hall A,published,50\n" +
+		"\"Workshop, part 2\",Hands on,2024-05-02T09:00:00+01:00,2024-05-02T12:00:00+01:00,Lab,,\n"
+
+	rows, err := parseImportFile("events.csv", strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, "Go meetup", rows[0].Input.Title)
+	assert.Equal(t, "2024-05-01T18:00", rows[0].Input.StartTime)
+	assert.Equal(t, "published", rows[0].Input.Status)
+	assert.Equal(t, "50", rows[0].Input.Capacity)
+
+	assert.Equal(t, "Workshop, part 2", rows[1].Input.Title)
+	assert.Equal(t, "2024-05-02T08:00", rows[1].Input.StartTime)
+	assert.Equal(t, "draft", rows[1].Input.Status)
+}
+
+func TestParseCSVImportMissingColumn(t *testing.T) {
+	_, err := parseImportFile("events.csv", strings.NewReader("title,start_time\nx,y\n"))
+	assert.EqualError(t, err, `the CSV file is missing the "description" column`)
+}
+
+func TestParseICSImport(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Standup",
+		"DESCRIPTION:Daily sync",
+		"LOCATION:Room 1",
+		"DTSTART:20240506T090000Z",
+		"DURATION:PT15M",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"EXDATE:20240508T090000Z",
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Offsite",
+		"DTSTART;VALUE=DATE:20240601",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Old",
+		"DTSTART:20240101T090000Z",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	rows, err := parseImportFile("calendar.ics", strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	standup := rows[0]
+	assert.Empty(t, standup.Error)
+	assert.Equal(t, "2024-05-06T09:00", standup.Input.StartTime)
+	assert.Equal(t, "2024-05-06T09:15", standup.Input.EndTime)
+	assert.Equal(t, "published", standup.Input.Status)
+	assert.Equal(t, "FREQ=DAILY;COUNT=5", standup.Input.RecurrenceRule)
+	assert.Equal(t, []time.Time{time.Date(2024, 5, 8, 9, 0, 0, 0, time.UTC)}, standup.ExDates)
+
+	offsite := rows[1]
+	assert.Equal(t, "2024-06-01T00:00", offsite.Input.StartTime)
+	assert.Equal(t, "2024-06-02T00:00", offsite.Input.EndTime)
+	assert.Equal(t, "draft", offsite.Input.Status)
+
+	assert.Equal(t, "Cancelled events are not imported", rows[2].Error)
+}
+
+func TestParseImportFileRejectsOtherFormats(t *testing.T) {
+	_, err := parseImportFile("events.xlsx", strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestDescribeBindingError(t *testing.T) {
+	input := EventInput{Title: "Offsite", StartTime: "2024-06-01T00:00", EndTime: "2024-06-02T00:00", Status: "cancelled"}
+	err := binding.Validator.ValidateStruct(&input)
+	require.Error(t, err)
+	assert.Equal(t, "description is required, location is required, status is invalid", describeBindingError(err))
+
+	input = EventInput{Title: "Offsite", Description: "Planning", Location: "Hall A", Status: "draft"}
+	err = binding.Validator.ValidateStruct(&input)
+	require.Error(t, err)
+	assert.Equal(t, "Start time is required, end time is required", userMessage(errors.New(describeBindingError(err))))
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"event-analytics/config"
+	"event-analytics/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OutboxController serves the admin actions on the email outbox
+type OutboxController struct {
+	outbox repository.OutboxRepository
+}
+
+func NewOutboxController(outbox repository.OutboxRepository) *OutboxController {
+	return &OutboxController{outbox: outbox}
+}
+
+// RequeueOutboxMessage makes a pending email, or a dead one that still has
+// its body, due now with a fresh set of attempts
+func (oc *OutboxController) RequeueOutboxMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/admin/outbox?error="+url.QueryEscape("Message not found"))
+		return
+	}
+
+	if err := oc.outbox.Requeue(uint(id)); err != nil {
+		message := "Failed to requeue the message"
+		if errors.Is(err, repository.ErrNotFound) {
+			message = "Message not found, already sent or no longer has a body"
+		} else {
+			log.Printf("Outbox: failed to requeue message %d: %v", id, err)
+		}
+		c.Redirect(http.StatusFound, "/admin/outbox?error="+url.QueryEscape(message))
+		return
+	}
+	config.Outbox.Notify()
+
+	c.SetCookie("flash", "Message requeued", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/admin/outbox")
+}
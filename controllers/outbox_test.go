@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"event-analytics/models"
+	"event-analytics/repository/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequeueOutboxMessage(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.user(t, "admin", models.RoleAdmin)
+	messages := app.repos.Outbox.(*memory.OutboxRepository)
+	messages.Add(models.OutboxMessage{Recipient: "alice@example.com", Subject: "Hello", Body: "<p>Hi</p>", Status: models.OutboxDead, Attempts: 5})
+	messages.Add(models.OutboxMessage{Recipient: "bob@example.com", Subject: "Hello", Status: models.OutboxSent, Attempts: 1})
+
+	w := app.do(t, admin, http.MethodPost, "/admin/outbox/1/requeue", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/admin/outbox", w.Header().Get("Location"))
+	pending, err := messages.List(models.OutboxPending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Zero(t, pending[0].Attempts)
+
+	w = app.do(t, admin, http.MethodPost, "/admin/outbox/2/requeue", nil)
+	assert.Contains(t, w.Header().Get("Location"), "error=Message+not+found")
+}
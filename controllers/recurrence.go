@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OccurrenceInput struct {
+	Title       string `form:"title" binding:"required"`
+	Description string `form:"description" binding:"required"`
+	StartTime   string `form:"start_time" binding:"required"`
+	EndTime     string `form:"end_time" binding:"required"`
+	Location    string `form:"location" binding:"required"`
+	Scope       string `form:"scope" binding:"required,oneof=this future"`
+}
+
+// loadOccurrence resolves the event and occurrence named in the URL for
+// the current user, redirecting and returning false when it cannot.
+func (ec *EventController) loadOccurrence(c *gin.Context) (*models.Event, *services.Occurrence, bool) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return nil, nil, false
+	}
+
+	event, ok, err := ec.loadEvent(c)
+	if !ok {
+		if err != nil {
+			log.Printf("Occurrence: failed to load event %s: %v", c.Param("id"), err)
+		}
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+		return nil, nil, false
+	}
+	eventID := event.ID.String()
+
+	if !ec.canManage(user, event) {
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Permission denied")
+		return nil, nil, false
+	}
+
+	key, err := strconv.ParseInt(c.Param("start"), 10, 64)
+	if err != nil {
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=Occurrence not found", eventID))
+		return nil, nil, false
+	}
+
+	occurrence, err := services.GetOccurrence(event, time.Unix(key, 0))
+	if err != nil {
+		if !errors.Is(err, services.ErrOccurrenceNotFound) && !errors.Is(err, services.ErrNotRecurring) {
+			log.Printf("Occurrence: failed to load occurrence %d of event %s: %v", key, eventID, err)
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=Occurrence not found", eventID))
+		return nil, nil, false
+	}
+
+	return event, occurrence, true
+}
+
+// UpdateOccurrence edits one occurrence of a series, or it and every later one
+func (ec *EventController) UpdateOccurrence(c *gin.Context) {
+	event, occurrence, ok := ec.loadOccurrence(c)
+	if !ok {
+		return
+	}
+	editURL := fmt.Sprintf("/events/%s/occurrences/%d/edit", event.ID, occurrence.Key())
+
+	var input OccurrenceInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, editURL+"?error=Please fill all required fields correctly")
+		return
+	}
+
+	startTime, err := parseDateTime(input.StartTime)
+	if err != nil {
+		c.Redirect(http.StatusFound, editURL+"?error=Invalid start datetime format")
+		return
+	}
+
+	endTime, err := parseDateTime(input.EndTime)
+	if err != nil {
+		c.Redirect(http.StatusFound, editURL+"?error=Invalid end datetime format")
+		return
+	}
+
+	if endTime.Before(startTime) {
+		c.Redirect(http.StatusFound, editURL+"?error=End datetime must be after start datetime")
+		return
+	}
+
+	override := models.EventOccurrenceOverride{
+		EventID:         event.ID,
+		OccurrenceStart: occurrence.OriginalStart,
+		ThisAndFuture:   input.Scope == services.ScopeFuture,
+		Title:           input.Title,
+		Description:     input.Description,
+		Location:        input.Location,
+		StartTime:       &startTime,
+		EndTime:         &endTime,
+	}
+	if err := services.SaveOccurrenceOverride(&override); err != nil {
+		log.Printf("Occurrence: failed to save override for event %s: %v", event.ID, err)
+		c.Redirect(http.StatusFound, editURL+"?error=Failed to update occurrence")
+		return
+	}
+
+	services.TrackEventAction(c, event, models.ActionEdit, "occurrence_"+input.Scope)
+	services.PublishEventChange(services.EventUpdated, event)
+
+	flash := "Occurrence updated successfully"
+	if override.ThisAndFuture {
+		flash = "This and all following occurrences were updated"
+	}
+	c.SetCookie("flash", flash, 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s", event.ID))
+}
+
+type CancelOccurrenceInput struct {
+	Scope string `form:"scope"` // this or future, checked by services.CancelOccurrence
+}
+
+// CancelOccurrence cancels one occurrence of a series, or ends the series there
+func (ec *EventController) CancelOccurrence(c *gin.Context) {
+	event, occurrence, ok := ec.loadOccurrence(c)
+	if !ok {
+		return
+	}
+
+	var input CancelOccurrenceInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=%s", event.ID, url.QueryEscape("Invalid cancellation request")))
+		return
+	}
+	scope := input.Scope
+	if err := services.CancelOccurrence(event, occurrence.OriginalStart, scope); err != nil {
+		message := "Failed to cancel occurrence"
+		if errors.Is(err, services.ErrFirstOccurrence) {
+			message = "To cancel every occurrence, delete the event instead"
+		} else {
+			log.Printf("Occurrence: failed to cancel occurrence of event %s: %v", event.ID, err)
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=%s", event.ID, url.QueryEscape(message)))
+		return
+	}
+
+	services.TrackEventAction(c, event, models.ActionEdit, "occurrence_cancel_"+scope)
+	services.PublishEventChange(services.EventUpdated, event)
+
+	flash := "Occurrence cancelled"
+	if scope == services.ScopeFuture {
+		flash = "This and all following occurrences were cancelled"
+	}
+	c.SetCookie("flash", flash, 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s", event.ID))
+}
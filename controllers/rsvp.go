@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RSVPInput struct {
+	Status string `form:"status"` // going, interested or not_going, checked by services.SetRSVP
+}
+
+// RSVP records the current user's attendance choice for an event
+func (ec *EventController) RSVP(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	event, ok, err := ec.loadEvent(c)
+	if !ok {
+		if err != nil {
+			log.Printf("RSVP: failed to load event %s: %v", c.Param("id"), err)
+		}
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+		return
+	}
+	eventID := event.ID.String()
+
+	var input RSVPInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=%s", eventID, url.QueryEscape("Invalid RSVP request")))
+		return
+	}
+	status := input.Status
+	result, err := services.SetRSVP(event.ID, user.ID, status, queuePromotionEmails(c))
+	if err != nil {
+		message := "Failed to save your RSVP"
+		if errors.Is(err, services.ErrInvalidRSVPStatus) || errors.Is(err, services.ErrRSVPClosed) {
+			message = err.Error()
+		} else {
+			log.Printf("RSVP: failed to save RSVP for event %s: %v", eventID, err)
+		}
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=%s", eventID, url.QueryEscape(message)))
+		return
+	}
+
+	if len(result.Promoted) > 0 {
+		config.Outbox.Notify()
+	}
+	services.TrackEventAction(c, event, models.ActionInteraction, "rsvp_"+status)
+
+	flash := "Your RSVP has been saved"
+	if result.Status == models.RSVPWaitlisted {
+		flash = "This event is full. You have been added to the waitlist"
+	}
+	c.SetCookie("flash", flash, 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/events/"+eventID)
+}
+
+// CancelRSVP removes the current user's RSVP for an event
+func (ec *EventController) CancelRSVP(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	event, ok, err := ec.loadEvent(c)
+	if !ok {
+		if err != nil {
+			log.Printf("RSVP: failed to load event %s: %v", c.Param("id"), err)
+		}
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+		return
+	}
+	eventID := event.ID.String()
+
+	promoted, err := services.CancelRSVP(event.ID, user.ID, queuePromotionEmails(c))
+	if err != nil {
+		log.Printf("RSVP: failed to cancel RSVP for event %s: %v", eventID, err)
+		c.Redirect(http.StatusFound, fmt.Sprintf("/events/%s?error=%s", eventID, url.QueryEscape("Failed to cancel your RSVP")))
+		return
+	}
+
+	if len(promoted) > 0 {
+		config.Outbox.Notify()
+	}
+
+	c.SetCookie("flash", "Your RSVP has been cancelled", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/events/"+eventID)
+}
+
+// queuePromotionEmails returns the hook queueing an email to every
+// attendee moved off the waitlist, in the transaction that moved them
+func queuePromotionEmails(c *gin.Context) services.PromotionHook {
+	baseURL := utils.GetBaseURL(c.Request)
+	return func(tx *gorm.DB, event *models.Event, promoted []models.Attendee) error {
+		body := utils.RenderTemplate("templates/waitlist_promoted.html", map[string]interface{}{
+			"EventTitle": event.Title,
+			"EventURL":   fmt.Sprintf("%s/events/%s", baseURL, event.ID),
+			"StartTime":  event.StartTime.Format("Jan 2, 2006 3:04 PM"),
+		})
+		for _, attendee := range promoted {
+			var user models.User
+			if err := tx.First(&user, "id = ?", attendee.UserID).Error; err != nil {
+				return fmt.Errorf("promoted attendee %s: %w", attendee.UserID, err)
+			}
+			if err := utils.QueueEmail(tx, user.Email, "You're off the waitlist for "+event.Title, body); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
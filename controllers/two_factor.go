@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"event-analytics/config"
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TwoFactorInput carries a TOTP or recovery code
+type TwoFactorInput struct {
+	Code string `form:"code" binding:"required"`
+}
+
+// TwoFactor is the second step of a login with two-factor authentication:
+// it checks the code for the pending login Login stored and only then
+// creates the session
+func (ac *AuthController) TwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	pending, _ := c.Cookie("pending_2fa")
+	userID, err := config.TwoFactorPending.Get(ctx, pending)
+	if pending == "" || err != nil {
+		c.SetCookie("pending_2fa", "", -1, "/auth", "", false, true)
+		c.Redirect(http.StatusFound, "/auth/login?error=two_factor_expired")
+		return
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=invalid_user")
+		return
+	}
+
+	var input TwoFactorInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.HTML(http.StatusBadRequest, "two_factor.html", gin.H{
+			"error":      "Enter the code from your authenticator app or a recovery code",
+			"title":      "Two-Factor Authentication",
+			"csrf_token": c.PostForm("csrf_token"),
+		})
+		return
+	}
+
+	// The wrong codes of earlier logins count too, so logging in again does
+	// not buy more guesses
+	if twoFactorLocked(ctx, userID) {
+		abandonTwoFactor(c, pending, "/auth/login?error=two_factor_locked")
+		return
+	}
+
+	if err := services.VerifyTwoFactor(id, input.Code); err != nil {
+		if !errors.Is(err, services.ErrInvalidCode) {
+			log.Printf("Two-factor login: failed to verify code for user %s: %v", id, err)
+			c.HTML(http.StatusInternalServerError, "two_factor.html", gin.H{
+				"error":      "Unable to verify the code. Please try again",
+				"title":      "Two-Factor Authentication",
+				"csrf_token": c.PostForm("csrf_token"),
+			})
+			return
+		}
+
+		allowed, err := config.TwoFactorFailures.Allow(ctx, userID)
+		if err != nil {
+			log.Printf("Two-factor login: failed to count a wrong code for user %s: %v", id, err)
+		} else if !allowed {
+			abandonTwoFactor(c, pending, "/auth/login?error=two_factor_locked")
+			return
+		}
+
+		left, err := config.TwoFactorPending.Fail(ctx, pending)
+		if err != nil || left == 0 {
+			c.SetCookie("pending_2fa", "", -1, "/auth", "", false, true)
+			c.Redirect(http.StatusFound, "/auth/login?error=two_factor_failed")
+			return
+		}
+		c.HTML(http.StatusUnauthorized, "two_factor.html", gin.H{
+			"error":      "Invalid code, " + strconv.Itoa(left) + " attempts left",
+			"title":      "Two-Factor Authentication",
+			"csrf_token": c.PostForm("csrf_token"),
+		})
+		return
+	}
+
+	if err := config.TwoFactorPending.Delete(ctx, pending); err != nil {
+		log.Printf("Two-factor login: failed to delete pending login: %v", err)
+	}
+	if err := config.TwoFactorFailures.Reset(ctx, userID); err != nil {
+		log.Printf("Two-factor login: failed to reset wrong codes for user %s: %v", id, err)
+	}
+	c.SetCookie("pending_2fa", "", -1, "/auth", "", false, true)
+	ac.startSession(c, userID)
+}
+
+// abandonTwoFactor ends a pending login and sends the user to location
+func abandonTwoFactor(c *gin.Context, pending, location string) {
+	if err := config.TwoFactorPending.Delete(c.Request.Context(), pending); err != nil {
+		log.Printf("Two-factor login: failed to delete pending login: %v", err)
+	}
+	c.SetCookie("pending_2fa", "", -1, "/auth", "", false, true)
+	c.Redirect(http.StatusFound, location)
+}
+
+// twoFactorLocked reports whether userID ran out of two-factor codes, see
+// config.TwoFactorFailures. The check lets the login through when Redis
+// fails, the pending login still limits its own codes.
+func twoFactorLocked(ctx context.Context, userID string) bool {
+	locked, err := config.TwoFactorFailures.Exhausted(ctx, userID)
+	if err != nil {
+		log.Printf("Two-factor login: failed to check wrong codes for user %s: %v", userID, err)
+		return false
+	}
+	return locked
+}
+
+// EnrollTwoFactor gives the current user a new secret and shows it on the
+// setup page, to be confirmed with a code
+func (ac *AuthController) EnrollTwoFactor(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	if _, err := services.BeginTwoFactorEnrollment(user.ID); err != nil {
+		if errors.Is(err, services.ErrTwoFactorEnabled) {
+			c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Two-factor authentication is already enabled"))
+			return
+		}
+		log.Printf("Two-factor: failed to start enrollment for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Failed to start two-factor setup"))
+		return
+	}
+	c.Redirect(http.StatusFound, "/user/two-factor")
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user
+// proves their app has the secret, and hands the recovery codes to the
+// profile page
+func (ac *AuthController) ConfirmTwoFactor(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	var input TwoFactorInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, "/user/two-factor?error="+url.QueryEscape("Enter the code from your authenticator app"))
+		return
+	}
+
+	codes, err := services.ConfirmTwoFactor(user.ID, input.Code)
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		c.Redirect(http.StatusFound, "/user/two-factor?error="+url.QueryEscape("Invalid code. Check the time on your device and try again"))
+		return
+	case errors.Is(err, services.ErrTwoFactorEnabled), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.Redirect(http.StatusFound, "/user/profile")
+		return
+	case err != nil:
+		log.Printf("Two-factor: failed to confirm enrollment for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/two-factor?error="+url.QueryEscape("Failed to enable two-factor authentication"))
+		return
+	}
+
+	showRecoveryCodes(c, codes, "Two-factor authentication enabled")
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a
+// current code, unless one of the user's roles requires it
+func (ac *AuthController) DisableTwoFactor(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	required, err := ac.users.RequiresTwoFactor(user.ID)
+	if err != nil || required {
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Your role requires two-factor authentication"))
+		return
+	}
+	if !checkTwoFactorCode(c, user.ID) {
+		return
+	}
+
+	if err := services.DisableTwoFactor(user.ID); err != nil {
+		log.Printf("Two-factor: failed to disable for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Failed to disable two-factor authentication"))
+		return
+	}
+	c.SetCookie("flash", "Two-factor authentication disabled", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after
+// checking a current code
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+	if !checkTwoFactorCode(c, user.ID) {
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		log.Printf("Two-factor: failed to regenerate recovery codes for user %s: %v", user.ID, err)
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Failed to regenerate recovery codes"))
+		return
+	}
+	showRecoveryCodes(c, codes, "New recovery codes generated, the old ones no longer work")
+}
+
+// checkTwoFactorCode verifies the code posted with a profile form, redirecting back
+// to the profile page when it is wrong
+func checkTwoFactorCode(c *gin.Context, userID uuid.UUID) bool {
+	var input TwoFactorInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Enter a code from your authenticator app"))
+		return false
+	}
+	if err := services.VerifyTwoFactor(userID, input.Code); err != nil {
+		if !errors.Is(err, services.ErrInvalidCode) {
+			log.Printf("Two-factor: failed to verify code for user %s: %v", userID, err)
+		}
+		c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Invalid two-factor code"))
+		return false
+	}
+	return true
+}
+
+// showRecoveryCodes hands the plain recovery codes, which are never
+// stored, to the profile page once
+func showRecoveryCodes(c *gin.Context, codes []string, message string) {
+	c.SetCookie("recovery_codes", strings.Join(codes, ","), 300, "/user/profile", "", false, true)
+	c.SetCookie("flash", message+". Save your recovery codes now, they will not be shown again", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/user/profile")
+}
+
+// UpdateTwoFactorRoles sets which of services.TwoFactorRoles must use
+// two-factor authentication, from the checkboxes of the admin page
+func UpdateTwoFactorRoles(c *gin.Context) {
+	for _, role := range services.TwoFactorRoles {
+		required := c.PostForm("require_"+role) == "on"
+		if err := services.SetRoleRequiresTwoFactor(role, required); err != nil {
+			log.Printf("Two-factor: failed to update role %s: %v", role, err)
+			c.Redirect(http.StatusFound, "/admin/two-factor?error="+url.QueryEscape("Failed to update the "+role+" role"))
+			return
+		}
+	}
+
+	c.SetCookie("flash", "Two-factor requirements saved", 300, "/", "", false, true)
+	c.Redirect(http.StatusFound, "/admin/two-factor")
+}
@@ -0,0 +1,32 @@
+package cron
+
+import (
+	"log"
+	"time"
+
+	"event-analytics/services"
+)
+
+// RollupHourlyAnalytics refreshes the hourly summaries for the previous and
+// current hour so late-arriving records are folded in.
+func RollupHourlyAnalytics() {
+	now := time.Now().UTC()
+	rows, err := services.RollupAnalytics(services.GranularityHour, now.Truncate(time.Hour).Add(-time.Hour), now)
+	if err != nil {
+		log.Printf("Failed to roll up hourly analytics: %v", err)
+		return
+	}
+	log.Printf("Hourly analytics rollup wrote %d rows", rows)
+}
+
+// RollupDailyAnalytics refreshes the daily summaries for yesterday and
+// today, days being UTC like the buckets
+func RollupDailyAnalytics() {
+	now := time.Now().UTC()
+	rows, err := services.RollupAnalytics(services.GranularityDay, now.AddDate(0, 0, -1), now)
+	if err != nil {
+		log.Printf("Failed to roll up daily analytics: %v", err)
+		return
+	}
+	log.Printf("Daily analytics rollup wrote %d rows", rows)
+}
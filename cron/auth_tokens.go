@@ -0,0 +1,19 @@
+package cron
+
+import (
+	"log"
+
+	"event-analytics/services"
+)
+
+// PurgeExpiredTokens deletes used and expired verification and reset tokens
+func PurgeExpiredTokens() {
+	purged, err := services.PurgeExpiredTokens()
+	if err != nil {
+		log.Printf("Failed to purge expired tokens: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d used or expired tokens", purged)
+	}
+}
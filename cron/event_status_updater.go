@@ -6,24 +6,38 @@ import (
 
 	"event-analytics/config"
 	"event-analytics/models"
+	"event-analytics/services"
+
+	"gorm.io/gorm/clause"
 )
 
+// returningStatus reads back the changed rows so they can be announced
+var returningStatus = clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "status"}}}
+
 func UpdateEventStatuses() {
 	currentTime := time.Now()
 
 	// Update draft events to published if their published_date is today
-	err := config.DB.Model(&models.Event{}).
+	var published []models.Event
+	err := config.DB.Model(&published).
+		Clauses(returningStatus).
 		Where("status = ? AND published_date <= ?", "draft", currentTime).
 		Update("status", "published").Error
 	if err != nil {
 		log.Printf("Failed to update draft events to published: %v", err)
 	}
+	services.PublishEventChanges(services.EventPublished, published)
 
-	// Update events to expired if their end_time is in the past
-	err = config.DB.Model(&models.Event{}).
-		Where("status != ? AND end_time <= ?", "expired", currentTime).
+	// Update events to expired if their end_time is in the past. Recurring
+	// events expire once their last occurrence has ended, and never when unbounded.
+	var expired []models.Event
+	err = config.DB.Model(&expired).
+		Clauses(returningStatus).
+		Where("status != ?", "expired").
+		Where("(COALESCE(recurrence_rule, '') = '' AND end_time <= ?) OR series_ends_at <= ?", currentTime, currentTime).
 		Update("status", "expired").Error
 	if err != nil {
 		log.Printf("Failed to update events to expired: %v", err)
 	}
+	services.PublishEventChanges(services.EventExpired, expired)
 }
@@ -0,0 +1,21 @@
+package cron
+
+import (
+	"log"
+
+	"event-analytics/config"
+	"event-analytics/pkg/outbox"
+)
+
+// ScrubOutbox empties the body of sent and dead outbox messages the
+// dispatcher left behind, such as those finished before it emptied them
+func ScrubOutbox() {
+	scrubbed, err := outbox.Scrub(config.DB)
+	if err != nil {
+		log.Printf("Failed to scrub the email outbox: %v", err)
+		return
+	}
+	if scrubbed > 0 {
+		log.Printf("Scrubbed the body of %d sent or dead emails", scrubbed)
+	}
+}
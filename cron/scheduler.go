@@ -16,6 +16,35 @@ func StartCronJobs() {
 		log.Fatalf("Failed to schedule event status updater: %v", err)
 	}
 
+	// Roll raw analytics into the hourly and daily summary tables
+	_, err = scheduler.Every(5).Minutes().Do(RollupHourlyAnalytics)
+	if err != nil {
+		log.Fatalf("Failed to schedule hourly analytics rollup: %v", err)
+	}
+
+	_, err = scheduler.Every(15).Minutes().Do(RollupDailyAnalytics)
+	if err != nil {
+		log.Fatalf("Failed to schedule daily analytics rollup: %v", err)
+	}
+
+	// Snapshot HyperLogLog unique visitor counts into the database every hour
+	_, err = scheduler.Every(1).Hour().Do(SnapshotUniqueVisitors)
+	if err != nil {
+		log.Fatalf("Failed to schedule unique visitor snapshot: %v", err)
+	}
+
+	// Drop used and expired verification and reset tokens every hour
+	_, err = scheduler.Every(1).Hour().Do(PurgeExpiredTokens)
+	if err != nil {
+		log.Fatalf("Failed to schedule token purge: %v", err)
+	}
+
+	// Empty the body of sent and dead email every hour, so no link outlives its delivery
+	_, err = scheduler.Every(1).Hour().Do(ScrubOutbox)
+	if err != nil {
+		log.Fatalf("Failed to schedule outbox scrub: %v", err)
+	}
+
 	// Start the scheduler
 	scheduler.StartAsync()
 }
\ No newline at end of file
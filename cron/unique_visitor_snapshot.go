@@ -0,0 +1,69 @@
+package cron
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/uniques"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// SnapshotUniqueVisitors copies the day, week and month HyperLogLog counts of
+// recently active events into Postgres. Yesterday's periods are included so
+// the final count of a period is captured after it closes.
+func SnapshotUniqueVisitors() {
+	if config.UniqueVisitors == nil {
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	yesterday := uniques.PeriodStart(uniques.Day, now).AddDate(0, 0, -1)
+
+	var eventIDs []uuid.UUID
+	if err := config.DB.Model(&models.AnalyticsEvent{}).
+		Where("created_at >= ?", yesterday).
+		Distinct("event_id").
+		Pluck("event_id", &eventIDs).Error; err != nil {
+		log.Printf("Failed to load active events for visitor snapshot: %v", err)
+		return
+	}
+
+	for _, eventID := range eventIDs {
+		for _, period := range uniques.Periods {
+			seen := make(map[time.Time]bool)
+			for _, at := range []time.Time{yesterday, now} {
+				start := uniques.PeriodStart(period, at)
+				if seen[start] {
+					continue
+				}
+				seen[start] = true
+
+				visitors, err := config.UniqueVisitors.Count(ctx, eventID.String(), period, start)
+				if err != nil {
+					log.Printf("Failed to count unique visitors for event %s: %v", eventID, err)
+					continue
+				}
+
+				snapshot := models.UniqueVisitorSnapshot{
+					EventID:     eventID,
+					Period:      string(period),
+					PeriodStart: start,
+					Visitors:    visitors,
+				}
+				err = config.DB.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "event_id"}, {Name: "period"}, {Name: "period_start"}},
+					DoUpdates: clause.AssignmentColumns([]string{"visitors", "updated_at"}),
+				}).Create(&snapshot).Error
+				if err != nil {
+					log.Printf("Failed to save unique visitor snapshot for event %s: %v", eventID, err)
+				}
+			}
+		}
+	}
+}
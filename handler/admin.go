@@ -0,0 +1,69 @@
+package handler
+
+import (
+    "log"
+    "net/http"
+
+    "event-analytics/models"
+    "event-analytics/render"
+    "event-analytics/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// outboxPageSize is the number of messages the outbox page lists
+const outboxPageSize = 100
+
+// ShowOutboxPage lists the newest outbox messages for admins, optionally
+// of one status, with the number of messages of every status
+func (p *Pages) ShowOutboxPage(c *gin.Context) {
+    status := c.Query("status")
+    valid := status == ""
+    for _, s := range models.OutboxStatuses {
+        valid = valid || s == status
+    }
+    if !valid {
+        c.Redirect(http.StatusFound, "/admin/outbox")
+        return
+    }
+
+    messages, err := p.outbox.List(status, outboxPageSize)
+    if err != nil {
+        log.Printf("Outbox page: failed to list messages: %v", err)
+    }
+    counts, err := p.outbox.Counts()
+    if err != nil {
+        log.Printf("Outbox page: failed to count messages: %v", err)
+    }
+
+    render.Render(c, gin.H{
+        "user":       c.MustGet("user"),
+        "title":      "Email Outbox",
+        "error":      c.Query("error"),
+        "success":    c.GetString("flash"),
+        "csrf_token": c.GetString("csrf_token"),
+        "status":     status,
+        "statuses":   models.OutboxStatuses,
+        "counts":     counts,
+        "messages":   messages,
+    }, "admin_outbox.html")
+}
+
+// ShowTwoFactorAdminPage lets admins require two-factor authentication for
+// the admin and moderator roles
+func ShowTwoFactorAdminPage(c *gin.Context) {
+    required, err := services.RolesRequiringTwoFactor()
+    if err != nil {
+        log.Printf("Two-factor page: failed to load roles: %v", err)
+    }
+
+    render.Render(c, gin.H{
+        "user":       c.MustGet("user"),
+        "title":      "Two-Factor Authentication",
+        "error":      c.Query("error"),
+        "success":    c.GetString("flash"),
+        "csrf_token": c.GetString("csrf_token"),
+        "roles":      services.TwoFactorRoles,
+        "required":   required,
+    }, "admin_two_factor.html")
+}
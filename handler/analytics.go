@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"event-analytics/render"
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShowEventAnalytics renders the analytics page for an event. Only the
+// event owner or an admin may view it.
+func (p *Pages) ShowEventAnalytics(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	eventID := c.Param("id")
+
+	event, err := p.findEvent(eventID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+		return
+	}
+
+	if !utils.IsAdminOrOwner(user, event) {
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Permission denied")
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		days = 30
+	} else if days > 365 {
+		days = 365
+	}
+
+	summary, err := services.GetEventAnalyticsSummary(&event, days)
+	if err != nil {
+		log.Printf("Error building analytics for event %s: %v", eventID, err)
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Failed to load analytics")
+		return
+	}
+
+	render.Render(c, gin.H{
+		"title":   "Event Analytics",
+		"user":    user,
+		"event":   event,
+		"summary": summary,
+		"days":    days,
+	}, "event_analytics.html")
+}
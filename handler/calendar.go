@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"event-analytics/models"
+	"event-analytics/pkg/ical"
+	"event-analytics/services"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// writeCalendar encodes cal as the response body
+func writeCalendar(c *gin.Context, cal *ical.Calendar, filename string) {
+	c.Header("Content-Type", ical.ContentType)
+	c.Header("Content-Disposition", `inline; filename="`+filename+`"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	if err := cal.Encode(c.Writer); err != nil {
+		log.Printf("Calendar: failed to write %s: %v", filename, err)
+	}
+}
+
+// ExportEventICS serves a single event as an .ics file (/events/:id.ics)
+func (p *Pages) ExportEventICS(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	eventID := strings.TrimSuffix(c.Param("id"), ".ics")
+	event, err := p.findEvent(eventID)
+	if err != nil {
+		c.String(http.StatusNotFound, "Event not found")
+		return
+	}
+
+	// Drafts are only visible to their owner and admins, as on the dashboard
+	if event.Status == "draft" && !utils.IsAdminOrOwner(user, event) {
+		c.String(http.StatusNotFound, "Event not found")
+		return
+	}
+
+	cal, err := services.BuildCalendar(event.Title, []models.Event{event}, utils.GetBaseURL(c.Request))
+	if err != nil {
+		log.Printf("Calendar: failed to build calendar for event %s: %v", event.ID, err)
+		c.String(http.StatusInternalServerError, "Failed to export event")
+		return
+	}
+
+	writeCalendar(c, cal, "event-"+event.ID.String()+".ics")
+}
+
+// CalendarFeed serves a user's subscribable calendar (/calendar/:token.ics).
+// The secret token stands in for the session, since calendar clients
+// fetch the feed without cookies.
+func CalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	user, err := services.CalendarFeedUser(token)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Calendar: failed to resolve feed token: %v", err)
+		}
+		c.String(http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+
+	events, err := services.FeedEvents(user.ID)
+	if err != nil {
+		log.Printf("Calendar: failed to load feed events for user %s: %v", user.ID, err)
+		c.String(http.StatusInternalServerError, "Failed to load calendar")
+		return
+	}
+
+	cal, err := services.BuildCalendar("Events", events, utils.GetBaseURL(c.Request))
+	if err != nil {
+		log.Printf("Calendar: failed to build feed for user %s: %v", user.ID, err)
+		c.String(http.StatusInternalServerError, "Failed to load calendar")
+		return
+	}
+
+	writeCalendar(c, cal, "events.ics")
+}
@@ -6,11 +6,16 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"encoding/json"
-	"event-analytics/config"
 	"event-analytics/models"
+	"event-analytics/services"
 	"event-analytics/utils"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	// "gorm.io/gorm"
 	// "errors"
 )
@@ -23,6 +28,13 @@ type EventInput struct {
     Location        string `form:"location" binding:"required"`
     Status          string `form:"status" binding:"required,oneof=draft published"`
     PublishedDate   string `form:"published_date"`
+    Capacity        string `form:"capacity"`
+    Repeat          string `form:"repeat"`
+    RepeatInterval  string `form:"repeat_interval"`
+    RepeatDays      []string `form:"repeat_days"`
+    RepeatCount     string `form:"repeat_count"`
+    RepeatUntil     string `form:"repeat_until"`
+    RecurrenceRule  string `form:"recurrence_rule"`
 }
 
 // ShowCreateEventPage renders the create event page
@@ -57,7 +69,13 @@ func ShowCreateEventPage(c *gin.Context) {
     }, "event_new.html")
 }
 
-func ShowEventDetails(c *gin.Context) {
+func (p *Pages) ShowEventDetails(c *gin.Context) {
+	// gin cannot route /events/:id.ics separately from /events/:id
+	if strings.HasSuffix(c.Param("id"), ".ics") {
+		p.ExportEventICS(c)
+		return
+	}
+
 	user, err := utils.GetUserFromSession(c)
 	if err != nil {
 		c.Redirect(http.StatusFound, "/auth/login")
@@ -67,8 +85,8 @@ func ShowEventDetails(c *gin.Context) {
 	// Parse event ID from URL
 	eventID := c.Param("id")
 
-	var event models.Event
-	if err := config.DB.Where("id = ?", eventID).First(&event).Error; err != nil {
+	event, err := p.findEvent(eventID)
+	if err != nil {
 		log.Printf("Error fetching event details: %v", err)
 		c.HTML(http.StatusNotFound, "event_details.html", gin.H{
 			"error": "Event not found",
@@ -78,15 +96,98 @@ func ShowEventDetails(c *gin.Context) {
 		return
 	}
 
+	services.TrackEventAction(c, &event, models.ActionDetailOpen, "")
+
+	rsvp, err := services.GetUserRSVP(event.ID, user.ID)
+	if err != nil {
+		log.Printf("Error fetching RSVP: %v", err)
+	}
+
+	var waitlistPosition int64
+	if rsvp == models.RSVPWaitlisted {
+		if waitlistPosition, err = services.WaitlistPosition(event.ID, user.ID); err != nil {
+			log.Printf("Error fetching waitlist position: %v", err)
+		}
+	}
+
+	counts, err := services.GetRSVPCounts([]uuid.UUID{event.ID})
+	if err != nil {
+		log.Printf("Error fetching RSVP counts: %v", err)
+	}
+
+	var occurrences []services.Occurrence
+	if event.RecurrenceRule != "" {
+		event.Recurrence = services.DescribeRecurrence(&event)
+		if occurrences, err = services.UpcomingOccurrences(&event, 10); err != nil {
+			log.Printf("Error expanding occurrences: %v", err)
+		}
+	}
+
+	flashMessage, _ := c.Cookie("flash")
+
 	c.HTML(http.StatusOK, "event_details.html", gin.H{
-		"title": "Event Details",
-		"user":  user,
-		"event": event,
+		"title":            "Event Details",
+		"user":             user,
+		"event":            event,
+		"rsvp":             rsvp,
+		"rsvpCounts":       counts[event.ID],
+		"waitlistPosition": waitlistPosition,
+		"occurrences":      occurrences,
+		"flash":            flashMessage,
+		"error":            c.Query("error"),
+		"csrf_token":       c.GetString("csrf_token"),
+		"canViewAnalytics": utils.IsAdminOrOwner(user, event),
+		"canEdit":          utils.IsAdminOrOwner(user, event),
 	})
 }
 
+// ShowEditOccurrencePage renders the form for editing one occurrence of a series
+func (p *Pages) ShowEditOccurrencePage(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	eventID := c.Param("id")
+	event, err := p.findEvent(eventID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
+		return
+	}
+
+	if !utils.IsAdminOrOwner(user, event) {
+		c.Redirect(http.StatusFound, "/user/dashboard?error=Permission denied")
+		return
+	}
+
+	key, err := strconv.ParseInt(c.Param("start"), 10, 64)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/events/"+eventID+"?error=Occurrence not found")
+		return
+	}
+
+	occurrence, err := services.GetOccurrence(&event, time.Unix(key, 0))
+	if err != nil {
+		log.Printf("Error fetching occurrence: %v", err)
+		c.Redirect(http.StatusFound, "/events/"+eventID+"?error=Occurrence not found")
+		return
+	}
+
+	render.Render(c, gin.H{
+		"title":      "Edit Occurrence",
+		"user":       user,
+		"event":      event,
+		"occurrence": occurrence,
+		"recurrence": services.DescribeRecurrence(&event),
+		"isFirst":    !occurrence.OriginalStart.After(event.StartTime),
+		"error":      c.Query("error"),
+		"csrf_token": c.GetString("csrf_token"),
+	}, "event_occurrence_edit.html")
+}
+
 // ShowEditEventPage renders the edit event page
-func ShowEditEventPage(c *gin.Context) {
+func (p *Pages) ShowEditEventPage(c *gin.Context) {
     user, err := utils.GetUserFromSession(c)
     if err != nil {
         c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
@@ -96,8 +197,8 @@ func ShowEditEventPage(c *gin.Context) {
     eventID := c.Param("id")
 	log.Println("Event ID from URL:", eventID)
 
-    var event models.Event
-    if err := config.DB.First(&event, "id = ?", eventID).Error; err != nil {
+    event, err := p.findEvent(eventID)
+    if err != nil {
 		log.Println("Error fetching event:", err)
 		c.Redirect(http.StatusFound, "/user/dashboard?error=Event not found")
 		return
@@ -118,4 +219,54 @@ func ShowEditEventPage(c *gin.Context) {
         "event":    event,
         "error":    errorMsg,
     }, "event_edit.html")
-}
\ No newline at end of file
+}
+// ShowImportPage renders the event import upload form
+func ShowImportPage(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
+		return
+	}
+
+	render.Render(c, gin.H{
+		"title":      "Import Events",
+		"user":       user,
+		"error":      c.Query("error"),
+		"csrf_token": c.GetString("csrf_token"),
+	}, "event_import.html")
+}
+
+// ShowEventCard renders a single dashboard card, used to update the
+// dashboard live. Events the dashboard would not list answer 404 so the
+// card is removed.
+func (p *Pages) ShowEventCard(c *gin.Context) {
+	user, err := utils.GetUserFromSession(c)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	event, err := p.findEvent(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// Same visibility rule as the dashboard query
+	if event.Status != "published" && !utils.IsAdminOrOwner(user, event) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	events := []models.Event{event}
+	events[0].IsEditable = services.CheckEventEditPermission(&events[0], user)
+	events[0].Description = utils.Truncate(events[0].Description, 50)
+	if err := services.ApplyRSVPCounts(events); err != nil {
+		log.Printf("Event card: failed to load RSVP counts: %v", err)
+	}
+	if err := services.ApplyNextOccurrences(events); err != nil {
+		log.Printf("Event card: failed to expand recurring event: %v", err)
+	}
+
+	c.HTML(http.StatusOK, "event_card.html", events[0])
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"event-analytics/models"
+	"event-analytics/repository"
+
+	"github.com/google/uuid"
+)
+
+// Pages serves the pages and live streams that read events or the email
+// outbox, through the repositories handed to NewPages
+type Pages struct {
+	events repository.EventRepository
+	outbox repository.OutboxRepository
+}
+
+func NewPages(events repository.EventRepository, outbox repository.OutboxRepository) *Pages {
+	return &Pages{events: events, outbox: outbox}
+}
+
+// findEvent loads the event with id, taken from the path. A malformed id
+// is not found.
+func (p *Pages) findEvent(id string) (models.Event, error) {
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return models.Event{}, repository.ErrNotFound
+	}
+	event, err := p.events.FindByID(eventID)
+	if err != nil {
+		return models.Event{}, err
+	}
+	return *event, nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"event-analytics/config"
+	"event-analytics/pkg/realtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamEvents is the Server-Sent Events fallback for clients whose
+// network breaks WebSocket upgrades. It streams the same messages as /ws
+// for the topics given as ?topic=..., with the same subscription rules.
+// Browsers resume with the Last-Event-ID header; clients that reconnect by
+// hand pass ?last_event_id= instead.
+func (p *Pages) StreamEvents(c *gin.Context) {
+	user, ok := liveUser(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	topics := c.QueryArray("topic")
+	if len(topics) == 0 {
+		c.String(http.StatusBadRequest, "at least one topic is required")
+		return
+	}
+	authorize := p.topicAuthorizer(user)
+	for _, topic := range topics {
+		if err := authorize(topic); err != nil {
+			status := http.StatusForbidden
+			if errors.Is(err, realtime.ErrUnknownTopic) {
+				status = http.StatusBadRequest
+			}
+			c.String(status, "%s: %s", topic, err.Error())
+			return
+		}
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	err := config.Hub.ServeSSE(c.Writer, c.Request, user.ID.String(), topics, lastEventID)
+	if errors.Is(err, realtime.ErrTooManyTopics) {
+		c.String(http.StatusBadRequest, err.Error())
+	} else if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+    "html/template"
+    "log"
+    "net/http"
+
+    "event-analytics/config"
+    "event-analytics/models"
+    "event-analytics/pkg/qrcode"
+    "event-analytics/pkg/totp"
+    "event-analytics/render"
+    "event-analytics/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// ShowTwoFactorPage asks for the second factor of a login that passed the
+// password check
+func ShowTwoFactorPage(c *gin.Context) {
+    pending, _ := c.Cookie("pending_2fa")
+    if pending == "" {
+        c.Redirect(http.StatusFound, "/auth/login")
+        return
+    }
+    if _, err := config.TwoFactorPending.Get(c.Request.Context(), pending); err != nil {
+        c.Redirect(http.StatusFound, "/auth/login?error=two_factor_expired")
+        return
+    }
+
+    render.Render(c, gin.H{
+        "title":      "Two-Factor Authentication",
+        "csrf_token": c.GetString("csrf_token"),
+    }, "two_factor.html")
+}
+
+// ShowTwoFactorSetupPage shows the QR code and key of the secret the user
+// is enrolling with
+func ShowTwoFactorSetupPage(c *gin.Context) {
+    user := c.MustGet("user").(*models.User)
+    secret, err := services.PendingTwoFactorSecret(user.ID)
+    if err != nil {
+        log.Printf("Two-factor setup: failed to load secret: %v", err)
+    }
+    if secret == "" {
+        c.Redirect(http.StatusFound, "/user/profile")
+        return
+    }
+
+    // The QR code is drawn here rather than by a third party, which would
+    // see the secret
+    var qrCode template.HTML
+    code, err := qrcode.Encode(totp.URI(services.TwoFactorIssuer, user.Email, secret))
+    if err != nil {
+        log.Printf("Two-factor setup: failed to draw QR code: %v", err)
+    } else {
+        qrCode = template.HTML(code.SVG(5))
+    }
+
+    render.Render(c, gin.H{
+        "user":       user,
+        "title":      "Two-Factor Authentication",
+        "error":      c.Query("error"),
+        "csrf_token": c.GetString("csrf_token"),
+        "secret":     secret,
+        "qrCode":     qrCode,
+    }, "two_factor_setup.html")
+}
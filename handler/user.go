@@ -6,16 +6,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"event-analytics/config"
 	"event-analytics/models"
+	"event-analytics/repository"
 	"event-analytics/services"
 	"log"
 	"net/http"
 
 	"errors"
 	"strconv"
-
-	"gorm.io/gorm"
+	"strings"
 )
 
 func ShowLoginPage(c *gin.Context) {
@@ -39,6 +38,12 @@ func ShowLoginPage(c *gin.Context) {
             message.Error = "User data is invalid"
         case "session_expired":
             message.Error = "Your session has expired. Please login again"
+        case "two_factor_expired":
+            message.Error = "Your sign in timed out. Please login again"
+        case "two_factor_failed":
+            message.Error = "Too many invalid codes. Please login again"
+        case "two_factor_locked":
+            message.Error = "Too many invalid codes. Please try again later"
         default:
             message.Error = "An error occurred"
         }
@@ -73,6 +78,13 @@ func ShowForgotPasswordPage(c *gin.Context) {
     }, "forgot_password.html")
 }
 
+func ShowResendVerificationPage(c *gin.Context) {
+    render.Render(c, gin.H{
+        "title": "Resend Verification Email",
+        "error": "",
+    }, "resend_verification.html")
+}
+
 func ShowResetPasswordPage(c *gin.Context) {
     token := c.Query("token")
 
@@ -83,10 +95,9 @@ func ShowResetPasswordPage(c *gin.Context) {
         return
     }
 
-    // Check if the token exists in the database (using Find)
-    var passwordReset models.PasswordReset
-    if err := config.DB.Where("token = ?", token).First(&passwordReset).Error; err != nil {
-        if errors.Is(err, gorm.ErrRecordNotFound) { 
+    // Check that the token is known, unused and not expired
+    if _, err := services.PasswordResetEmail(token); err != nil {
+        if errors.Is(err, services.ErrInvalidToken) {
             log.Printf("Reset password: Invalid or expired token")
             c.Redirect(http.StatusFound, "/auth/login?error=invalid_token")
             return
@@ -104,7 +115,7 @@ func ShowResetPasswordPage(c *gin.Context) {
     }, "reset_password.html")
 }
 
-func Dashboard(c *gin.Context) {
+func (p *Pages) Dashboard(c *gin.Context) {
     user, exists := c.Get("user")
     if !exists || user == nil {
         c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
@@ -131,15 +142,13 @@ func Dashboard(c *gin.Context) {
     }
     offset := (pageNum - 1) * limit
 
-    var events []models.Event
-
-    // Fetch events based on visibility rules
-    query := config.DB.Offset(offset).Limit(limit)
+    // Fetch events based on visibility rules, with the total for pagination
+    filter := repository.EventFilter{Offset: offset, Limit: limit}
     if !utils.IsAdmin(currentUser) {
-        query = query.Where("status = ? OR created_by = ?", "published", currentUser.ID)
+        filter.VisibleTo = &currentUser.ID
     }
-    result := query.Find(&events)
-    if result.Error != nil {
+    events, totalEvents, err := p.events.List(filter)
+    if err != nil {
         // c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
         c.Redirect(http.StatusFound, "/user/dashboard?error=Failed to fetch events")
         c.Abort()
@@ -152,6 +161,15 @@ func Dashboard(c *gin.Context) {
         events[i].Description = utils.Truncate(events[i].Description, 50) // Truncate to 50 characters
     }
 
+    if err := services.ApplyRSVPCounts(events); err != nil {
+        log.Printf("Dashboard: failed to load RSVP counts: %v", err)
+    }
+    if err := services.ApplyNextOccurrences(events); err != nil {
+        log.Printf("Dashboard: failed to expand recurring events: %v", err)
+    }
+
+    services.TrackEventViews(c, events)
+
     // HTMX handling
     if c.GetHeader("HX-Request") != "" {
         c.HTML(http.StatusOK, "event_cards.html", gin.H{
@@ -161,14 +179,6 @@ func Dashboard(c *gin.Context) {
         return
     }
 
-    // Count total events based on visibility rules for pagination
-    var totalEvents int64
-    countQuery := config.DB.Model(&models.Event{})
-    if !utils.IsAdmin(currentUser) {
-        countQuery = countQuery.Where("status = ? OR created_by = ?", "published", currentUser.ID)
-    }
-    countQuery.Count(&totalEvents)
-
     hasMore := offset+limit < int(totalEvents)
 
     render.Render(c, gin.H{
@@ -191,9 +201,58 @@ func ShowProfilePage(c *gin.Context) {
         return
     }
 
+    var calendarFeed *models.CalendarFeed
+    var apiTokens []models.APIToken
+    var twoFactorEnabled bool
+    var recoveryCodesLeft int64
+    if currentUser, ok := user.(*models.User); ok {
+        feed, err := services.GetCalendarFeed(currentUser.ID)
+        if err != nil {
+            log.Printf("Profile: failed to load calendar feed: %v", err)
+        }
+        calendarFeed = feed
+
+        if apiTokens, err = services.ListAPITokens(currentUser.ID); err != nil {
+            log.Printf("Profile: failed to load API tokens: %v", err)
+        }
+
+        twoFactorEnabled = currentUser.TwoFactorEnabled()
+        if twoFactorEnabled {
+            if recoveryCodesLeft, err = services.RecoveryCodesLeft(currentUser.ID); err != nil {
+                log.Printf("Profile: failed to count recovery codes: %v", err)
+            }
+        }
+    }
+
+    // Only set right after the feed URL was generated
+    calendarFeedURL, _ := c.Cookie("calendar_feed_url")
+    c.SetCookie("calendar_feed_url", "", -1, "/user/profile", "", false, true)
+
+    // Only set right after a token was created
+    newAPIToken, _ := c.Cookie("api_token")
+    c.SetCookie("api_token", "", -1, "/user/profile", "", false, true)
+
+    // Only set right after two-factor authentication was enabled or the
+    // codes regenerated
+    var recoveryCodes []string
+    if codes, _ := c.Cookie("recovery_codes"); codes != "" {
+        recoveryCodes = strings.Split(codes, ",")
+    }
+    c.SetCookie("recovery_codes", "", -1, "/user/profile", "", false, true)
+
     render.Render(c, gin.H{
         "user":  user,
 		"title": "Profile",
+        "error":           c.Query("error"),
+        "success":         c.GetString("flash"),
+        "csrf_token":      c.GetString("csrf_token"),
+        "calendarFeed":    calendarFeed,
+        "calendarFeedURL": calendarFeedURL,
+        "apiTokens":       apiTokens,
+        "newAPIToken":     newAPIToken,
+        "twoFactorEnabled":  twoFactorEnabled,
+        "recoveryCodesLeft": recoveryCodesLeft,
+        "recoveryCodes":     recoveryCodes,
     }, "profile.html")
 }
 
@@ -209,83 +268,3 @@ func ShowChangePasswordPage(c *gin.Context) {
         "user":  user,
     }, "change_password.html")
 }
-
-/* func Dashboard(c *gin.Context) {
-    user, exists := c.Get("user")
-    if !exists || user == nil {
-        c.Redirect(http.StatusFound, "/auth/login?error=auth_required")
-        c.Abort()
-        return
-    }
-
-    currentUser, ok := user.(*models.User)
-    if !ok {
-        c.Redirect(http.StatusFound, "/auth/login?error=invalid_user")
-        c.Abort()
-        return
-    }
-
-    error_message := c.Query("error")
-
-    flashMessage, _ := c.Cookie("flash")
-    c.SetCookie("flash", "", -1, "/", "", false, true)
-
-    page := c.DefaultQuery("page", "1")
-    limit := 4
-    pageNum, err := strconv.Atoi(page)
-    if err != nil {
-        pageNum = 1
-    }
-    offset := (pageNum - 1) * limit
-
-    var events []models.Event
-    result := config.DB.Offset(offset).Limit(limit).Find(&events)
-
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
-        return
-    }
-
-    // formattedEvents := make([]gin.H, len(events))
-    // for i, event := range events {
-    //     formattedEvents[i] = gin.H{
-    //         "ID":           event.ID,
-    //         "Title":        event.Title,
-    //         "Location":     event.Location,
-    //         "Description":  event.Description,
-    //         "Status":       event.Status,
-    //         "Image":        event.Image,
-    //         "StartTime":    utils.FormatDate(event.StartTime),
-    //         "EndTime":      utils.FormatDate(event.EndTime),
-    //         "IsEditable":   services.CheckEventEditPermission(&event, currentUser),
-    //     }
-    // }
-
-    for i := range events {
-        events[i].IsEditable = services.CheckEventEditPermission(&events[i], currentUser)
-        events[i].Description = utils.Truncate(events[i].Description, 50) // Truncate to 50 characters
-    }
-
-    // HTMX handling
-    if c.GetHeader("HX-Request") != "" {
-        c.HTML(http.StatusOK, "event_cards.html", gin.H{
-            "content": events,
-        })
-        return
-    }
-
-    // Full page rendering
-    var totalEvents int64
-    config.DB.Model(&models.Event{}).Count(&totalEvents)
-    hasMore := offset+limit < int(totalEvents)
-
-    render.Render(c, gin.H{
-        "title":    "Dashboard",
-        "user":     user,
-        "content":  events,
-        "hasMore":  hasMore,
-        "nextPage": pageNum + 1,
-        "flash":    flashMessage,
-        "error":    error_message,
-    }, "dashboard.html")
-} */
\ No newline at end of file
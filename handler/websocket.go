@@ -1,53 +1,79 @@
 package handler
 
 import (
-	"github.com/gin-gonic/gin"
+	"errors"
 	"log"
 	"net/http"
-	"sync"
-    "github.com/gorilla/websocket"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/realtime"
+	"event-analytics/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
+// The default CheckOrigin only accepts same-origin pages, which keeps
+// other sites from opening sockets with the user's session cookie
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins; consider restricting in production
-	},
-}
-
-type WebSocketHub struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan []byte
-	lock      sync.Mutex
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
 }
 
-var Hub = &WebSocketHub{
-	clients:   make(map[*websocket.Conn]bool),
-	broadcast: make(chan []byte),
-}
+var errForbiddenTopic = errors.New("permission denied")
 
-func (h *WebSocketHub) Run() {
-	for {
-		message := <-h.broadcast
-		h.lock.Lock()
-		for client := range h.clients {
-			err := client.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				client.Close()
-				delete(h.clients, client)
-			}
-		}
-		h.lock.Unlock()
+// WebSocketHandler upgrades a signed in user's connection and hands it to
+// config.Hub. Clients send {"action": "subscribe", "topic": "event:<id>"}
+// to receive updates for dashboard, event:<id> or their own user:<id>.
+func (p *Pages) WebSocketHandler(c *gin.Context) {
+	user, ok := liveUser(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
 	}
-}
 
-func WebSocketHandler(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Failed to upgrade connection:", err)
 		return
 	}
 
-	Hub.lock.Lock()
-	Hub.clients[conn] = true
-	Hub.lock.Unlock()
+	config.Hub.ServeWebSocket(conn, user.ID.String(), p.topicAuthorizer(user))
+}
+
+// liveUser returns the user APIAuthRequired found for the request's
+// session cookie or API token
+func liveUser(c *gin.Context) (*models.User, bool) {
+	user, _ := c.Get("user")
+	current, ok := user.(*models.User)
+	return current, ok && current != nil
+}
+
+// topicAuthorizer returns the subscription rules for user: everyone may
+// follow the dashboard and visible events, only the user and admins may
+// follow user:<id>
+func (p *Pages) topicAuthorizer(user *models.User) realtime.Authorizer {
+	return func(topic string) error {
+		kind, id, err := realtime.ParseTopic(topic)
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case "user":
+			if id != user.ID.String() && !utils.IsAdmin(user) {
+				return errForbiddenTopic
+			}
+		case "event":
+			event, err := p.findEvent(id)
+			if err != nil {
+				return errors.New("event not found")
+			}
+			if event.Status == "draft" && !utils.IsAdminOrOwner(user, event) {
+				return errors.New("event not found")
+			}
+		}
+		return nil
+	}
 }
@@ -4,13 +4,24 @@ import (
 	"context"
 	"event-analytics/config"
 	"event-analytics/models"
+	"event-analytics/repository"
 	"net/http"
 	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func AuthRequired() gin.HandlerFunc {
+// sessionUser loads the user a session token belongs to
+func sessionUser(users repository.UserRepository, userID string) (*models.User, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	return users.FindByID(id)
+}
+
+func AuthRequired(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionToken, _ := c.Cookie("session_token")
 		if sessionToken == "" {
@@ -34,18 +45,84 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		user, err := sessionUser(users, userID)
+		if err != nil {
 			c.Redirect(http.StatusFound, "/auth/login")
 			c.Abort()
 			return
 		}
 
-		c.Set("user", &user)
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// RequireRole lets only users holding role through. It runs after
+// AuthRequired, which sets the user.
+func RequireRole(users repository.UserRepository, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := c.Get("user")
+		current, ok := user.(*models.User)
+		if !ok {
+			c.Redirect(http.StatusFound, "/auth/login")
+			c.Abort()
+			return
+		}
+
+		allowed, err := users.HasRole(current.ID, role)
+		if err != nil || !allowed {
+			c.Redirect(http.StatusFound, "/user/dashboard?error="+url.QueryEscape("Permission denied"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// twoFactorExempt are the routes a user who has to enroll in two-factor
+// authentication can still reach, to do so or to leave
+var twoFactorExempt = map[string]bool{
+	"/user/profile":            true,
+	"/user/logout":             true,
+	"/user/two-factor":         true,
+	"/user/two-factor/enroll":  true,
+	"/user/two-factor/confirm": true,
+}
+
+// TwoFactorExempt reports whether TwoFactorEnrolled lets the route through
+// for users who have yet to enroll
+func TwoFactorExempt(route string) bool {
+	return twoFactorExempt[route]
+}
+
+// TwoFactorEnrolled sends users whose roles require two-factor
+// authentication to their profile page until they have enrolled. It runs
+// after AuthRequired, which sets the user.
+func TwoFactorEnrolled(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mustEnroll(c, users) {
+			c.Redirect(http.StatusFound, "/user/profile?error="+url.QueryEscape("Your role requires two-factor authentication. Enable it to continue"))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// mustEnroll reports whether the user of the request has yet to enroll in
+// the two-factor authentication one of their roles requires. A failed
+// lookup counts as required.
+func mustEnroll(c *gin.Context, users repository.UserRepository) bool {
+	user, _ := c.Get("user")
+	current, ok := user.(*models.User)
+	if !ok || current.TwoFactorEnabled() || TwoFactorExempt(c.FullPath()) {
+		return false
+	}
+
+	required, err := users.RequiresTwoFactor(current.ID)
+	return err != nil || required
+}
+
 func PreventAuthenticatedAccess() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionToken, _ := c.Cookie("session_token")
@@ -62,7 +139,7 @@ func PreventAuthenticatedAccess() gin.HandlerFunc {
 	}
 }
 
-func UserMiddleware() gin.HandlerFunc {
+func UserMiddleware(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionToken, _ := c.Cookie("session_token")
 		if sessionToken == "" {
@@ -78,8 +155,8 @@ func UserMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		user, err := sessionUser(users, userID)
+		if err != nil {
 			c.Set("user", nil)
 			c.Next()
 			return
@@ -0,0 +1,27 @@
+// Package migrations holds the numbered SQL files that build the database
+// schema, one directory per dialect. Add a change as the next
+// NNNN_name.up.sql with a matching NNNN_name.down.sql that undoes it, in
+// every directory; applied files must never be edited.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Dialects lists the directories, named like the dialects they are for
+var Dialects = []string{"postgres", "sqlite"}
+
+// For returns the migrations of a dialect
+func For(dialect string) (fs.FS, error) {
+	for _, name := range Dialects {
+		if name == dialect {
+			return fs.Sub(files, name)
+		}
+	}
+	return nil, fmt.Errorf("migrations: no migrations for dialect %q", dialect)
+}
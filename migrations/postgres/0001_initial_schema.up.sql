@@ -0,0 +1,201 @@
+-- Schema as previously created by AutoMigrate. Every statement is
+-- idempotent so databases that were set up by AutoMigrate adopt it as is.
+
+CREATE TABLE IF NOT EXISTS users (
+    id          uuid PRIMARY KEY,
+    username    varchar(100) NOT NULL,
+    first_name  varchar(100),
+    last_name   varchar(100),
+    email       varchar(150) NOT NULL,
+    password    text NOT NULL,
+    address     varchar(255),
+    is_verified boolean DEFAULT false,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    CONSTRAINT uni_users_username UNIQUE (username),
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS events (
+    id              uuid PRIMARY KEY,
+    title           varchar(255) NOT NULL,
+    description     text NOT NULL,
+    start_time      timestamptz,
+    end_time        timestamptz,
+    location        varchar(255),
+    image           varchar(255),
+    status          varchar(50) DEFAULT 'draft',
+    published_date  timestamptz,
+    capacity        bigint,
+    recurrence_rule varchar(255),
+    series_ends_at  timestamptz,
+    created_by      uuid NOT NULL,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz,
+    CONSTRAINT uni_events_title UNIQUE (title)
+);
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+
+CREATE TABLE IF NOT EXISTS verification_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid NOT NULL,
+    token      varchar(255) NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON verification_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         bigserial PRIMARY KEY,
+    email      varchar(255) NOT NULL,
+    token      varchar(255) NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_email ON password_resets (email);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id   bigserial PRIMARY KEY,
+    name text NOT NULL,
+    CONSTRAINT uni_roles_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id uuid NOT NULL,
+    role_id bigint NOT NULL,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE TABLE IF NOT EXISTS password_histories (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid NOT NULL,
+    password   text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_password_histories_user_id ON password_histories (user_id);
+
+CREATE TABLE IF NOT EXISTS user_logs (
+    id         bigserial PRIMARY KEY,
+    user_id    uuid NOT NULL,
+    action     varchar(255) NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS analytics_events (
+    id           bigserial PRIMARY KEY,
+    event_id     uuid NOT NULL,
+    user_id      uuid,
+    visitor_id   varchar(64),
+    action       varchar(50) NOT NULL,
+    name         varchar(100),
+    event_status varchar(50),
+    referrer     varchar(512),
+    user_agent   varchar(512),
+    ip_address   varchar(64),
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_event_id ON analytics_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_user_id ON analytics_events (user_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_visitor_id ON analytics_events (visitor_id);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_action ON analytics_events (action);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_created_at ON analytics_events (created_at);
+
+CREATE TABLE IF NOT EXISTS unique_visitor_snapshots (
+    id           bigserial PRIMARY KEY,
+    event_id     uuid NOT NULL,
+    period       varchar(10) NOT NULL,
+    period_start timestamptz NOT NULL,
+    visitors     bigint NOT NULL DEFAULT 0,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_unique_visitor_snapshot ON unique_visitor_snapshots (event_id, period, period_start);
+
+CREATE TABLE IF NOT EXISTS analytics_hourly_rollups (
+    event_id        uuid NOT NULL,
+    bucket_start    timestamptz NOT NULL,
+    views           bigint NOT NULL DEFAULT 0,
+    detail_opens    bigint NOT NULL DEFAULT 0,
+    edits           bigint NOT NULL DEFAULT 0,
+    deletes         bigint NOT NULL DEFAULT 0,
+    interactions    bigint NOT NULL DEFAULT 0,
+    unique_visitors bigint NOT NULL DEFAULT 0,
+    pre_publish     bigint NOT NULL DEFAULT 0,
+    post_publish    bigint NOT NULL DEFAULT 0,
+    updated_at      timestamptz,
+    PRIMARY KEY (event_id, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS analytics_daily_rollups (
+    event_id        uuid NOT NULL,
+    bucket_start    timestamptz NOT NULL,
+    views           bigint NOT NULL DEFAULT 0,
+    detail_opens    bigint NOT NULL DEFAULT 0,
+    edits           bigint NOT NULL DEFAULT 0,
+    deletes         bigint NOT NULL DEFAULT 0,
+    interactions    bigint NOT NULL DEFAULT 0,
+    unique_visitors bigint NOT NULL DEFAULT 0,
+    pre_publish     bigint NOT NULL DEFAULT 0,
+    post_publish    bigint NOT NULL DEFAULT 0,
+    updated_at      timestamptz,
+    PRIMARY KEY (event_id, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS attendees (
+    id            bigserial PRIMARY KEY,
+    event_id      uuid NOT NULL,
+    user_id       uuid NOT NULL,
+    status        varchar(20) NOT NULL,
+    waitlisted_at timestamptz,
+    created_at    timestamptz,
+    updated_at    timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendee_event_user ON attendees (event_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_attendees_user_id ON attendees (user_id);
+CREATE INDEX IF NOT EXISTS idx_attendees_status ON attendees (status);
+CREATE INDEX IF NOT EXISTS idx_attendees_waitlisted_at ON attendees (waitlisted_at);
+
+CREATE TABLE IF NOT EXISTS event_occurrence_overrides (
+    id               bigserial PRIMARY KEY,
+    event_id         uuid NOT NULL,
+    occurrence_start timestamptz NOT NULL,
+    this_and_future  boolean NOT NULL DEFAULT false,
+    cancelled        boolean NOT NULL DEFAULT false,
+    title            varchar(255),
+    description      text,
+    location         varchar(255),
+    start_time       timestamptz,
+    end_time         timestamptz,
+    created_at       timestamptz,
+    updated_at       timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_occurrence_override ON event_occurrence_overrides (event_id, occurrence_start, this_and_future);
+
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id               bigserial PRIMARY KEY,
+    user_id          uuid NOT NULL,
+    token_hash       varchar(64) NOT NULL,
+    last_accessed_at timestamptz,
+    created_at       timestamptz,
+    updated_at       timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_calendar_feeds_user_id ON calendar_feeds (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_calendar_feeds_token_hash ON calendar_feeds (token_hash);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           uuid PRIMARY KEY,
+    user_id      uuid NOT NULL,
+    name         varchar(100) NOT NULL,
+    hint         varchar(16) NOT NULL,
+    token_hash   varchar(64) NOT NULL,
+    scopes       varchar(255) NOT NULL,
+    last_used_at timestamptz,
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_tokens_token_hash ON api_tokens (token_hash);
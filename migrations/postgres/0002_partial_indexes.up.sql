@@ -0,0 +1,7 @@
+-- Drafts the status cron publishes once their published_date passes
+CREATE INDEX IF NOT EXISTS idx_events_scheduled_drafts ON events (published_date)
+    WHERE status = 'draft' AND deleted_at IS NULL;
+
+-- Waitlist in promotion order, see services/rsvp_service.go
+CREATE INDEX IF NOT EXISTS idx_attendees_waitlist ON attendees (event_id, waitlisted_at, id)
+    WHERE status = 'waitlisted';
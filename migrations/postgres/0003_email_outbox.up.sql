@@ -0,0 +1,15 @@
+-- Emails waiting for the outbox dispatcher, see pkg/outbox
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id              bigserial PRIMARY KEY,
+    recipient       varchar(255) NOT NULL,
+    subject         varchar(255) NOT NULL,
+    body            text NOT NULL,
+    status          varchar(20) NOT NULL,
+    attempts        bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error      text,
+    sent_at         timestamptz,
+    created_at      timestamptz,
+    updated_at      timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON email_outbox (status, next_attempt_at);
@@ -0,0 +1,16 @@
+-- Hashed tokens can't be turned back into the plain ones, so they go
+DELETE FROM password_resets;
+DROP INDEX IF EXISTS idx_password_resets_expires_at;
+DROP INDEX IF EXISTS idx_password_resets_token_hash;
+ALTER TABLE password_resets DROP COLUMN IF EXISTS used_at;
+ALTER TABLE password_resets DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE password_resets ALTER COLUMN token_hash TYPE varchar(255);
+ALTER TABLE password_resets RENAME COLUMN token_hash TO token;
+
+DELETE FROM verification_tokens;
+DROP INDEX IF EXISTS idx_verification_tokens_expires_at;
+DROP INDEX IF EXISTS idx_verification_tokens_token_hash;
+ALTER TABLE verification_tokens DROP COLUMN IF EXISTS used_at;
+ALTER TABLE verification_tokens DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE verification_tokens ALTER COLUMN token_hash TYPE varchar(255);
+ALTER TABLE verification_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,22 @@
+-- Verification and reset tokens are stored as SHA-256 hashes, expire and
+-- are marked used instead of deleted, see services/auth_token_service.go.
+-- Outstanding tokens are hashed in place and get the usual lifetime.
+ALTER TABLE verification_tokens RENAME COLUMN token TO token_hash;
+ALTER TABLE verification_tokens ALTER COLUMN token_hash TYPE varchar(64)
+    USING encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+ALTER TABLE verification_tokens ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+ALTER TABLE verification_tokens ADD COLUMN IF NOT EXISTS used_at timestamptz;
+UPDATE verification_tokens SET expires_at = COALESCE(created_at, now()) + interval '24 hours';
+ALTER TABLE verification_tokens ALTER COLUMN expires_at SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_verification_tokens_token_hash ON verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires_at ON verification_tokens (expires_at);
+
+ALTER TABLE password_resets RENAME COLUMN token TO token_hash;
+ALTER TABLE password_resets ALTER COLUMN token_hash TYPE varchar(64)
+    USING encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+ALTER TABLE password_resets ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+ALTER TABLE password_resets ADD COLUMN IF NOT EXISTS used_at timestamptz;
+UPDATE password_resets SET expires_at = COALESCE(created_at, now()) + interval '1 hour';
+ALTER TABLE password_resets ALTER COLUMN expires_at SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires_at ON password_resets (expires_at);
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS event_occurrence_overrides;
+DROP TABLE IF EXISTS attendees;
+DROP TABLE IF EXISTS analytics_daily_rollups;
+DROP TABLE IF EXISTS analytics_hourly_rollups;
+DROP TABLE IF EXISTS unique_visitor_snapshots;
+DROP TABLE IF EXISTS analytics_events;
+DROP TABLE IF EXISTS user_logs;
+DROP TABLE IF EXISTS password_histories;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS verification_tokens;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_attendees_waitlist;
+DROP INDEX IF EXISTS idx_events_scheduled_drafts;
@@ -0,0 +1,20 @@
+-- Hashed tokens can't be turned back into the plain ones, so they go
+DROP TABLE IF EXISTS password_resets;
+CREATE TABLE password_resets (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    email      varchar(255) NOT NULL,
+    token      varchar(255) NOT NULL,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_email ON password_resets (email);
+
+DROP TABLE IF EXISTS verification_tokens;
+CREATE TABLE verification_tokens (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    varchar(36) NOT NULL,
+    token      varchar(255) NOT NULL,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON verification_tokens (user_id);
@@ -0,0 +1,30 @@
+-- Verification and reset tokens are stored as SHA-256 hashes, expire and
+-- are marked used instead of deleted, see services/auth_token_service.go.
+-- SQLite can't hash the outstanding tokens, so the tables start over.
+DROP TABLE IF EXISTS verification_tokens;
+CREATE TABLE verification_tokens (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    varchar(36) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at    datetime,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_user_id ON verification_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_verification_tokens_token_hash ON verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires_at ON verification_tokens (expires_at);
+
+DROP TABLE IF EXISTS password_resets;
+CREATE TABLE password_resets (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    email      varchar(255) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at    datetime,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_email ON password_resets (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_resets_expires_at ON password_resets (expires_at);
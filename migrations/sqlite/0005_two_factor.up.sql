@@ -0,0 +1,20 @@
+-- Optional TOTP two-factor authentication, see services/two_factor_service.go.
+-- totp_secret holds the secret being enrolled until totp_enabled_at is set;
+-- totp_last_step is the time step of the last accepted code, so a code
+-- can't be replayed.
+ALTER TABLE users ADD COLUMN totp_secret varchar(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at datetime;
+ALTER TABLE users ADD COLUMN totp_last_step bigint NOT NULL DEFAULT 0;
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    varchar(36) NOT NULL,
+    code_hash  varchar(64) NOT NULL,
+    used_at    datetime,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+-- Roles whose members must enroll before using the app
+ALTER TABLE roles ADD COLUMN requires_two_factor boolean NOT NULL DEFAULT false;
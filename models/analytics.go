@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tracked actions recorded against an event
+const (
+	ActionView        = "view"        // event card rendered on the dashboard
+	ActionDetailOpen  = "detail_open" // event details page opened
+	ActionEdit        = "edit"        // event updated by its owner or an admin
+	ActionDelete      = "delete"      // event deleted
+	ActionInteraction = "interaction" // custom client-side interaction
+)
+
+// AnalyticsEvent is a single raw tracking record for an event
+type AnalyticsEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	EventID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"event_id"`
+	UserID      *uuid.UUID `gorm:"type:uuid;index" json:"user_id"` // Nullable for anonymous visitors
+	VisitorID   string     `gorm:"size:64;index" json:"visitor_id"`
+	Action      string     `gorm:"size:50;not null;index" json:"action"`
+	Name        string     `gorm:"size:100" json:"name"`        // Custom interaction name
+	EventStatus string     `gorm:"size:50" json:"event_status"` // Event status at the time of tracking
+	Referrer    string     `gorm:"size:512" json:"referrer"`
+	UserAgent   string     `gorm:"size:512" json:"user_agent"`
+	IPAddress   string     `gorm:"size:64" json:"ip_address"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
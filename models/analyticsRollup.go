@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnalyticsRollup holds the counters shared by the hourly and daily
+// analytics summary tables. Rows are keyed by event and bucket start.
+type AnalyticsRollup struct {
+	EventID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"event_id"`
+	BucketStart    time.Time `gorm:"primaryKey" json:"bucket_start"`
+	Views          int64     `gorm:"not null;default:0" json:"views"`
+	DetailOpens    int64     `gorm:"not null;default:0" json:"detail_opens"`
+	Edits          int64     `gorm:"not null;default:0" json:"edits"`
+	Deletes        int64     `gorm:"not null;default:0" json:"deletes"`
+	Interactions   int64     `gorm:"not null;default:0" json:"interactions"`
+	UniqueVisitors int64     `gorm:"not null;default:0" json:"unique_visitors"`
+	PrePublish     int64     `gorm:"not null;default:0" json:"pre_publish"`
+	PostPublish    int64     `gorm:"not null;default:0" json:"post_publish"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// AnalyticsHourlyRollup is one hour of aggregated traffic for an event
+type AnalyticsHourlyRollup struct {
+	AnalyticsRollup
+}
+
+// AnalyticsDailyRollup is one day of aggregated traffic for an event
+type AnalyticsDailyRollup struct {
+	AnalyticsRollup
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// API token scopes. Write scopes do not imply read, tokens created from
+// the profile page get both when read-write access is chosen.
+const (
+	ScopeEventsRead     = "events:read"
+	ScopeEventsWrite    = "events:write"
+	ScopeAnalyticsRead  = "analytics:read"
+	ScopeAnalyticsWrite = "analytics:write"
+)
+
+// Scopes lists every valid API token scope
+var Scopes = []string{ScopeEventsRead, ScopeEventsWrite, ScopeAnalyticsRead, ScopeAnalyticsWrite}
+
+// APIToken is a named personal access token for the JSON API. Like
+// CalendarFeed only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index;references:ID;constraint:OnDelete:CASCADE" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Hint       string     `gorm:"size:16;not null" json:"hint"` // First characters of the token, to tell tokens apart
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"scopes"` // Space separated
+	LastUsedAt *time.Time `json:"last_used_at"`                    // Nullable, updated at most once a minute
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// BeforeCreate generates a UUID for new tokens
+func (t *APIToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return
+}
+
+// ScopeList returns the token's scopes
+func (t *APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope reports whether the token grants scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
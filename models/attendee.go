@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RSVP statuses an attendee can choose
+const (
+	RSVPGoing      = "going"
+	RSVPInterested = "interested"
+	RSVPNotGoing   = "not_going"
+	RSVPWaitlisted = "waitlisted" // asked to go but the event was full
+)
+
+// Attendee records a user's RSVP to an event
+type Attendee struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	EventID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_attendee_event_user;references:ID;constraint:OnDelete:CASCADE" json:"event_id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_attendee_event_user;index;references:ID;constraint:OnDelete:CASCADE" json:"user_id"`
+	Status       string     `gorm:"size:20;not null;index" json:"status"` // going, interested, not_going or waitlisted
+	WaitlistedAt *time.Time `gorm:"index" json:"waitlisted_at"`           // Orders the waitlist, nullable
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarFeed is a user's secret iCalendar subscription URL. Only the
+// SHA-256 hash of the token is stored, so a leaked database does not
+// expose anyone's drafts.
+type CalendarFeed struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex;references:ID;constraint:OnDelete:CASCADE" json:"user_id"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"` // Nullable, set when a client fetches the feed
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -18,11 +18,17 @@ type Event struct {
     Image         string          `gorm:"size:255" json:"image"`
     Status        string          `gorm:"size:50;default:'draft'" json:"status"` // draft, published, or expired
     PublishedDate *time.Time      `json:"published_date"`                       // Nullable
+    Capacity      *int            `json:"capacity"`                             // Nullable, unlimited when empty
+    RecurrenceRule string         `gorm:"size:255" json:"recurrence_rule"`       // RFC 5545 RRULE, empty for one-off events
+    SeriesEndsAt  *time.Time      `json:"series_ends_at"`                       // End of the last occurrence, nullable for unbounded series
     CreatedBy     uuid.UUID       `gorm:"not null" json:"created_by"`
     CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
     UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
     DeletedAt     gorm.DeletedAt  `gorm:"index" json:"deleted_at"`
     IsEditable    bool            `gorm:"-" json:"is_editable"` // Virtual field
+    Going         int64           `gorm:"-" json:"going"`       // Virtual field, RSVP count
+    Interested    int64           `gorm:"-" json:"interested"`  // Virtual field, RSVP count
+    Recurrence    string          `gorm:"-" json:"recurrence"`  // Virtual field, human readable RRULE
 }
 
 func (e *Event) BeforeCreate(tx *gorm.DB) (err error) {
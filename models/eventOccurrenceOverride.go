@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventOccurrenceOverride changes or cancels occurrences of a recurring event.
+// It mirrors an RFC 5545 RECURRENCE-ID: OccurrenceStart is the start the
+// occurrence would have had, and ThisAndFuture extends the change to every
+// later occurrence (RANGE=THISANDFUTURE). Empty fields inherit from the series.
+type EventOccurrenceOverride struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	EventID         uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_occurrence_override;references:ID;constraint:OnDelete:CASCADE" json:"event_id"`
+	OccurrenceStart time.Time  `gorm:"not null;uniqueIndex:idx_occurrence_override" json:"occurrence_start"`
+	ThisAndFuture   bool       `gorm:"not null;default:false;uniqueIndex:idx_occurrence_override" json:"this_and_future"`
+	Cancelled       bool       `gorm:"not null;default:false" json:"cancelled"` // EXDATE
+	Title           string     `gorm:"size:255" json:"title"`
+	Description     string     `gorm:"type:text" json:"description"`
+	Location        string     `gorm:"size:255" json:"location"`
+	StartTime       *time.Time `json:"start_time"`
+	EndTime         *time.Time `json:"end_time"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Outbox message statuses
+const (
+	OutboxPending = "pending" // Waiting for its next attempt
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // Gave up after the last attempt, until requeued
+)
+
+// OutboxStatuses lists every outbox message status
+var OutboxStatuses = []string{OutboxPending, OutboxSent, OutboxDead}
+
+// OutboxMessage is an email waiting to be sent by the outbox dispatcher.
+// It is written in the same transaction as the change that triggers it,
+// so the email is sent if and only if the change is committed.
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Recipient     string     `gorm:"size:255;not null" json:"recipient"`
+	Subject       string     `gorm:"size:255;not null" json:"subject"`
+	Body          string     `gorm:"type:text;not null" json:"-"` // HTML
+	Status        string     `gorm:"size:20;not null;index:idx_outbox_due" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due" json:"next_attempt_at"` // Also the lease of a message being sent
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName keeps the table next to the other email tables in listings
+func (OutboxMessage) TableName() string {
+	return "email_outbox"
+}
@@ -4,10 +4,14 @@ import (
     "time"
 )
 
+// PasswordReset lets the owner of Email choose a new password. Like
+// VerificationToken only the token's hash is stored and it is single use.
 type PasswordReset struct {
-    ID          uint      `gorm:"primaryKey"`
-    Email       string    `gorm:"type:varchar(255);not null;index"`
-    Token       string    `gorm:"type:varchar(255);not null"`
-    CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+    ID          uint       `gorm:"primaryKey"`
+    Email       string     `gorm:"type:varchar(255);not null;index"`
+    TokenHash   string     `gorm:"size:64;not null;uniqueIndex"`
+    ExpiresAt   time.Time  `gorm:"not null;index"`
+    UsedAt      *time.Time // Nullable, set when the password is reset
+    CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
 }
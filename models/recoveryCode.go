@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode stands in for a TOTP code when the user has lost their
+// authenticator. Only the SHA-256 hash is stored and a code works once.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	CodeHash  string     `gorm:"size:64;not null"`
+	UsedAt    *time.Time // Nullable, set when the code is used
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
@@ -2,9 +2,17 @@ package models
 
 import "github.com/google/uuid"
 
+// Built-in role names, created on startup by utils.InitializeRoles
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleUser      = "user"
+)
+
 type Role struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"unique;not null"`
+	ID                uint   `gorm:"primaryKey"`
+	Name              string `gorm:"unique;not null"`
+	RequiresTwoFactor bool   `gorm:"not null;default:false"` // Members must enroll in two-factor authentication
 }
 
 // type UserRole struct {
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UniqueVisitorSnapshot persists the approximate unique-visitor count of an
+// event for a day, ISO week or month, copied from Redis HyperLogLog keys.
+type UniqueVisitorSnapshot struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EventID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_unique_visitor_snapshot" json:"event_id"`
+	Period      string    `gorm:"size:10;not null;uniqueIndex:idx_unique_visitor_snapshot" json:"period"` // day, week or month
+	PeriodStart time.Time `gorm:"not null;uniqueIndex:idx_unique_visitor_snapshot" json:"period_start"`
+	Visitors    int64     `gorm:"not null;default:0" json:"visitors"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -17,6 +17,9 @@ type User struct {
 	Password  	string         	`gorm:"not null"`
 	Address   	string         	`gorm:"size:255"`
 	IsVerified 	bool 			`gorm:"default:false"`
+	TOTPSecret	string			`gorm:"column:totp_secret;size:64"` // Secret being enrolled, or in use once TOTPEnabledAt is set
+	TOTPEnabledAt	*time.Time		`gorm:"column:totp_enabled_at"`
+	TOTPLastStep	int64			`gorm:"column:totp_last_step;not null;default:0"` // Time step of the last accepted code
 	CreatedAt 	time.Time 	 	`gorm:"autoCreateTime"`
 	UpdatedAt 	time.Time 	 	`gorm:"autoUpdateTime"`
 	DeletedAt 	gorm.DeletedAt 	`gorm:"index"`
@@ -28,6 +31,11 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// TwoFactorEnabled reports whether the user signs in with a TOTP code
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
 // HashPassword hashes a user's password.
 func (u *User) HashPassword() error {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
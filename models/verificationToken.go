@@ -5,10 +5,14 @@ import (
     "github.com/google/uuid"
 )
 
+// VerificationToken confirms a user's email address. Only the SHA-256 hash
+// of the token is stored, and it can be used once before ExpiresAt.
 type VerificationToken struct {
-    ID        uint      `gorm:"primaryKey"`
-    UserID    uuid.UUID `gorm:"type:uuid;not null;index;references:ID;constraint:OnDelete:CASCADE"`
-    Token     string    `gorm:"type:varchar(255);not null"`
-    CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+    ID        uint       `gorm:"primaryKey"`
+    UserID    uuid.UUID  `gorm:"type:uuid;not null;index;references:ID;constraint:OnDelete:CASCADE"`
+    TokenHash string     `gorm:"size:64;not null;uniqueIndex"`
+    ExpiresAt time.Time  `gorm:"not null;index"`
+    UsedAt    *time.Time // Nullable, set when the email is verified
+    CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
 }
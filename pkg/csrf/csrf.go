@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +17,12 @@ func Middleware() gin.HandlerFunc {
 			return
 		}
 
+		// The JSON API only accepts JSON bodies, see middlewares.RequireJSON
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+
 		if c.Request.Method == "GET" {
 			token := generateToken()
 			c.SetCookie("csrf_token", token, 3600, "/", "", false, true)
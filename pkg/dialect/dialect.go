@@ -0,0 +1,59 @@
+// Package dialect opens the database a DSN names and keeps the SQL that
+// differs between PostgreSQL and SQLite behind one interface. PostgreSQL is
+// what production runs; SQLite needs no server, for local development and
+// hermetic tests.
+package dialect
+
+import (
+	"fmt"
+	"strings"
+
+	"event-analytics/pkg/migrate"
+
+	"gorm.io/gorm"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Dialect is a database backend
+type Dialect interface {
+	// The migrator locks and inspects the schema through the dialect
+	migrate.Dialect
+
+	// Name is Postgres or SQLite, also the directory of its migrations
+	Name() string
+
+	// Open connects to the database named by dsn
+	Open(dsn string, config *gorm.Config) (*gorm.DB, error)
+
+	// TruncateTime returns an SQL expression for column rounded down to
+	// the start of its hour or day in UTC, whatever the session's zone
+	TruncateTime(unit, column string) (string, error)
+
+	// LockTx blocks until tx holds the lock named key, which is released
+	// when tx ends
+	LockTx(tx *gorm.DB, key string) error
+}
+
+// FromDSN returns the dialect of dsn. DSNs starting with sqlite: or file:
+// are SQLite, anything else is a PostgreSQL URL or key=value DSN.
+func FromDSN(dsn string) Dialect {
+	if strings.HasPrefix(dsn, "sqlite:") || strings.HasPrefix(dsn, "file:") {
+		return sqliteDialect{}
+	}
+	return postgresDialect{}
+}
+
+// Open connects to the database named by dsn with the dialect of its scheme
+func Open(dsn string, config *gorm.Config) (Dialect, *gorm.DB, error) {
+	d := FromDSN(dsn)
+	db, err := d.Open(dsn, config)
+	return d, db, err
+}
+
+func unsupportedUnit(unit string) error {
+	return fmt.Errorf("dialect: cannot truncate time to %q", unit)
+}
@@ -0,0 +1,92 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// migrationLockKey identifies the advisory lock held while migrating
+const migrationLockKey int64 = 0x6576656e745f6d67 // "event_mg"
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return Postgres
+}
+
+// Open connects to the database, creating it when it does not exist yet
+func (postgresDialect) Open(dsn string, config *gorm.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), config)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		return db, err
+	}
+
+	// Connect to the postgres database to create the target database
+	dbName := databaseName(dsn)
+	adminDB, err := sql.Open("postgres", strings.Replace(dsn, dbName, "postgres", 1))
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	defer adminDB.Close()
+
+	if _, err := adminDB.Exec(fmt.Sprintf("CREATE DATABASE %s", dbName)); err != nil {
+		return nil, fmt.Errorf("create database %s: %w", dbName, err)
+	}
+	log.Printf("Database %s created successfully", dbName)
+
+	return gorm.Open(postgres.Open(dsn), config)
+}
+
+// databaseName extracts the database of a URL or key=value DSN
+func databaseName(dsn string) string {
+	if strings.Contains(dsn, "database=") {
+		parts := strings.Split(dsn, "database=")
+		return strings.Split(parts[1], " ")[0]
+	}
+	if strings.Contains(dsn, "/") {
+		parts := strings.Split(dsn, "/")
+		return strings.Split(parts[len(parts)-1], "?")[0]
+	}
+	return ""
+}
+
+func (postgresDialect) TruncateTime(unit, column string) (string, error) {
+	switch unit {
+	case "hour", "day":
+		// date_trunc would round a timestamptz in the session's zone
+		return fmt.Sprintf("date_trunc('%s', %s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'", unit, column), nil
+	}
+	return "", unsupportedUnit(unit)
+}
+
+// LockTx takes a transaction level advisory lock, so replicas serialise
+func (postgresDialect) LockTx(tx *gorm.DB, key string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error
+}
+
+// LockMigrations takes a session advisory lock on conn
+func (postgresDialect) LockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}, nil
+}
+
+func (postgresDialect) TableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var exists bool
+	err := conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+	return exists, err
+}
+
+func (postgresDialect) TimestampType() string {
+	return "timestamptz"
+}
@@ -0,0 +1,178 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDriver wraps the pure-Go SQLite driver so every time is written in
+// UTC. Times are stored as text, which only compares correctly in one zone.
+const sqliteDriver = "sqlite-utc"
+
+func init() {
+	sql.Register(sqliteDriver, utcDriver{&sqlite.Driver{}})
+}
+
+// sqliteDefaults are added to the DSN unless it sets them. Immediate
+// transactions take the write lock up front, so two writers wait on the busy
+// timeout instead of failing when one upgrades a read.
+var sqliteDefaults = []struct{ setting, param string }{
+	{"busy_timeout", "_pragma=busy_timeout(5000)"},
+	{"journal_mode", "_pragma=journal_mode(WAL)"},
+	{"_txlock", "_txlock=immediate"},
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return SQLite
+}
+
+// Open opens the database file, creating it when it does not exist yet
+func (sqliteDialect) Open(dsn string, config *gorm.Config) (*gorm.DB, error) {
+	name, memory := sqliteName(dsn)
+	db, err := gorm.Open(&gormsqlite.Dialector{DriverName: sqliteDriver, DSN: name}, config)
+	if err != nil {
+		return nil, err
+	}
+	if memory {
+		// Every connection to :memory: opens a database of its own
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+// sqliteName turns sqlite:path, sqlite://path and file:path DSNs into the
+// name the driver opens, with sqliteDefaults added. It also reports whether
+// the database is in memory.
+func sqliteName(dsn string) (string, bool) {
+	name := dsn
+	if strings.HasPrefix(name, "sqlite:") {
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "sqlite:"), "//")
+	}
+	path, rawQuery, _ := strings.Cut(name, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Leave it to the driver to report
+		return name, false
+	}
+	memory := strings.Contains(path, ":memory:") || query.Get("mode") == "memory"
+
+	params := []string{}
+	if rawQuery != "" {
+		params = append(params, rawQuery)
+	}
+	for _, d := range sqliteDefaults {
+		if memory && d.setting == "journal_mode" {
+			continue
+		}
+		if !sqliteSets(query, d.setting) {
+			params = append(params, d.param)
+		}
+	}
+	if len(params) == 0 {
+		return path, memory
+	}
+	return path + "?" + strings.Join(params, "&"), memory
+}
+
+// sqliteSets reports whether the DSN query sets a parameter or pragma
+func sqliteSets(query url.Values, setting string) bool {
+	if _, ok := query[setting]; ok {
+		return true
+	}
+	for _, pragma := range query["_pragma"] {
+		if strings.HasPrefix(strings.ToLower(pragma), setting) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sqliteDialect) TruncateTime(unit, column string) (string, error) {
+	// strftime reads the stored zone offset and formats in UTC, in the
+	// layout the driver writes times in
+	switch unit {
+	case "hour":
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00+00:00', %s)", column), nil
+	case "day":
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00+00:00', %s)", column), nil
+	}
+	return "", unsupportedUnit(unit)
+}
+
+// LockTx does nothing: SQLite has a single writer, and immediate
+// transactions already hold its lock from the start
+func (sqliteDialect) LockTx(tx *gorm.DB, key string) error {
+	return nil
+}
+
+// LockMigrations does nothing, the same transactions serialise migrations.
+// An SQLite file is not shared by replicas booting on different hosts.
+func (sqliteDialect) LockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	return func() {}, nil
+}
+
+func (sqliteDialect) TableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	return count > 0, err
+}
+
+func (sqliteDialect) TimestampType() string {
+	return "datetime"
+}
+
+type utcDriver struct {
+	*sqlite.Driver
+}
+
+func (d utcDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(sqliteConn)}, nil
+}
+
+// sqliteConn is what the driver's connections implement. utcConn forwards
+// all of it so database/sql keeps using the context aware methods.
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+type utcConn struct {
+	sqliteConn
+}
+
+// CheckNamedValue converts arguments like database/sql does by default,
+// then moves times to UTC
+func (utcConn) CheckNamedValue(arg *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(arg.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	arg.Value = value
+	return nil
+}
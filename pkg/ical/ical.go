@@ -0,0 +1,201 @@
+// Package ical writes RFC 5545 iCalendar documents containing VEVENTs.
+// All times are written in UTC, which needs no VTIMEZONE component and is
+// understood by Google Calendar, Outlook and Apple Calendar alike.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	dateTimeFormat = "20060102T150405Z"
+	maxLineOctets  = 75
+)
+
+// VEVENT STATUS values
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Calendar is a VCALENDAR object
+type Calendar struct {
+	ProdID   string
+	Name     string        // X-WR-CALNAME, shown by clients when subscribing
+	Timezone string        // X-WR-TIMEZONE display hint, e.g. "UTC"
+	Refresh  time.Duration // REFRESH-INTERVAL hint for subscribed feeds, zero to omit
+	Events   []Event
+}
+
+// Event is a VEVENT component. RecurrenceID marks an overridden
+// occurrence of the series with the same UID.
+type Event struct {
+	UID          string
+	DTStamp      time.Time
+	Created      time.Time
+	LastModified time.Time
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Status       string
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID time.Time
+}
+
+// Encode writes the calendar to w with CRLF line endings and folded lines
+func (cal *Calendar) Encode(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", cal.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		e.line("X-WR-CALNAME", escape(cal.Name))
+	}
+	if cal.Timezone != "" {
+		e.line("X-WR-TIMEZONE", cal.Timezone)
+	}
+	if cal.Refresh > 0 {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION", duration(cal.Refresh))
+		e.line("X-PUBLISHED-TTL", duration(cal.Refresh))
+	}
+
+	for _, event := range cal.Events {
+		e.event(event)
+	}
+
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// String returns the encoded calendar
+func (cal *Calendar) String() string {
+	var b strings.Builder
+	cal.Encode(&b)
+	return b.String()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) event(event Event) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", event.UID)
+	e.line("DTSTAMP", formatTime(event.DTStamp))
+	if !event.Created.IsZero() {
+		e.line("CREATED", formatTime(event.Created))
+	}
+	if !event.LastModified.IsZero() {
+		e.line("LAST-MODIFIED", formatTime(event.LastModified))
+	}
+	if !event.RecurrenceID.IsZero() {
+		e.line("RECURRENCE-ID", formatTime(event.RecurrenceID))
+	}
+	e.line("DTSTART", formatTime(event.Start))
+	e.line("DTEND", formatTime(event.End))
+	if event.RRule != "" {
+		e.line("RRULE", event.RRule)
+	}
+	for _, exdate := range event.ExDates {
+		e.line("EXDATE", formatTime(exdate))
+	}
+	e.line("SUMMARY", escape(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION", escape(event.Description))
+	}
+	if event.Location != "" {
+		e.line("LOCATION", escape(event.Location))
+	}
+	if event.URL != "" {
+		e.line("URL", event.URL)
+	}
+	if event.Status != "" {
+		e.line("STATUS", event.Status)
+	}
+	e.line("END", "VEVENT")
+}
+
+// line writes a content line, folding it at 75 octets without splitting
+// a UTF-8 sequence
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+
+	s := name + ":" + value
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		e.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1 // continuation lines start with a space
+	}
+	e.write(s + "\r\n")
+}
+
+func (e *encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escape encodes a TEXT value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// duration formats d as an RFC 5545 DURATION such as PT1H
+func duration(d time.Duration) string {
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := int(d.Hours()); h > 0 {
+		b.WriteString(strconv.Itoa(h) + "H")
+		d -= time.Duration(h) * time.Hour
+	}
+	if m := int(d.Minutes()); m > 0 {
+		b.WriteString(strconv.Itoa(m) + "M")
+		d -= time.Duration(m) * time.Minute
+	}
+	if s := int(d.Seconds()); s > 0 || b.Len() == 2 {
+		b.WriteString(strconv.Itoa(s) + "S")
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeEvent(t *testing.T) {
+	start := time.Date(2024, 3, 1, 18, 0, 0, 0, time.FixedZone("WAT", 3600))
+	cal := Calendar{
+		ProdID:   "-//Test//EN",
+		Name:     "My, Events",
+		Timezone: "UTC",
+		Refresh:  90 * time.Minute,
+		Events: []Event{{
+			UID:         "abc@example.com",
+			DTStamp:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			Start:       start,
+			End:         start.Add(2 * time.Hour),
+			Summary:     "Go; meetup",
+			Description: "Line one\nLine two",
+			Status:      StatusConfirmed,
+			RRule:       "FREQ=WEEKLY;COUNT=3",
+			ExDates:     []time.Time{start.AddDate(0, 0, 7)},
+		}},
+	}
+
+	out := cal.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\n",
+		"X-WR-CALNAME:My\\, Events\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H30M\r\n",
+		"UID:abc@example.com\r\n",
+		"DTSTAMP:20240201T000000Z\r\n",
+		"DTSTART:20240301T170000Z\r\n",
+		"DTEND:20240301T190000Z\r\n",
+		"RRULE:FREQ=WEEKLY;COUNT=3\r\n",
+		"EXDATE:20240308T170000Z\r\n",
+		"SUMMARY:Go\\; meetup\r\n",
+		"DESCRIPTION:Line one\\nLine two\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func TestLongLinesAreFolded(t *testing.T) {
+	cal := Calendar{Events: []Event{{UID: "x", Summary: strings.Repeat("é", 100)}}}
+
+	out := cal.String()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+		assert.True(t, utf8.ValidString(line))
+	}
+	assert.Contains(t, strings.ReplaceAll(out, "\r\n ", ""), "SUMMARY:"+strings.Repeat("é", 100)+"\r\n")
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "PT1H", duration(time.Hour))
+	assert.Equal(t, "PT15M", duration(15*time.Minute))
+	assert.Equal(t, "PT0S", duration(0))
+}
+
+func TestParseEvents(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:1\r\n" +
+		"SUMMARY:Quarterly review\\, Q1\r\n" +
+		"DESCRIPTION:A long description that was folded by the exporting appli\r\n" +
+		" cation\r\n" +
+		"DTSTART;TZID=\"Europe/Paris\":20240115T100000\r\n" +
+		"DURATION:PT1H30M\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"DESCRIPTION:Reminder\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Holiday\r\n" +
+		"DTSTART;VALUE=DATE:20240301\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, err := ParseEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	first := events[0]
+	assert.Equal(t, 3, first.Line)
+	assert.Equal(t, "Quarterly review, Q1", first.Text("SUMMARY"))
+	assert.Equal(t, "A long description that was folded by the exporting application", first.Text("DESCRIPTION"))
+
+	start, dateOnly, err := first.Get("DTSTART").Time()
+	require.NoError(t, err)
+	assert.False(t, dateOnly)
+	assert.Equal(t, time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC), start.UTC())
+
+	d, err := ParseDuration(first.Get("DURATION").Value)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	start, dateOnly, err = events[1].Get("DTSTART").Time()
+	require.NoError(t, err)
+	assert.True(t, dateOnly)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), start)
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	cal := Calendar{Events: []Event{{UID: "x", Start: start, End: start.Add(time.Hour), Summary: "a; b, c\nd", Status: StatusTentative}}}
+
+	events, err := ParseEvents(strings.NewReader(cal.String()))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "a; b, c\nd", events[0].Text("SUMMARY"))
+	assert.Equal(t, StatusTentative, events[0].Text("STATUS"))
+
+	parsed, _, err := events[0].Get("DTSTART").Time()
+	require.NoError(t, err)
+	assert.Equal(t, start, parsed)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := ParseEvents(strings.NewReader("title,start\nfoo,bar\n"))
+	assert.Error(t, err)
+
+	_, err = ParseEvents(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:x\n"))
+	assert.Error(t, err)
+
+	_, err = ParseDuration("P1Y")
+	assert.Error(t, err)
+}
@@ -0,0 +1,225 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Property is a content line such as DTSTART;TZID=Europe/Paris:20240101T100000
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a parsed VEVENT. Line is where it starts in the input.
+type Component struct {
+	Line       int
+	Properties []Property
+}
+
+// Get returns the first property with the given name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Text returns the unescaped value of a TEXT property, or "" when absent
+func (c *Component) Text(name string) string {
+	if p := c.Get(name); p != nil {
+		return unescape(p.Value)
+	}
+	return ""
+}
+
+// Time parses a DATE or DATE-TIME property. UTC, TZID and floating times
+// are supported; floating times are read as UTC. dateOnly is set for
+// VALUE=DATE properties such as all-day events.
+func (p *Property) Time() (t time.Time, dateOnly bool, err error) {
+	value := p.Value
+	if p.Params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.Parse("20060102", value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeFormat, value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if loc, err = time.LoadLocation(strings.Trim(tzid, `"`)); err != nil {
+			return t, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses an RFC 5545 DURATION value such as P1DT2H
+func ParseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// ParseEvents reads the VEVENT components of an iCalendar document
+func ParseEvents(r io.Reader) ([]Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events   []Component
+		current  *Component
+		depth    int // nesting inside the current VEVENT (e.g. VALARM)
+		calendar bool
+	)
+	for _, l := range lines {
+		prop, err := parseLine(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.number, err)
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VCALENDAR"):
+			calendar = true
+		case prop.Name == "BEGIN" && current == nil && strings.EqualFold(prop.Value, "VEVENT"):
+			current = &Component{Line: l.number}
+		case prop.Name == "BEGIN" && current != nil:
+			depth++
+		case prop.Name == "END" && current != nil && depth > 0:
+			depth--
+		case prop.Name == "END" && current != nil:
+			events = append(events, *current)
+			current = nil
+		case current != nil && depth == 0:
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if !calendar {
+		return nil, errors.New("not an iCalendar file")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("line %d: VEVENT is not closed", current.Line)
+	}
+	return events, nil
+}
+
+type contentLine struct {
+	number int
+	text   string
+}
+
+// unfold joins folded continuation lines
+func unfold(r io.Reader) ([]contentLine, error) {
+	var lines []contentLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, contentLine{number: number, text: text})
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into name, parameters and value,
+// honouring quoted parameter values
+func parseLine(text string) (Property, error) {
+	prop := Property{Params: map[string]string{}}
+
+	inQuotes := false
+	nameEnd, valueStart := -1, -1
+	for i := 0; i < len(text) && valueStart < 0; i++ {
+		switch text[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes && nameEnd < 0 {
+				nameEnd = i
+			}
+		case ':':
+			if !inQuotes {
+				valueStart = i + 1
+				if nameEnd < 0 {
+					nameEnd = i
+				}
+			}
+		}
+	}
+	if valueStart < 0 {
+		return prop, fmt.Errorf("malformed content line %q", text)
+	}
+
+	prop.Name = strings.ToUpper(text[:nameEnd])
+	prop.Value = text[valueStart:]
+	if nameEnd < valueStart-1 {
+		for _, param := range splitParams(text[nameEnd+1 : valueStart-1]) {
+			key, value, _ := strings.Cut(param, "=")
+			prop.Params[strings.ToUpper(key)] = value
+		}
+	}
+	return prop, nil
+}
+
+func splitParams(s string) []string {
+	var params []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, s[start:])
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
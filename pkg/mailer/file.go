@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File writes every message to a .eml file in a directory, which mail
+// clients open as is
+type File struct {
+	dir string
+}
+
+// NewFile returns a File backend writing to dir, creating it when needed
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir}, nil
+}
+
+// Send writes the message to a new file named after the time it was sent,
+// so a directory listing is in order
+func (f *File) Send(_ context.Context, msg Message) error {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	file, err := os.OpenFile(filepath.Join(f.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,83 @@
+// Package mailer sends email through a pluggable backend: SMTP for
+// production, a directory of .eml files or the log for development, and
+// memory for tests that check what was sent.
+package mailer
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mailer sends messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Message is an HTML email. From may include a display name, as in
+// "Event Analytics <events@example.com>".
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+}
+
+// WriteTo writes the message in MIME format, as sent over SMTP
+func (m Message) WriteTo(w io.Writer) (int64, error) {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.From)
+	msg.SetHeader("To", m.To)
+	msg.SetHeader("Subject", m.Subject)
+	msg.SetBody("text/html", m.HTML)
+	return msg.WriteTo(w)
+}
+
+// Log writes every message to a logger instead of sending it, so links in
+// verification and reset emails can be followed during development
+type Log struct {
+	logger *log.Logger
+}
+
+func NewLog(logger *log.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Send(_ context.Context, msg Message) error {
+	l.logger.Printf("Mail to %s from %s: %s\n%s", msg.To, msg.From, msg.Subject, msg.HTML)
+	return nil
+}
+
+// Memory keeps every message, for tests
+type Memory struct {
+	lock     sync.Mutex
+	messages []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(_ context.Context, msg Message) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (m *Memory) Messages() []Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Reset forgets the messages sent so far
+func (m *Memory) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.messages = nil
+}
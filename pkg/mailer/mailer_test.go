@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var welcome = Message{
+	From:    "Event Analytics <events@example.com>",
+	To:      "alice@example.com",
+	Subject: "Verify Your Email",
+	HTML:    `<a href="http://localhost/auth/verify?token=abc">Verify</a>`,
+}
+
+// fakeSMTP accepts one connection, answers a plain SMTP exchange and
+// records the commands and the message data
+type fakeSMTP struct {
+	addr     string
+	commands chan string
+	data     chan string
+}
+
+func startFakeSMTP(t *testing.T, extensions ...string) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeSMTP{addr: listener.Addr().String(), commands: make(chan string, 16), data: make(chan string, 1)}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			server.commands <- line
+			switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+			case "EHLO":
+				for _, ext := range extensions {
+					text.PrintfLine("250-%s", ext)
+				}
+				text.PrintfLine("250 localhost")
+			case "AUTH":
+				text.PrintfLine("235 Authenticated")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				lines, _ := text.ReadDotLines()
+				server.data <- strings.Join(lines, "\n")
+				text.PrintfLine("250 Queued")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return server
+}
+
+func (f *fakeSMTP) smtp(t *testing.T, config SMTPConfig) *SMTP {
+	host, port, err := net.SplitHostPort(f.addr)
+	require.NoError(t, err)
+	config.Host = host
+	config.Port, err = strconv.Atoi(port)
+	require.NoError(t, err)
+	return NewSMTP(config)
+}
+
+func TestSMTPSendsMessage(t *testing.T) {
+	server := startFakeSMTP(t, "AUTH PLAIN")
+	mailer := server.smtp(t, SMTPConfig{TLS: TLSNone, Username: "events@example.com", Password: "hunter2"})
+
+	require.NoError(t, mailer.Send(context.Background(), welcome))
+
+	var commands []string
+	for len(server.commands) > 0 {
+		commands = append(commands, <-server.commands)
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte("\x00events@example.com\x00hunter2"))
+	assert.Contains(t, commands, "AUTH PLAIN "+credentials)
+	assert.Contains(t, commands, "MAIL FROM:<events@example.com>")
+	assert.Contains(t, commands, "RCPT TO:<alice@example.com>")
+
+	data := <-server.data
+	assert.Contains(t, data, "Subject: Verify Your Email")
+	assert.Contains(t, data, "To: alice@example.com")
+}
+
+func TestSMTPRequiresStartTLS(t *testing.T) {
+	server := startFakeSMTP(t)
+	mailer := server.smtp(t, SMTPConfig{TLS: TLSStartTLS})
+
+	err := mailer.Send(context.Background(), welcome)
+	assert.ErrorIs(t, err, ErrNoStartTLS)
+}
+
+func TestSMTPRejectsBadAddresses(t *testing.T) {
+	mailer := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: 1, TLS: TLSNone})
+	msg := welcome
+	msg.To = "not an address"
+	assert.Error(t, mailer.Send(context.Background(), msg))
+}
+
+func TestFileWritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFile(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send(context.Background(), welcome))
+	require.NoError(t, mailer.Send(context.Background(), welcome))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2, "every message gets a file of its own")
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	headers, err := textproto.NewReader(bufio.NewReader(f)).ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, "Verify Your Email", headers.Get("Subject"))
+	assert.Equal(t, "alice@example.com", headers.Get("To"))
+}
+
+func TestMemoryCapturesMessages(t *testing.T) {
+	mailer := NewMemory()
+	require.NoError(t, mailer.Send(context.Background(), welcome))
+
+	sent := mailer.Messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, welcome, sent[0])
+
+	sent[0].Subject = "changed"
+	assert.Equal(t, welcome.Subject, mailer.Messages()[0].Subject, "Messages returns a copy")
+
+	mailer.Reset()
+	assert.Empty(t, mailer.Messages())
+}
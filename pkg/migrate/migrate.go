@@ -0,0 +1,338 @@
+// Package migrate applies numbered SQL migrations to a database. Migrations
+// are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql; applied
+// versions are recorded in schema_migrations. Every migration runs in its
+// own transaction, and the lock of the Dialect keeps replicas that boot at
+// the same time from applying one twice.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Table records the applied migrations
+const Table = "schema_migrations"
+
+var (
+	ErrIrreversible = errors.New("migration has no down file")
+	ErrNoDatabase   = errors.New("migrate: no database")
+	ErrNoDialect    = errors.New("migrate: no dialect")
+)
+
+// Dialect is the database specific SQL the migrator needs
+type Dialect interface {
+	// LockMigrations blocks until conn holds the migration lock and returns
+	// a function that releases it
+	LockMigrations(ctx context.Context, conn *sql.Conn) (unlock func(), err error)
+	// TableExists reports whether the table has been created
+	TableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error)
+	// TimestampType is the column type of applied_at
+	TimestampType() string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string // Empty when the migration cannot be undone
+}
+
+// Checksum identifies the up SQL, so edits to applied files are noticed
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // The up file changed after it was applied
+	Missing   bool // Applied, but there is no file for it
+}
+
+// Load reads the migrations in the root of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		parts := fileName.FindStringSubmatch(file.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migrate: %s does not match NNNN_name.up.sql or NNNN_name.down.sql", file.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: %s has an invalid version", file.Name())
+		}
+		body, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		} else if m.Name != parts[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: %s has no up file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations on a database. With DryRun set,
+// Up and Down only report what they would run.
+type Migrator struct {
+	DryRun bool
+
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New returns a migrator for the migrations in fsys
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if db == nil {
+		return nil, ErrNoDatabase
+	}
+	if dialect == nil {
+		return nil, ErrNoDialect
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Migrations returns every known migration, ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if !m.DryRun {
+				err := inTx(ctx, conn, func(tx *sql.Tx) error {
+					if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+						return err
+					}
+					_, err := tx.ExecContext(ctx,
+						"INSERT INTO "+Table+" (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+						migration.Version, migration.Name, migration.Checksum(), time.Now())
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("migrate: %s up: %w", migration, err)
+				}
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and
+// returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migrate: %s: %w", migration, ErrIrreversible)
+			}
+			if !m.DryRun {
+				err := inTx(ctx, conn, func(tx *sql.Tx) error {
+					if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+						return err
+					}
+					_, err := tx.ExecContext(ctx, "DELETE FROM "+Table+" WHERE version = $1", migration.Version)
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("migrate: %s down: %w", migration, err)
+				}
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration with its state, including applied versions
+// whose files are gone
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	done, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = record.checksum != migration.Checksum()
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, record := range done {
+		appliedAt := record.appliedAt
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: version, Name: record.name},
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration lock. A dry
+// run only reads, so it neither locks nor creates the migrations table.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.DryRun {
+		return fn(conn)
+	}
+
+	unlock, err := m.dialect.LockMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer unlock()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+Table+` (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		checksum   varchar(64) NOT NULL,
+		applied_at `+m.dialect.TimestampType()+` NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate: create %s: %w", Table, err)
+	}
+	return fn(conn)
+}
+
+type appliedRecord struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// appliedVersions reads schema_migrations, which may not exist yet
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedRecord, error) {
+	exists, err := m.dialect.TableExists(ctx, conn, Table)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int64]appliedRecord)
+	if !exists {
+		return done, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var record appliedRecord
+		if err := rows.Scan(&version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = record
+	}
+	return done, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
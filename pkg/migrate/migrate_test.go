@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"event-analytics/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON b (c);")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX a;")},
+		"0001_init.up.sql":        {Data: []byte("CREATE TABLE b (c int);")},
+		"README.md":               {Data: []byte("ignored")},
+	}
+
+	loaded, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "0001_init", loaded[0].String())
+	assert.Empty(t, loaded[0].Down, "down files are optional")
+	assert.Equal(t, int64(2), loaded[1].Version)
+	assert.Equal(t, "DROP INDEX a;", loaded[1].Down)
+	assert.NotEqual(t, loaded[0].Checksum(), loaded[1].Checksum())
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":     {"init.up.sql": {Data: []byte("SELECT 1;")}},
+		"missing up":   {"0001_init.down.sql": {Data: []byte("SELECT 1;")}},
+		"zero version": {"0000_init.up.sql": {Data: []byte("SELECT 1;")}},
+		"version clash": {
+			"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAppMigrationsLoad(t *testing.T) {
+	var names [][]string
+	for _, dialect := range migrations.Dialects {
+		fsys, err := migrations.For(dialect)
+		require.NoError(t, err)
+		loaded, err := Load(fsys)
+		require.NoError(t, err)
+		require.NotEmpty(t, loaded)
+
+		var dialectNames []string
+		for i, migration := range loaded {
+			assert.Equal(t, int64(i+1), migration.Version, "%s versions are consecutive", dialect)
+			assert.NotEmpty(t, migration.Down, "%s/%s needs a down file", dialect, migration)
+			dialectNames = append(dialectNames, migration.String())
+		}
+		names = append(names, dialectNames)
+	}
+	for _, dialectNames := range names[1:] {
+		assert.Equal(t, names[0], dialectNames, "every dialect has the same migrations")
+	}
+}
+
+func TestNewRequiresDatabase(t *testing.T) {
+	fsys, err := migrations.For("postgres")
+	require.NoError(t, err)
+	_, err = New(nil, nil, fsys)
+	assert.ErrorIs(t, err, ErrNoDatabase)
+}
@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "/events/{id}/occurrences/{start}/edit", Path("/events/:id/occurrences/:start/edit"))
+	assert.Equal(t, "/static/{filepath}", Path("/static/*filepath"))
+	assert.Equal(t, "/user/dashboard", Path("/user/dashboard"))
+}
+
+type id [4]byte
+
+func (id) MarshalText() ([]byte, error) { return nil, nil }
+
+type base struct {
+	ID        id        `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type item struct {
+	base
+	Name     string            `json:"name" binding:"required,max=50"`
+	Kind     string            `json:"kind" binding:"omitempty,oneof=a b"`
+	Count    *int              `json:"count,omitempty" binding:"min=1"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Parent   *item             `json:"parent"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+type itemForm struct {
+	Name  string   `form:"name" binding:"required"`
+	Days  []string `form:"days"`
+	Other string
+}
+
+func TestJSONSchema(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	ref := d.Schema(item{})
+	assert.Equal(t, "#/components/schemas/item", ref.Ref)
+
+	s := d.Components.Schemas["item"]
+	require.NotNil(t, s)
+	assert.Equal(t, []string{"name"}, s.Required)
+	assert.Equal(t, "string", s.Properties["id"].Type, "text marshalers are strings")
+	assert.Equal(t, "date-time", s.Properties["created_at"].Format, "embedded fields are flattened")
+	assert.Equal(t, 50, *s.Properties["name"].MaxLength)
+	assert.Equal(t, []string{"a", "b"}, s.Properties["kind"].Enum)
+	assert.True(t, s.Properties["count"].Nullable)
+	assert.Equal(t, 1.0, *s.Properties["count"].Minimum)
+	assert.Equal(t, "array", s.Properties["tags"].Type)
+	assert.Equal(t, "object", s.Properties["labels"].Type)
+	assert.Equal(t, "#/components/schemas/item", s.Properties["parent"].AllOf[0].Ref, "recursive types refer to themselves")
+	assert.NotContains(t, s.Properties, "Secret")
+	assert.NotContains(t, s.Properties, "internal")
+}
+
+func TestDefineType(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	d.DefineType(id{}, Format("uuid"))
+	d.Schema(item{})
+	assert.Equal(t, "uuid", d.Components.Schemas["item"].Properties["id"].Format)
+}
+
+func TestFormBody(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	op := d.Route("POST", "/items/:id", "Create").
+		FormField("csrf_token", "", true).
+		Form(itemForm{}).
+		File("image", "", false).
+		Redirect("To the item")
+
+	require.Len(t, op.Parameters, 1)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+
+	require.Contains(t, op.RequestBody.Content, MIMEMultipart)
+	assert.NotContains(t, op.RequestBody.Content, MIMEForm)
+	s := op.RequestBody.Content[MIMEMultipart].Schema
+	assert.Equal(t, []string{"csrf_token", "name"}, s.Required)
+	assert.Equal(t, "array", s.Properties["days"].Type)
+	assert.Equal(t, "binary", s.Properties["image"].Format)
+	assert.NotContains(t, s.Properties, "Other", "untagged fields are not part of the form")
+
+	assert.Contains(t, op.Responses["302"].Headers, "Location")
+	_, ok := d.Operation("POST", "/items/:id")
+	assert.True(t, ok)
+}
@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Operation describes a single route. Its methods return the operation so
+// a route reads as one chained declaration.
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	doc         *Document
+}
+
+// Summarize sets the one line summary
+func (op *Operation) Summarize(summary string) *Operation {
+	op.Summary = summary
+	return op
+}
+
+// ID sets the operationId clients use to name generated methods
+func (op *Operation) ID(id string) *Operation {
+	op.OperationID = id
+	return op
+}
+
+// Describe sets the long description
+func (op *Operation) Describe(description string) *Operation {
+	op.Description = description
+	return op
+}
+
+// Tag groups the operation
+func (op *Operation) Tag(tags ...string) *Operation {
+	op.Tags = append(op.Tags, tags...)
+	return op
+}
+
+// Secured requires the named security scheme with the given scopes. Call
+// it once per alternative scheme.
+func (op *Operation) Secured(scheme string, scopes ...string) *Operation {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	op.Security = append(op.Security, map[string][]string{scheme: scopes})
+	return op
+}
+
+// PathParam describes a path parameter declared by the route
+func (op *Operation) PathParam(name, description string, schema *Schema) *Operation {
+	for i := range op.Parameters {
+		if op.Parameters[i].In == "path" && op.Parameters[i].Name == name {
+			op.Parameters[i].Description = description
+			op.Parameters[i].Schema = schema
+			return op
+		}
+	}
+	panic("openapi: route has no path parameter " + name)
+}
+
+// Query adds an optional query string parameter
+func (op *Operation) Query(name, description string, schema *Schema) *Operation {
+	op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Description: description, Schema: schema})
+	return op
+}
+
+// RequiredQuery adds a required query string parameter
+func (op *Operation) RequiredQuery(name, description string, schema *Schema) *Operation {
+	op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Description: description, Required: true, Schema: schema})
+	return op
+}
+
+// Header adds an optional request header
+func (op *Operation) Header(name, description string) *Operation {
+	op.Parameters = append(op.Parameters, Parameter{Name: name, In: "header", Description: description, Schema: String()})
+	return op
+}
+
+// Form declares an application/x-www-form-urlencoded body built from the
+// form tags of v. Fields already added with FormField or File are kept.
+func (op *Operation) Form(v interface{}) *Operation {
+	fields := op.doc.schemas.form(typeOf(v))
+	body := op.formBody()
+	for name, prop := range fields.Properties {
+		body.Properties[name] = prop
+	}
+	body.Required = append(body.Required, fields.Required...)
+	return op
+}
+
+// FormField adds a field to the form body, creating an empty form body if
+// the operation has none yet
+func (op *Operation) FormField(name, description string, required bool) *Operation {
+	schema := op.formBody()
+	schema.Properties[name] = &Schema{Type: "string", Description: description}
+	if required {
+		schema.Required = append(schema.Required, name)
+	}
+	return op
+}
+
+// File adds a file upload field, switching the body to multipart/form-data
+func (op *Operation) File(name, description string, required bool) *Operation {
+	schema := op.formBody()
+	schema.Properties[name] = &Schema{Type: "string", Format: "binary", Description: description}
+	if required {
+		schema.Required = append(schema.Required, name)
+	}
+	if media, ok := op.RequestBody.Content[MIMEForm]; ok {
+		delete(op.RequestBody.Content, MIMEForm)
+		op.RequestBody.Content[MIMEMultipart] = media
+	}
+	return op
+}
+
+func (op *Operation) formBody() *Schema {
+	if op.RequestBody != nil {
+		for _, contentType := range []string{MIMEForm, MIMEMultipart} {
+			if media, ok := op.RequestBody.Content[contentType]; ok {
+				return media.Schema
+			}
+		}
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	op.setBody(MIMEForm, schema)
+	return schema
+}
+
+// JSONBody declares a required JSON body with the schema of v
+func (op *Operation) JSONBody(v interface{}) *Operation {
+	op.setBody(MIMEJSON, op.doc.schemas.json(typeOf(v)))
+	return op
+}
+
+func (op *Operation) setBody(contentType string, schema *Schema) {
+	op.RequestBody = &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{contentType: {Schema: schema}},
+	}
+}
+
+func (op *Operation) response(status int, description string) *Response {
+	code := strconv.Itoa(status)
+	resp, ok := op.Responses[code]
+	if !ok {
+		resp = &Response{Description: description}
+		op.Responses[code] = resp
+	} else if description != "" {
+		resp.Description += "; " + description
+	}
+	return resp
+}
+
+// Returns documents a response with a body of the given content type.
+// An empty schema documents an opaque body.
+func (op *Operation) Returns(status int, contentType, description string, schema *Schema) *Operation {
+	resp := op.response(status, description)
+	if resp.Content == nil {
+		resp.Content = make(map[string]MediaType)
+	}
+	resp.Content[contentType] = MediaType{Schema: schema}
+	return op
+}
+
+// JSON documents a JSON response with the schema of v
+func (op *Operation) JSON(status int, description string, v interface{}) *Operation {
+	return op.Returns(status, MIMEJSON, description, op.doc.schemas.json(typeOf(v)))
+}
+
+// HTML documents a rendered page
+func (op *Operation) HTML(status int, description string) *Operation {
+	return op.Returns(status, MIMEHTML, description, String())
+}
+
+// Redirect documents a 302 to the Location header. Repeated calls add to
+// the description, since every outcome of a form shares the status.
+func (op *Operation) Redirect(description string) *Operation {
+	resp := op.response(http.StatusFound, description)
+	resp.Headers = map[string]*Header{
+		"Location": {Description: "Page to load next, error messages are passed in the error query parameter", Schema: String()},
+	}
+	return op
+}
+
+// Empty documents a response without a body
+func (op *Operation) Empty(status int, description string) *Operation {
+	op.response(status, description)
+	return op
+}
+
+// ResponseHeader documents a header of an already declared response
+func (op *Operation) ResponseHeader(status int, name, description string) *Operation {
+	resp := op.response(status, "")
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]*Header)
+	}
+	resp.Headers[name] = &Header{Description: description, Schema: String()}
+	return op
+}
+
+// String returns a string schema
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer returns an integer schema with optional bounds, 0 meaning unset
+func Integer(min, max int) *Schema {
+	s := &Schema{Type: "integer"}
+	if min != 0 {
+		m := float64(min)
+		s.Minimum = &m
+	}
+	if max != 0 {
+		m := float64(max)
+		s.Maximum = &m
+	}
+	return s
+}
+
+// Enum returns a string schema restricted to values
+func Enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+// Format returns a string schema with a format such as uuid or date-time
+func Format(format string) *Schema {
+	return &Schema{Type: "string", Format: format}
+}
@@ -0,0 +1,245 @@
+package openapi
+
+import (
+	"encoding"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	fileHeaderType    = reflect.TypeOf(multipart.FileHeader{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaRegistry turns Go types into schemas. Named structs used in JSON
+// become components referenced by $ref; form structs are always inlined
+// since they describe a single request body.
+type schemaRegistry struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	overrides  map[reflect.Type]*Schema
+}
+
+func newSchemaRegistry(components map[string]*Schema) *schemaRegistry {
+	return &schemaRegistry{
+		components: components,
+		names:      make(map[reflect.Type]string),
+		overrides:  make(map[reflect.Type]*Schema),
+	}
+}
+
+// DefineType sets the schema used for values of v's type, for types whose
+// JSON form reflection cannot see, such as custom MarshalJSON methods
+func (d *Document) DefineType(v interface{}, schema *Schema) {
+	d.schemas.overrides[typeOf(v)] = schema
+}
+
+// Schema returns the JSON schema of v's type, registering components as
+// needed, for use in parameters and hand written responses
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemas.json(typeOf(v))
+}
+
+func typeOf(v interface{}) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	return reflect.TypeOf(v)
+}
+
+func (r *schemaRegistry) json(t reflect.Type) *Schema {
+	return r.schema(t, "json", true)
+}
+
+func (r *schemaRegistry) form(t reflect.Type) *Schema {
+	return r.schema(t, "form", false)
+}
+
+func (r *schemaRegistry) schema(t reflect.Type, tag string, refs bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if s, ok := r.overrides[t]; ok {
+		copied := *s
+		return &copied
+	}
+
+	switch {
+	case t == timeType:
+		return Format("date-time")
+	case t == fileHeaderType:
+		return Format("binary")
+	case t.Kind() != reflect.Ptr && t.Implements(textMarshalerType),
+		t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textMarshalerType):
+		return String()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := r.schema(t.Elem(), tag, refs)
+		if elem.Ref != "" {
+			return &Schema{AllOf: []*Schema{elem}, Nullable: true}
+		}
+		elem.Nullable = true
+		return elem
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Format("byte")
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem(), tag, refs)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem(), tag, refs)}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if !refs || t.Name() == "" {
+			return r.object(t, tag, refs)
+		}
+		return &Schema{Ref: "#/components/schemas/" + r.component(t)}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// component registers the named struct t and returns its component name
+func (r *schemaRegistry) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := r.components[name]; taken {
+		// Same name in another package, qualify with the package name
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = string(unicode.ToUpper(rune(pkg[0]))) + pkg[1:] + name
+	}
+
+	// Register before building so recursive types terminate
+	r.names[t] = name
+	r.components[name] = &Schema{}
+	*r.components[name] = *r.object(t, "json", true)
+	return name
+}
+
+// object builds the schema of a struct from its tag (json or form) and its
+// gin binding rules
+func (r *schemaRegistry) object(t reflect.Type, tag string, refs bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(s, t, tag, refs)
+	return s
+}
+
+func (r *schemaRegistry) addFields(s *Schema, t reflect.Type, tag string, refs bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, hasTag := parseTag(field.Tag.Get(tag))
+		if name == "-" {
+			continue
+		}
+
+		// Untagged embedded structs are flattened, as encoding/json does
+		if field.Anonymous && !hasTag {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addFields(s, ft, tag, refs)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if tag == "form" && !hasTag {
+			// gin binds untagged fields by name, but forms here are
+			// always tagged, so an untagged field is not part of the form
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := r.schema(field.Type, tag, refs)
+		if applyBinding(prop, field.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		if strings.Contains(opts, "string") && prop.Type != "string" {
+			prop = String()
+		}
+		s.Properties[name] = prop
+	}
+}
+
+func parseTag(tag string) (name, opts string, ok bool) {
+	if tag == "" {
+		return "", "", false
+	}
+	name, opts, _ = strings.Cut(tag, ",")
+	return name, opts, true
+}
+
+// applyBinding copies the validator rules that have an OpenAPI equivalent
+// onto s and reports whether the field is required
+func applyBinding(s *Schema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "min", "max", "gte", "lte", "len":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			applyBound(s, key, n)
+		}
+	}
+	return required
+}
+
+func applyBound(s *Schema, key string, n int) {
+	f := float64(n)
+	switch s.Type {
+	case "string":
+		if key == "min" || key == "gte" || key == "len" {
+			s.MinLength = &n
+		}
+		if key == "max" || key == "lte" || key == "len" {
+			s.MaxLength = &n
+		}
+	case "integer", "number":
+		if key == "min" || key == "gte" || key == "len" {
+			s.Minimum = &f
+		}
+		if key == "max" || key == "lte" || key == "len" {
+			s.Maximum = &f
+		}
+	}
+}
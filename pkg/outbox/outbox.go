@@ -0,0 +1,330 @@
+// Package outbox sends email through a table of queued messages. Callers
+// write a message with Enqueue in the transaction of the change that
+// triggers it; a Dispatcher sends due messages with a pool of workers,
+// retries failures with exponential backoff and marks a message dead after
+// its last attempt. Delivery is at least once: a worker that dies after
+// sending but before recording it sends the message again. The body of a
+// sent or dead message is emptied, so the verification and reset links in
+// it do not outlive the delivery.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/pkg/mailer"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by Requeue when no unsent message with a body
+// has the id
+var ErrNotFound = errors.New("outbox: no unsent message with that id")
+
+// Options tune a Dispatcher. Zero fields take the defaults of NewDispatcher.
+type Options struct {
+	Workers      int           // Messages sent at the same time
+	MaxAttempts  int           // Attempts before a message is marked dead
+	RetryDelay   time.Duration // Wait after the first failure, doubled after each further one
+	MaxDelay     time.Duration // Longest wait between attempts
+	PollInterval time.Duration // How often due messages are looked for
+	Lease        time.Duration // How long a claimed message is hidden from other dispatchers
+	BatchSize    int           // Messages claimed per poll
+}
+
+// Enqueue writes an email to the outbox through db, which should be the
+// transaction of the change the email is about. It is sent right away.
+func Enqueue(db *gorm.DB, to, subject, html string) error {
+	return db.Create(&models.OutboxMessage{
+		Recipient:     to,
+		Subject:       subject,
+		Body:          html,
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Requeue makes a pending message, or a dead one that still has its body,
+// due now with a fresh set of attempts
+func Requeue(db *gorm.DB, id uint) error {
+	result := db.Model(&models.OutboxMessage{}).
+		Where("id = ? AND status <> ? AND body <> ''", id, models.OutboxSent).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List returns the newest messages with status, or of every status when
+// it is empty
+func List(db *gorm.DB, status string, limit int) ([]models.OutboxMessage, error) {
+	query := db.Order("created_at DESC, id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var messages []models.OutboxMessage
+	err := query.Find(&messages).Error
+	return messages, err
+}
+
+// Scrub empties the body of every sent and dead message that still has
+// one, such as messages finished before bodies were emptied on delivery,
+// and returns how many were scrubbed
+func Scrub(db *gorm.DB) (int64, error) {
+	result := db.Model(&models.OutboxMessage{}).
+		Where("status IN ? AND body <> ''", []string{models.OutboxSent, models.OutboxDead}).
+		Update("body", "")
+	return result.RowsAffected, result.Error
+}
+
+// Counts returns the number of messages of every status
+func Counts(db *gorm.DB) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := db.Model(&models.OutboxMessage{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	counts := make(map[string]int64, len(models.OutboxStatuses))
+	for _, status := range models.OutboxStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, err
+}
+
+// Backoff returns the wait after the given number of failed attempts:
+// base, doubled for every attempt after the first, at most max
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// Dispatcher sends the due messages of the outbox through a Mailer
+type Dispatcher struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+	from   string
+	opts   Options
+	now    func() time.Time
+
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDispatcher returns a Dispatcher sending from the address from. It
+// sends with 4 workers and gives up after 8 attempts, retrying after 30
+// seconds at first and at most an hour apart, unless opts say otherwise.
+func NewDispatcher(db *gorm.DB, m mailer.Mailer, from string, opts Options) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 30 * time.Second
+	}
+	if opts.MaxDelay < opts.RetryDelay {
+		opts.MaxDelay = time.Hour
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10 * opts.Workers
+	}
+	return &Dispatcher{
+		db:     db,
+		mailer: m,
+		from:   from,
+		opts:   opts,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the background poller
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Notify asks the poller to look for due messages now rather than at the
+// next poll, after a message has been enqueued and committed. A nil
+// Dispatcher ignores it.
+func (d *Dispatcher) Notify() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the poller once the messages being sent are done. Messages
+// not yet sent stay in the outbox for the next start.
+func (d *Dispatcher) Close() {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+	})
+	<-d.done
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back, there may be more due
+		for {
+			sent, err := d.RunOnce(context.Background())
+			if err != nil {
+				log.Printf("Outbox: failed to poll for due messages: %v", err)
+			}
+			if err != nil || sent < d.opts.BatchSize {
+				break
+			}
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// RunOnce sends a batch of due messages with the worker pool, waits for
+// them and returns how many were attempted
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	var ids []uint
+	err := d.db.Model(&models.OutboxMessage{}).
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, d.now()).
+		Order("next_attempt_at").
+		Limit(d.opts.BatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan uint)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	attempted := 0
+	for i := 0; i < d.opts.Workers && i < len(ids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				ok, err := d.deliver(ctx, id)
+				if err != nil {
+					log.Printf("Outbox: message %d: %v", id, err)
+				}
+				if ok {
+					lock.Lock()
+					attempted++
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+	wg.Wait()
+	return attempted, nil
+}
+
+// deliver claims a message, sends it and records the outcome. It reports
+// false when another dispatcher claimed the message first.
+func (d *Dispatcher) deliver(ctx context.Context, id uint) (bool, error) {
+	msg, err := d.claim(id)
+	if err != nil || msg == nil {
+		return false, err
+	}
+
+	sendErr := d.mailer.Send(ctx, mailer.Message{
+		From:    d.from,
+		To:      msg.Recipient,
+		Subject: msg.Subject,
+		HTML:    msg.Body,
+	})
+
+	now := d.now()
+	updates := map[string]interface{}{}
+	switch {
+	case sendErr == nil:
+		updates["status"] = models.OutboxSent
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+		updates["body"] = ""
+	case msg.Attempts >= d.opts.MaxAttempts:
+		updates["status"] = models.OutboxDead
+		updates["last_error"] = sendErr.Error()
+		updates["body"] = ""
+		log.Printf("Outbox: giving up on message %d to %s after %d attempts: %v", msg.ID, msg.Recipient, msg.Attempts, sendErr)
+	default:
+		updates["next_attempt_at"] = now.Add(Backoff(msg.Attempts, d.opts.RetryDelay, d.opts.MaxDelay))
+		updates["last_error"] = sendErr.Error()
+	}
+	return true, d.db.Model(&models.OutboxMessage{}).Where("id = ?", msg.ID).Updates(updates).Error
+}
+
+// claim counts an attempt and pushes the message's next attempt past the
+// lease, so no other worker picks it up while it is being sent and a
+// worker that dies leaves it to be retried. It returns nil when the
+// message is no longer due.
+func (d *Dispatcher) claim(id uint) (*models.OutboxMessage, error) {
+	now := d.now()
+	result := d.db.Model(&models.OutboxMessage{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, models.OutboxPending, now).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(d.opts.Lease),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	var msg models.OutboxMessage
+	if err := d.db.First(&msg, id).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
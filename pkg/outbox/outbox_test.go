@@ -0,0 +1,264 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-analytics/migrations"
+	"event-analytics/models"
+	"event-analytics/pkg/dialect"
+	"event-analytics/pkg/mailer"
+	"event-analytics/pkg/migrate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns an in-memory SQLite database with the app's schema
+func newTestDB(t *testing.T) *gorm.DB {
+	d, db, err := dialect.Open("sqlite::memory:", &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	fsys, err := migrations.For(d.Name())
+	require.NoError(t, err)
+	migrator, err := migrate.New(sqlDB, d, fsys)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+// flakyMailer fails its next failures sends, then records the messages
+type flakyMailer struct {
+	lock     sync.Mutex
+	failures int
+	sent     []mailer.Message
+}
+
+func (f *flakyMailer) Send(_ context.Context, msg mailer.Message) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("421 service not available")
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// newTestDispatcher returns a dispatcher whose clock only moves when
+// advance is called
+func newTestDispatcher(db *gorm.DB, m mailer.Mailer, opts Options) (*Dispatcher, func(time.Duration)) {
+	d := NewDispatcher(db, m, "Event Analytics <events@example.com>", opts)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	return d, func(by time.Duration) { now = now.Add(by) }
+}
+
+func enqueue(t *testing.T, db *gorm.DB, to string) models.OutboxMessage {
+	require.NoError(t, Enqueue(db, to, "Verify Your Email", "<p>Hi</p>"))
+	var msg models.OutboxMessage
+	require.NoError(t, db.Where("recipient = ?", to).Order("id DESC").First(&msg).Error)
+	return msg
+}
+
+func reload(t *testing.T, db *gorm.DB, id uint) models.OutboxMessage {
+	var msg models.OutboxMessage
+	require.NoError(t, db.First(&msg, id).Error)
+	return msg
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 7, want: 32 * time.Minute},
+		{attempts: 8, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Backoff(tt.attempts, 30*time.Second, time.Hour), "after %d attempts", tt.attempts)
+	}
+}
+
+func TestDispatcherSendsDueMessages(t *testing.T) {
+	db := newTestDB(t)
+	mail := &flakyMailer{}
+	d, _ := newTestDispatcher(db, mail, Options{Workers: 2})
+
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		require.NoError(t, Enqueue(db, to, "Verify Your Email", "<p>Hi</p>"))
+	}
+	// Enqueue stamps the wall clock, which is ahead of the dispatcher's
+	require.NoError(t, db.Model(&models.OutboxMessage{}).Where("1 = 1").Update("next_attempt_at", d.now()).Error)
+
+	sent, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Len(t, mail.sent, 3)
+	assert.Equal(t, "Event Analytics <events@example.com>", mail.sent[0].From)
+
+	counts, err := Counts(db)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{models.OutboxPending: 0, models.OutboxSent: 3, models.OutboxDead: 0}, counts)
+	assert.Equal(t, "<p>Hi</p>", mail.sent[0].HTML)
+	var bodies int64
+	require.NoError(t, db.Model(&models.OutboxMessage{}).Where("body <> ''").Count(&bodies).Error)
+	assert.Zero(t, bodies, "sent messages lose their body")
+
+	sent, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent, "sent messages are not sent again")
+}
+
+func TestDispatcherBacksOffThenMarksDead(t *testing.T) {
+	db := newTestDB(t)
+	mail := &flakyMailer{failures: 100}
+	d, advance := newTestDispatcher(db, mail, Options{MaxAttempts: 3, RetryDelay: time.Minute, MaxDelay: time.Hour})
+	msg := enqueue(t, db, "a@example.com")
+	require.NoError(t, db.Model(&msg).Update("next_attempt_at", d.now()).Error)
+
+	_, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+	msg = reload(t, db, msg.ID)
+	assert.Equal(t, models.OutboxPending, msg.Status)
+	assert.Equal(t, 1, msg.Attempts)
+	assert.Equal(t, "421 service not available", msg.LastError)
+	assert.True(t, msg.NextAttemptAt.Equal(d.now().Add(time.Minute)), "retried after the first delay")
+
+	// Not due yet
+	advance(59 * time.Second)
+	sent, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	advance(time.Second)
+	_, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	msg = reload(t, db, msg.ID)
+	assert.Equal(t, 2, msg.Attempts)
+	assert.True(t, msg.NextAttemptAt.Equal(d.now().Add(2*time.Minute)), "the delay doubles")
+
+	advance(2 * time.Minute)
+	_, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	msg = reload(t, db, msg.ID)
+	assert.Equal(t, models.OutboxDead, msg.Status)
+	assert.Equal(t, 3, msg.Attempts)
+	assert.Empty(t, msg.Body, "dead messages lose their body")
+
+	advance(24 * time.Hour)
+	sent, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent, "dead messages are not retried")
+	assert.Empty(t, mail.sent)
+}
+
+func TestRequeue(t *testing.T) {
+	db := newTestDB(t)
+	mail := &flakyMailer{failures: 2}
+	d, _ := newTestDispatcher(db, mail, Options{MaxAttempts: 2})
+	msg := enqueue(t, db, "a@example.com")
+	require.NoError(t, db.Model(&msg).Update("next_attempt_at", d.now()).Error)
+
+	_, err := d.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, reload(t, db, msg.ID).Attempts)
+
+	require.NoError(t, Requeue(db, msg.ID))
+	msg = reload(t, db, msg.ID)
+	assert.Equal(t, models.OutboxPending, msg.Status)
+	assert.Zero(t, msg.Attempts)
+
+	// Requeue makes the message due on the wall clock. Two more failures
+	// are needed to kill it, the attempts started over.
+	d.now = time.Now
+	_, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxPending, reload(t, db, msg.ID).Status)
+	require.NoError(t, Requeue(db, msg.ID))
+	_, err = d.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxSent, reload(t, db, msg.ID).Status)
+	assert.Len(t, mail.sent, 1)
+
+	assert.ErrorIs(t, Requeue(db, msg.ID), ErrNotFound, "sent messages can't be requeued")
+	assert.ErrorIs(t, Requeue(db, msg.ID+1), ErrNotFound)
+
+	// Dead messages can only be requeued while they still have their body
+	dead := models.OutboxMessage{Recipient: "b@example.com", Subject: "Hi", Body: "<p>Hi</p>", Status: models.OutboxDead, NextAttemptAt: time.Now()}
+	require.NoError(t, db.Create(&dead).Error)
+	require.NoError(t, Requeue(db, dead.ID))
+	require.NoError(t, db.Model(&dead).Updates(map[string]interface{}{"status": models.OutboxDead, "body": ""}).Error)
+	assert.ErrorIs(t, Requeue(db, dead.ID), ErrNotFound)
+}
+
+func TestScrub(t *testing.T) {
+	db := newTestDB(t)
+	for _, status := range []string{models.OutboxPending, models.OutboxSent, models.OutboxDead} {
+		require.NoError(t, db.Create(&models.OutboxMessage{
+			Recipient: status + "@example.com", Subject: "Reset your password",
+			Body: `<a href="https://example.com/auth/reset-password?token=secret">Reset</a>`, Status: status, NextAttemptAt: time.Now(),
+		}).Error)
+	}
+
+	scrubbed, err := Scrub(db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), scrubbed)
+
+	var pending models.OutboxMessage
+	require.NoError(t, db.Where("status = ?", models.OutboxPending).First(&pending).Error)
+	assert.Contains(t, pending.Body, "token=secret", "pending messages keep their body")
+
+	scrubbed, err = Scrub(db)
+	require.NoError(t, err)
+	assert.Zero(t, scrubbed)
+}
+
+func TestClaimLeasesTheMessage(t *testing.T) {
+	db := newTestDB(t)
+	d, advance := newTestDispatcher(db, &flakyMailer{}, Options{Lease: time.Minute})
+	msg := enqueue(t, db, "a@example.com")
+	require.NoError(t, db.Model(&msg).Update("next_attempt_at", d.now()).Error)
+
+	claimed, err := d.claim(msg.ID)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	claimed, err = d.claim(msg.ID)
+	require.NoError(t, err)
+	assert.Nil(t, claimed, "a claimed message is hidden from other workers")
+
+	// A worker that died mid-send leaves the message to be retried
+	advance(time.Minute)
+	claimed, err = d.claim(msg.ID)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, 2, claimed.Attempts)
+}
+
+func TestEnqueueRollsBackWithTheTransaction(t *testing.T) {
+	db := newTestDB(t)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, Enqueue(tx, "a@example.com", "Verify Your Email", "<p>Hi</p>"))
+		return errors.New("user not created")
+	})
+	require.Error(t, err)
+
+	messages, err := List(db, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
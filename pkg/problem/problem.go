@@ -0,0 +1,45 @@
+// Package problem writes RFC 9457 problem details (application/problem+json)
+// so every API error has the same shape.
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// Details is an RFC 9457 problem details object
+type Details struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"` // Field name to message, for validation failures
+}
+
+// New returns a problem for status with the standard status text as title
+func New(status int, detail string) *Details {
+	return &Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Abort writes p as the response and stops the handler chain
+func Abort(c *gin.Context, p *Details) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// AbortWithStatus writes a problem for status with detail as its explanation
+func AbortWithStatus(c *gin.Context, status int, detail string) {
+	Abort(c, New(status, detail))
+}
@@ -0,0 +1,112 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomonMatchesTheStandard(t *testing.T) {
+	// "HELLO WORLD" as a 1-M code, from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsDivisor(10)))
+}
+
+func TestLayoutsFillEveryVersion(t *testing.T) {
+	for v := 1; v <= maxVersion; v++ {
+		// Modules left once the function patterns are drawn, as in the standard
+		raw := (16*v+128)*v + 64
+		if v >= 2 {
+			n := v/7 + 2
+			raw -= (25*n-10)*n - 55
+			if v >= 7 {
+				raw -= 36
+			}
+		}
+		l := layouts[v]
+		total := l.dataCodewords() + (l.shortBlocks+l.longBlocks)*l.ecPerBlock
+		assert.Equal(t, raw/8, total, "version %d", v)
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	assert.Nil(t, alignmentPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	assert.Equal(t, []int{6, 26, 46, 66}, alignmentPositions(14))
+	assert.Equal(t, []int{6, 34, 62, 90}, alignmentPositions(20))
+}
+
+func TestDataCodewordsArePadded(t *testing.T) {
+	got := dataCodewords(1, []byte("ab"))
+	require.Len(t, got, 16)
+	// Mode 0100, count 00000010, 'a', 'b', terminator, then alternating pads
+	assert.Equal(t, []byte{0x40, 0x26, 0x16, 0x20, 0xEC, 0x11, 0xEC}, got[:7])
+}
+
+func TestEncodePicksTheSmallestVersion(t *testing.T) {
+	code, err := Encode("hello")
+	require.NoError(t, err)
+	assert.Equal(t, 1, code.Version())
+	assert.Equal(t, 21, code.Size())
+
+	uri := "otpauth://totp/Event%20Analytics:someone@example.com?algorithm=SHA1&digits=6&issuer=Event+Analytics&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	code, err = Encode(uri)
+	require.NoError(t, err)
+	assert.Equal(t, 8, code.Version(), "149 bytes need version 8")
+
+	_, err = Encode(strings.Repeat("x", 700))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestEncodeDrawsFinderAndFormat(t *testing.T) {
+	code, err := Encode("otpauth://totp/x")
+	require.NoError(t, err)
+	n := code.Size()
+
+	// The three finder patterns, dark ring, light ring, dark core
+	for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		x, y := corner[0], corner[1]
+		assert.True(t, code.Dark(x, y))
+		assert.False(t, code.Dark(x+1, y+1))
+		assert.True(t, code.Dark(x+3, y+3))
+	}
+	assert.True(t, code.Dark(8, n-8), "the dark module")
+
+	// Both copies of the format bits agree and decode to level M
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= b(code.Dark(8, i)) << i
+	}
+	first |= b(code.Dark(8, 7))<<6 | b(code.Dark(8, 8))<<7 | b(code.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= b(code.Dark(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= b(code.Dark(n-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= b(code.Dark(8, n-15+i)) << i
+	}
+	assert.Equal(t, first, second)
+	assert.Equal(t, 0b00, (first^0x5412)>>13, "level M")
+}
+
+func TestSVG(t *testing.T) {
+	code, err := Encode("hello")
+	require.NoError(t, err)
+	svg := code.SVG(4)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29" width="116" height="116"`))
+	assert.Contains(t, svg, "M4 4h1v1h-1z", "the top left module sits inside the quiet zone")
+	assert.True(t, strings.HasSuffix(svg, "</svg>"))
+}
+
+func b(dark bool) int {
+	if dark {
+		return 1
+	}
+	return 0
+}
@@ -2,7 +2,6 @@ package ratelimit
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -10,23 +9,64 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// Limiter allows limit events per key in a fixed window. The counts live
+// in Redis, so every instance shares them.
+type Limiter struct {
+	client *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+// NewLimiter returns a limiter counting under Redis keys starting with
+// prefix
+func NewLimiter(client *redis.Client, prefix string, limit int, window time.Duration) *Limiter {
+	return &Limiter{client: client, prefix: prefix, limit: limit, window: window}
+}
+
+// Allow counts an event for key and reports whether it is within the limit
+func (l *Limiter) Allow(ctx context.Context, key string) (bool, error) {
+	key = l.prefix + key
+	count, err := l.client.Incr(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		if err := l.client.Expire(ctx, key, l.window).Err(); err != nil {
+			return false, err
+		}
+	}
+	return count <= int64(l.limit), nil
+}
+
+// Exhausted reports whether the limit for key is used up, without
+// counting an event
+func (l *Limiter) Exhausted(ctx context.Context, key string) (bool, error) {
+	count, err := l.client.Get(ctx, l.prefix+key).Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(l.limit), nil
+}
+
+// Reset forgets the events counted for key
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.client.Del(ctx, l.prefix+key).Err()
+}
+
 func Middleware(client *redis.Client, limit int, window time.Duration) gin.HandlerFunc {
+	limiter := NewLimiter(client, "ratelimit:", limit, window)
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		key := fmt.Sprintf("ratelimit:%s", ip)
-		ctx := context.Background()
-
-		count, err := client.Incr(ctx, key).Result()
+		allowed, err := limiter.Allow(context.Background(), c.ClientIP())
 		if err != nil {
 			c.Next()
 			return
 		}
 
-		if count == 1 {
-			client.Expire(ctx, key, window)
-		}
-
-		if count > int64(limit) {
+		if !allowed {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
 			return
 		}
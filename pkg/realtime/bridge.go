@@ -0,0 +1,344 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Channel is the Redis pub/sub channel the instances exchange messages on
+const Channel = "realtime:messages"
+
+const (
+	relayBuffer    = 1024
+	relayTimeout   = 2 * time.Second
+	seenCapacity   = 4096
+	healthInterval = 30 * time.Second
+	minBackoff     = 100 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// Subscription is an open subscription to the shared channel
+type Subscription interface {
+	// Receive blocks until the next message arrives or the subscription fails
+	Receive(ctx context.Context) ([]byte, error)
+	Close() error
+}
+
+// envelope wraps a hub message on the shared channel
+type envelope struct {
+	Node    string          `json:"node"`
+	ID      string          `json:"id"`
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Bridge relays messages published on one instance's hub to the hubs of
+// every other instance. Outgoing messages are queued and published from a
+// background goroutine so publishers never wait on Redis; incoming
+// messages are delivered to local subscribers only. Messages published
+// while the subscription is down are not replayed.
+type Bridge struct {
+	hub       *Hub
+	node      string
+	seq       uint64
+	publish   func(ctx context.Context, data []byte) error
+	subscribe func(ctx context.Context) (Subscription, error)
+	seen      *seenSet
+
+	outgoing chan []byte
+	dropped  uint64
+
+	lock    sync.Mutex
+	current Subscription // Guarded by lock
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewBridge returns a bridge for hub that publishes with publish and
+// listens through subscribe. Call Start to begin relaying.
+func NewBridge(hub *Hub, publish func(ctx context.Context, data []byte) error, subscribe func(ctx context.Context) (Subscription, error)) *Bridge {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bridge{
+		hub:       hub,
+		node:      newNodeID(),
+		publish:   publish,
+		subscribe: subscribe,
+		seen:      newSeenSet(seenCapacity),
+		outgoing:  make(chan []byte, relayBuffer),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// NewRedisBridge returns a bridge for hub over the Redis pub/sub channel
+func NewRedisBridge(client *redis.Client, hub *Hub) *Bridge {
+	publish := func(ctx context.Context, data []byte) error {
+		return client.Publish(ctx, Channel, data).Err()
+	}
+	subscribe := func(ctx context.Context) (Subscription, error) {
+		pubsub := client.Subscribe(ctx, Channel)
+		// Wait for the confirmation so a dead server is noticed right away
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			return nil, err
+		}
+		return &redisSubscription{pubsub: pubsub}, nil
+	}
+	return NewBridge(hub, publish, subscribe)
+}
+
+// Node returns the id this instance stamps on its messages
+func (b *Bridge) Node() string {
+	return b.node
+}
+
+// Dropped returns the number of outgoing messages discarded because the
+// queue was full
+func (b *Bridge) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Start attaches the bridge to the hub and launches the publisher and
+// subscriber goroutines
+func (b *Bridge) Start() {
+	b.hub.SetRelay(b)
+	b.done.Add(2)
+	go b.runPublisher()
+	go b.runSubscriber()
+}
+
+// Close detaches the bridge from the hub and stops both goroutines
+func (b *Bridge) Close() {
+	b.closeOnce.Do(func() {
+		b.hub.SetRelay(nil)
+		b.cancel()
+		b.lock.Lock()
+		if b.current != nil {
+			b.current.Close()
+		}
+		b.lock.Unlock()
+		b.done.Wait()
+	})
+}
+
+// Relay queues an encoded message for the other instances without blocking
+func (b *Bridge) Relay(topic string, payload []byte) {
+	seq := atomic.AddUint64(&b.seq, 1)
+	data, err := json.Marshal(envelope{
+		Node:    b.node,
+		ID:      b.node + "-" + strconv.FormatUint(seq, 10),
+		Topic:   topic,
+		Payload: payload,
+	})
+	if err != nil {
+		return
+	}
+
+	select {
+	case b.outgoing <- data:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+func (b *Bridge) runPublisher() {
+	defer b.done.Done()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case data := <-b.outgoing:
+			ctx, cancel := context.WithTimeout(b.ctx, relayTimeout)
+			if err := b.publish(ctx, data); err != nil && b.ctx.Err() == nil {
+				log.Printf("Failed to relay realtime message: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// runSubscriber keeps a subscription open, reconnecting with exponential
+// backoff whenever it fails
+func (b *Bridge) runSubscriber() {
+	defer b.done.Done()
+	backoff := minBackoff
+	for b.ctx.Err() == nil {
+		sub, err := b.subscribe(b.ctx)
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Realtime subscription failed, retrying in %s: %v", backoff, err)
+			if !b.sleep(backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		if !b.setCurrent(sub) {
+			sub.Close()
+			return
+		}
+		backoff = minBackoff
+
+		err = b.receive(sub)
+		b.setCurrent(nil)
+		sub.Close()
+		if b.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Realtime subscription lost, reconnecting: %v", err)
+		if !b.sleep(backoff) {
+			return
+		}
+	}
+}
+
+func (b *Bridge) receive(sub Subscription) error {
+	for {
+		data, err := sub.Receive(b.ctx)
+		if err != nil {
+			return err
+		}
+		b.handle(data)
+	}
+}
+
+// handle delivers a message from another instance to local subscribers.
+// The instance's own messages were delivered when they were published and
+// a message already seen is delivered only once.
+func (b *Bridge) handle(data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Topic == "" {
+		return
+	}
+	if env.Node == b.node || !b.seen.Add(env.ID) {
+		return
+	}
+	b.hub.Deliver(env.Topic, env.Payload)
+}
+
+// setCurrent records the open subscription so Close can interrupt it. It
+// reports false when the bridge is already closing.
+func (b *Bridge) setCurrent(sub Subscription) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if sub != nil && b.ctx.Err() != nil {
+		return false
+	}
+	b.current = sub
+	return true
+}
+
+// sleep waits for d and reports false if the bridge was closed meanwhile
+func (b *Bridge) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-b.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func newNodeID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// seenSet remembers the most recent message ids, forgetting the oldest
+// once it is full
+type seenSet struct {
+	lock  sync.Mutex
+	ids   map[string]struct{}
+	order []string
+	next  int
+}
+
+func newSeenSet(capacity int) *seenSet {
+	return &seenSet{
+		ids:   make(map[string]struct{}, capacity),
+		order: make([]string, capacity),
+	}
+}
+
+// Add records id and reports whether it was new
+func (s *seenSet) Add(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	if old := s.order[s.next]; old != "" {
+		delete(s.ids, old)
+	}
+	s.order[s.next] = id
+	s.next = (s.next + 1) % len(s.order)
+	s.ids[id] = struct{}{}
+	return true
+}
+
+// redisSubscription reads messages from a Redis pub/sub connection and
+// pings it when idle so a silently dropped connection is noticed
+type redisSubscription struct {
+	pubsub *redis.PubSub
+}
+
+var errNoPong = errors.New("realtime: redis did not answer ping")
+
+func (s *redisSubscription) Receive(ctx context.Context) ([]byte, error) {
+	pinged := false
+	for {
+		msg, err := s.pubsub.ReceiveTimeout(ctx, healthInterval)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return nil, err
+			}
+			if pinged {
+				return nil, errNoPong
+			}
+			if err := s.pubsub.Ping(ctx); err != nil {
+				return nil, err
+			}
+			pinged = true
+			continue
+		}
+
+		pinged = false
+		if m, ok := msg.(*redis.Message); ok {
+			return []byte(m.Payload), nil
+		}
+	}
+}
+
+func (s *redisSubscription) Close() error {
+	return s.pubsub.Close()
+}
@@ -0,0 +1,191 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBroker stands in for a Redis channel shared by several bridges
+type memoryBroker struct {
+	lock    sync.Mutex
+	subs    map[*memorySubscription]struct{}
+	fail    int // Number of subscribe attempts still to reject
+	attempt int
+}
+
+type memorySubscription struct {
+	broker   *memoryBroker
+	messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{subs: make(map[*memorySubscription]struct{})}
+}
+
+func (m *memoryBroker) publish(_ context.Context, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for sub := range m.subs {
+		sub.messages <- data
+	}
+	return nil
+}
+
+func (m *memoryBroker) subscribe(_ context.Context) (Subscription, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.attempt++
+	if m.fail > 0 {
+		m.fail--
+		return nil, errors.New("connection refused")
+	}
+	sub := &memorySubscription{broker: m, messages: make(chan []byte, 16), closed: make(chan struct{})}
+	m.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// drop severs every open subscription, as a Redis restart would
+func (m *memoryBroker) drop() {
+	m.lock.Lock()
+	subs := m.subs
+	m.subs = make(map[*memorySubscription]struct{})
+	m.lock.Unlock()
+	for sub := range subs {
+		sub.Close()
+	}
+}
+
+func (m *memoryBroker) subscribers() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.subs)
+}
+
+func (s *memorySubscription) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-s.messages:
+		return data, nil
+	case <-s.closed:
+		return nil, errors.New("connection closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() {
+		s.broker.lock.Lock()
+		delete(s.broker.subs, s)
+		s.broker.lock.Unlock()
+		close(s.closed)
+	})
+	return nil
+}
+
+func startBridge(t *testing.T, broker *memoryBroker) (*Hub, *Bridge) {
+	hub := NewHub(10)
+	bridge := NewBridge(hub, broker.publish, broker.subscribe)
+	bridge.Start()
+	t.Cleanup(bridge.Close)
+	return hub, bridge
+}
+
+func receive(t *testing.T, client *Client) Message {
+	t.Helper()
+	select {
+	case payload := <-client.Send():
+		var msg Message
+		require.NoError(t, json.Unmarshal(payload, &msg))
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func TestBridgeRelaysBetweenInstances(t *testing.T) {
+	broker := newMemoryBroker()
+	hubA, _ := startBridge(t, broker)
+	hubB, _ := startBridge(t, broker)
+	require.Eventually(t, func() bool { return broker.subscribers() == 2 }, time.Second, 5*time.Millisecond)
+
+	a := hubA.Register("a", 4)
+	b := hubB.Register("b", 4)
+	require.NoError(t, hubA.Subscribe(a, TopicDashboard))
+	require.NoError(t, hubB.Subscribe(b, TopicDashboard))
+
+	require.NoError(t, hubA.Publish(Message{Topic: TopicDashboard, Type: "event.created"}))
+
+	assert.Equal(t, "event.created", receive(t, a).Type)
+	assert.Equal(t, "event.created", receive(t, b).Type)
+
+	// The publishing instance must not get its own message back from Redis
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, a.Send())
+}
+
+func TestBridgeDeliversEachMessageOnce(t *testing.T) {
+	hub := NewHub(10)
+	bridge := NewBridge(hub, nil, nil)
+	client := hub.Register("a", 4)
+	require.NoError(t, hub.Subscribe(client, TopicDashboard))
+
+	data, err := json.Marshal(envelope{Node: "other", ID: "other-1", Topic: TopicDashboard, Payload: json.RawMessage(`{"topic":"dashboard","type":"event.updated"}`)})
+	require.NoError(t, err)
+
+	bridge.handle(data)
+	bridge.handle(data)
+	assert.Equal(t, "event.updated", receive(t, client).Type)
+	assert.Empty(t, client.Send(), "duplicate is dropped")
+
+	own, err := json.Marshal(envelope{Node: bridge.Node(), ID: bridge.Node() + "-1", Topic: TopicDashboard, Payload: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	bridge.handle(own)
+	bridge.handle([]byte("not json"))
+	assert.Empty(t, client.Send())
+}
+
+func TestBridgeReconnects(t *testing.T) {
+	broker := newMemoryBroker()
+	broker.fail = 2
+	hubA, _ := startBridge(t, broker)
+	hubB, _ := startBridge(t, broker)
+	require.Eventually(t, func() bool { return broker.subscribers() == 2 }, 5*time.Second, 5*time.Millisecond)
+
+	broker.drop()
+	require.Eventually(t, func() bool { return broker.subscribers() == 2 }, 5*time.Second, 5*time.Millisecond)
+
+	b := hubB.Register("b", 4)
+	require.NoError(t, hubB.Subscribe(b, EventTopic("1")))
+	require.NoError(t, hubA.Publish(Message{Topic: EventTopic("1"), Type: "event.deleted"}))
+	assert.Equal(t, "event.deleted", receive(t, b).Type)
+}
+
+func TestBridgeCloseDetachesRelay(t *testing.T) {
+	broker := newMemoryBroker()
+	hub, bridge := startBridge(t, broker)
+	require.Eventually(t, func() bool { return broker.subscribers() == 1 }, time.Second, 5*time.Millisecond)
+
+	bridge.Close()
+	assert.Equal(t, 0, broker.subscribers())
+	require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: "event.created"}))
+	assert.Zero(t, len(bridge.outgoing), "nothing is queued after close")
+}
+
+func TestSeenSetForgetsOldest(t *testing.T) {
+	seen := newSeenSet(2)
+	assert.True(t, seen.Add("a"))
+	assert.True(t, seen.Add("b"))
+	assert.False(t, seen.Add("a"))
+	assert.True(t, seen.Add("c"))
+	assert.True(t, seen.Add("a"), "a was evicted by c")
+}
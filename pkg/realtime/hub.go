@@ -0,0 +1,421 @@
+// Package realtime fans out messages to subscribers of named topics, such
+// as the WebSocket connections of the dashboard. Every client has its own
+// bounded send buffer; a client that falls behind is disconnected instead
+// of slowing down publishers.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Topics
+const (
+	TopicDashboard   = "dashboard"
+	topicEventPrefix = "event:"
+	topicUserPrefix  = "user:"
+)
+
+// EventTopic is the topic of changes to a single event
+func EventTopic(eventID string) string {
+	return topicEventPrefix + eventID
+}
+
+// UserTopic is the topic of notifications for a single user
+func UserTopic(userID string) string {
+	return topicUserPrefix + userID
+}
+
+// ParseTopic splits a topic into its kind (dashboard, event or user) and id
+func ParseTopic(topic string) (kind, id string, err error) {
+	switch {
+	case topic == TopicDashboard:
+		return TopicDashboard, "", nil
+	case strings.HasPrefix(topic, topicEventPrefix) && len(topic) > len(topicEventPrefix):
+		return "event", topic[len(topicEventPrefix):], nil
+	case strings.HasPrefix(topic, topicUserPrefix) && len(topic) > len(topicUserPrefix):
+		return "user", topic[len(topicUserPrefix):], nil
+	}
+	return "", "", ErrUnknownTopic
+}
+
+var (
+	ErrUnknownTopic     = errors.New("unknown topic")
+	ErrTooManyTopics    = errors.New("too many subscriptions")
+	ErrClientDisconnect = errors.New("client disconnected")
+)
+
+// Message is what subscribers receive. ID is set when the hub keeps a
+// replay buffer.
+type Message struct {
+	ID    string      `json:"id,omitempty"`
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Client is one subscriber. Messages are queued on Send until the
+// connection's writer picks them up.
+type Client struct {
+	UserID string
+	send   chan []byte
+	topics map[string]bool // Guarded by the hub lock
+	closed bool            // Guarded by the hub lock
+}
+
+// Send returns the client's outgoing queue. It is closed when the hub
+// drops the client.
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+// Hub tracks which clients listen to which topics
+type Hub struct {
+	lock      sync.RWMutex
+	topics    map[string]map[*Client]struct{}
+	clients   map[*Client]struct{}
+	maxTopics int
+	relay     Relay        // Guarded by lock
+	replay    ReplayBuffer // Guarded by lock
+	closed    bool         // Guarded by lock
+
+	// With a replay buffer, published messages are recorded and sent by
+	// a background goroutine so publishers never wait for Redis
+	recording chan queued   // Guarded by lock
+	stop      chan struct{} // Closed by Close to stop the recorder
+	recorded  chan struct{} // Closed when the recorder has stopped
+}
+
+// Messages waiting to be recorded before Publish sends them without an id
+const recordQueue = 1024
+
+// queued is a message waiting for the recorder, or a Flush marker
+type queued struct {
+	msg     Message
+	flushed chan struct{}
+}
+
+// Relay forwards published messages to the hubs of other instances
+type Relay interface {
+	Relay(topic string, payload []byte)
+}
+
+// NewHub returns a hub that allows each client maxTopics subscriptions
+func NewHub(maxTopics int) *Hub {
+	return &Hub{
+		topics:    make(map[string]map[*Client]struct{}),
+		clients:   make(map[*Client]struct{}),
+		maxTopics: maxTopics,
+	}
+}
+
+// Register adds a client with a send buffer of the given size. After Close
+// the client comes back already disconnected.
+func (h *Hub) Register(userID string, buffer int) *Client {
+	client := &Client{
+		UserID: userID,
+		send:   make(chan []byte, buffer),
+		topics: make(map[string]bool),
+	}
+	h.lock.Lock()
+	if h.closed {
+		client.closed = true
+		close(client.send)
+	} else {
+		h.clients[client] = struct{}{}
+	}
+	h.lock.Unlock()
+	return client
+}
+
+// Unregister removes the client from every topic and closes its queue.
+// It is safe to call more than once.
+func (h *Hub) Unregister(client *Client) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if client.closed {
+		return
+	}
+	for topic := range client.topics {
+		h.removeLocked(topic, client)
+	}
+	delete(h.clients, client)
+	client.closed = true
+	close(client.send)
+}
+
+// Subscribe adds the client to topic
+func (h *Hub) Subscribe(client *Client, topic string) error {
+	if _, _, err := ParseTopic(topic); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if client.closed {
+		return ErrClientDisconnect
+	}
+	if client.topics[topic] {
+		return nil
+	}
+	if len(client.topics) >= h.maxTopics {
+		return ErrTooManyTopics
+	}
+
+	subscribers, ok := h.topics[topic]
+	if !ok {
+		subscribers = make(map[*Client]struct{})
+		h.topics[topic] = subscribers
+	}
+	subscribers[client] = struct{}{}
+	client.topics[topic] = true
+	return nil
+}
+
+// Unsubscribe removes the client from topic
+func (h *Hub) Unsubscribe(client *Client, topic string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.removeLocked(topic, client)
+}
+
+func (h *Hub) removeLocked(topic string, client *Client) {
+	delete(client.topics, topic)
+	if subscribers, ok := h.topics[topic]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// SetRelay installs the relay that Publish forwards messages to, or
+// removes it when relay is nil
+func (h *Hub) SetRelay(relay Relay) {
+	h.lock.Lock()
+	h.relay = relay
+	h.lock.Unlock()
+}
+
+// SetReplay installs the buffer that Publish records messages in, or
+// removes it when replay is nil. The first buffer starts the recorder.
+func (h *Hub) SetReplay(replay ReplayBuffer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.replay = replay
+	if replay != nil && h.recording == nil && !h.closed {
+		h.recording = make(chan queued, recordQueue)
+		h.stop = make(chan struct{})
+		h.recorded = make(chan struct{})
+		go h.record(h.recording, h.stop, h.recorded)
+	}
+}
+
+// Replay returns the hub's replay buffer, if any
+func (h *Hub) Replay() ReplayBuffer {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.replay
+}
+
+// Publish sends msg to every subscriber of its topic, on this instance and,
+// through the relay, on every other one. With a replay buffer the message
+// is handed to the recorder, which records it and sends it with the
+// buffer's id; if recording fails, or the recorder is too far behind, it
+// is still sent, without an id.
+func (h *Hub) Publish(msg Message) error {
+	h.lock.RLock()
+	if h.recording != nil && !h.closed {
+		select {
+		case h.recording <- queued{msg: msg}:
+			h.lock.RUnlock()
+			return nil
+		default:
+			log.Printf("Realtime replay queue is full, sending message without an id")
+		}
+	}
+	h.lock.RUnlock()
+	return h.send(msg)
+}
+
+// Flush waits until the recorder has sent every message published so far
+func (h *Hub) Flush() {
+	h.lock.RLock()
+	recording, recorded := h.recording, h.recorded
+	h.lock.RUnlock()
+	if recording == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case recording <- queued{flushed: flushed}:
+	case <-recorded:
+		return
+	}
+	select {
+	case <-flushed:
+	case <-recorded:
+	}
+}
+
+// record appends queued messages to the replay buffer, in order, and sends
+// them with their ids. Once stopped it empties the queue and returns.
+func (h *Hub) record(queue <-chan queued, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case item := <-queue:
+			h.recordOne(item)
+		case <-stop:
+			for {
+				select {
+				case item := <-queue:
+					h.recordOne(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) recordOne(item queued) {
+	if item.flushed != nil {
+		close(item.flushed)
+		return
+	}
+
+	msg := item.msg
+	if replay := h.Replay(); replay != nil {
+		msg.ID = ""
+		recorded, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("Failed to encode realtime message: %v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+		id, err := replay.Append(ctx, msg.Topic, recorded)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to record realtime message for replay: %v", err)
+		}
+		msg.ID = id
+	}
+	if err := h.send(msg); err != nil {
+		log.Printf("Failed to encode realtime message: %v", err)
+	}
+}
+
+// send delivers msg locally and hands it to the relay
+func (h *Hub) send(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	h.Deliver(msg.Topic, payload)
+
+	h.lock.RLock()
+	relay := h.relay
+	h.lock.RUnlock()
+	if relay != nil {
+		relay.Relay(msg.Topic, payload)
+	}
+	return nil
+}
+
+// Close sends the messages still waiting for the recorder, then
+// disconnects every client so their WebSocket and SSE streams end. Call it
+// when the server shuts down; nothing is delivered afterwards.
+func (h *Hub) Close() {
+	h.lock.Lock()
+	if h.closed {
+		h.lock.Unlock()
+		return
+	}
+	h.closed = true
+	if h.stop != nil {
+		close(h.stop)
+	}
+	recorded := h.recorded
+	h.lock.Unlock()
+
+	if recorded != nil {
+		<-recorded
+	}
+
+	h.lock.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.lock.RUnlock()
+	for _, client := range clients {
+		h.Unregister(client)
+	}
+}
+
+// Deliver queues an encoded message for the subscribers of topic. Clients
+// whose queue is full are disconnected rather than waited for.
+func (h *Hub) Deliver(topic string, payload []byte) {
+	var slow []*Client
+
+	h.lock.RLock()
+	for client := range h.topics[topic] {
+		select {
+		case client.send <- payload:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	h.lock.RUnlock()
+
+	for _, client := range slow {
+		h.Unregister(client)
+	}
+}
+
+// Reply queues msg for a single client, such as a subscription
+// acknowledgement, and reports whether it was queued
+func (h *Hub) Reply(client *Client, msg Message) bool {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return false
+	}
+
+	h.lock.RLock()
+	if client.closed {
+		h.lock.RUnlock()
+		return false
+	}
+	select {
+	case client.send <- payload:
+		h.lock.RUnlock()
+		return true
+	default:
+		h.lock.RUnlock()
+		h.Unregister(client)
+		return false
+	}
+}
+
+// Subscribers returns the number of clients listening to topic
+func (h *Hub) Subscribers(topic string) int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Clients returns the number of registered clients
+func (h *Hub) Clients() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.clients)
+}
@@ -0,0 +1,134 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		topic   string
+		kind    string
+		id      string
+		wantErr bool
+	}{
+		{topic: "dashboard", kind: "dashboard"},
+		{topic: "event:42", kind: "event", id: "42"},
+		{topic: "user:7", kind: "user", id: "7"},
+		{topic: "event:", wantErr: true},
+		{topic: "admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			kind, id, err := ParseTopic(tt.topic)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownTopic)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.id, id)
+		})
+	}
+}
+
+func TestPublishReachesSubscribers(t *testing.T) {
+	hub := NewHub(10)
+	a := hub.Register("a", 4)
+	b := hub.Register("b", 4)
+
+	require.NoError(t, hub.Subscribe(a, EventTopic("1")))
+	require.NoError(t, hub.Subscribe(b, TopicDashboard))
+	assert.Equal(t, 1, hub.Subscribers(EventTopic("1")))
+
+	require.NoError(t, hub.Publish(Message{Topic: EventTopic("1"), Type: "event.updated", Data: map[string]string{"title": "Go"}}))
+
+	var msg Message
+	require.NoError(t, json.Unmarshal(<-a.Send(), &msg))
+	assert.Equal(t, "event:1", msg.Topic)
+	assert.Equal(t, "event.updated", msg.Type)
+	assert.Empty(t, b.Send(), "other topics are not delivered")
+
+	hub.Unsubscribe(a, EventTopic("1"))
+	assert.Equal(t, 0, hub.Subscribers(EventTopic("1")))
+}
+
+// blockingReplay holds every Append until release is closed
+type blockingReplay struct {
+	memoryReplay
+	release chan struct{}
+}
+
+func (b *blockingReplay) Append(ctx context.Context, topic string, payload []byte) (string, error) {
+	<-b.release
+	return b.memoryReplay.Append(ctx, topic, payload)
+}
+
+func TestPublishDoesNotWaitForReplay(t *testing.T) {
+	hub := NewHub(10)
+	replay := &blockingReplay{memoryReplay: memoryReplay{size: 10}, release: make(chan struct{})}
+	hub.SetReplay(replay)
+	client := hub.Register("a", 4)
+	require.NoError(t, hub.Subscribe(client, TopicDashboard))
+
+	published := make(chan struct{})
+	go func() {
+		for _, typ := range []string{"event.created", "event.updated"} {
+			assert.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: typ}))
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish waited for the replay buffer")
+	}
+
+	close(replay.release)
+	hub.Flush()
+	for _, want := range []string{"1000-1", "1000-2"} {
+		var msg Message
+		require.NoError(t, json.Unmarshal(<-client.Send(), &msg))
+		assert.Equal(t, want, msg.ID, "messages keep their order")
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	hub := NewHub(10)
+	slow := hub.Register("slow", 1)
+	fast := hub.Register("fast", 8)
+	require.NoError(t, hub.Subscribe(slow, TopicDashboard))
+	require.NoError(t, hub.Subscribe(fast, TopicDashboard))
+
+	for i := 0; i < 3; i++ {
+		hub.Deliver(TopicDashboard, []byte(`{}`))
+	}
+
+	assert.Equal(t, 1, hub.Subscribers(TopicDashboard))
+	assert.Equal(t, 1, hub.Clients())
+	assert.Len(t, fast.Send(), 3)
+
+	// The slow client's queue is drained, then closed
+	<-slow.Send()
+	_, ok := <-slow.Send()
+	assert.False(t, ok)
+	assert.ErrorIs(t, hub.Subscribe(slow, TopicDashboard), ErrClientDisconnect)
+
+	hub.Unregister(slow) // Safe to repeat
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	hub := NewHub(2)
+	client := hub.Register("a", 1)
+	require.NoError(t, hub.Subscribe(client, EventTopic("1")))
+	require.NoError(t, hub.Subscribe(client, EventTopic("2")))
+	require.NoError(t, hub.Subscribe(client, EventTopic("2")), "resubscribing is a no-op")
+	assert.ErrorIs(t, hub.Subscribe(client, EventTopic("3")), ErrTooManyTopics)
+	assert.ErrorIs(t, hub.Subscribe(client, "nope"), ErrUnknownTopic)
+}
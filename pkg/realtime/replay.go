@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ReplayKey is the Redis stream that keeps recent messages for resuming
+const ReplayKey = "realtime:replay"
+
+// Longest the recorder waits for the replay buffer
+const replayTimeout = 500 * time.Millisecond
+
+// Entry is a published message kept for replay
+type Entry struct {
+	ID      string
+	Topic   string
+	Payload []byte
+}
+
+// ReplayBuffer keeps the most recent messages of every topic, shared by all
+// instances, so a reconnecting client can catch up on what it missed
+type ReplayBuffer interface {
+	// Append stores a message and returns its id. Ids increase with every
+	// message.
+	Append(ctx context.Context, topic string, payload []byte) (string, error)
+
+	// Since returns the messages after lastID. complete is false when
+	// lastID has already left the buffer and messages may be missing.
+	Since(ctx context.Context, lastID string) (entries []Entry, complete bool, err error)
+}
+
+// RedisReplay is a ReplayBuffer on a capped Redis stream. The stream is
+// dropped when no message was published for ttl.
+type RedisReplay struct {
+	client *redis.Client
+	key    string
+	maxLen int64
+	ttl    time.Duration
+}
+
+// NewRedisReplay keeps about maxLen messages for at most ttl
+func NewRedisReplay(client *redis.Client, maxLen int64, ttl time.Duration) *RedisReplay {
+	return &RedisReplay{client: client, key: ReplayKey, maxLen: maxLen, ttl: ttl}
+}
+
+func (r *RedisReplay) Append(ctx context.Context, topic string, payload []byte) (string, error) {
+	var add *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		add = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.key,
+			MaxLen: r.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"topic": topic, "payload": payload},
+		})
+		pipe.Expire(ctx, r.key, r.ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return add.Val(), nil
+}
+
+func (r *RedisReplay) Since(ctx context.Context, lastID string) ([]Entry, bool, error) {
+	if _, _, ok := parseID(lastID); !ok {
+		return nil, false, nil
+	}
+
+	// The range includes lastID itself, which proves nothing was trimmed
+	// between it and the first entry returned
+	messages, err := r.client.XRangeN(ctx, r.key, lastID, "+", r.maxLen+1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(messages) == 0 || messages[0].ID != lastID {
+		return nil, false, nil
+	}
+
+	entries := make([]Entry, 0, len(messages)-1)
+	for _, msg := range messages[1:] {
+		topic, _ := msg.Values["topic"].(string)
+		payload, _ := msg.Values["payload"].(string)
+		entries = append(entries, Entry{ID: msg.ID, Topic: topic, Payload: []byte(payload)})
+	}
+	return entries, true, nil
+}
+
+// parseID splits a stream id of the form <milliseconds>-<sequence>
+func parseID(id string) (ms, seq uint64, ok bool) {
+	i := strings.IndexByte(id, '-')
+	if i < 0 {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(id[:i], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err = strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
+
+// idAfter reports whether stream id a comes after b. Ids that cannot be
+// parsed never count as later.
+func idAfter(a, b string) bool {
+	aMS, aSeq, ok := parseID(a)
+	if !ok {
+		return false
+	}
+	bMS, bSeq, ok := parseID(b)
+	if !ok {
+		return true
+	}
+	return aMS > bMS || (aMS == bMS && aSeq > bSeq)
+}
@@ -0,0 +1,118 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// Comment lines sent while idle keep proxies from closing the stream
+	heartbeatPeriod = 25 * time.Second
+
+	// Milliseconds browsers wait before reconnecting
+	retryMillis = 3000
+)
+
+// ErrStreamingUnsupported is returned when the response cannot be flushed
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+// ServeSSE streams the messages of topics to w as Server-Sent Events until
+// the request ends. The topics must already be authorized. Every message
+// carries its replay id, so when the browser reconnects with lastEventID
+// the messages it missed are sent first. If they have left the replay
+// buffer a "reset" event tells the client to reload instead.
+//
+// An error is returned, before anything is written, when the client cannot
+// be subscribed.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request, userID string, topics []string, lastEventID string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return ErrStreamingUnsupported
+	}
+
+	// Subscribe before reading the replay buffer so nothing published in
+	// between is lost; duplicates are skipped by id below
+	client := h.Register(userID, sendBuffer)
+	defer h.Unregister(client)
+	subscribed := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if err := h.Subscribe(client, topic); err != nil {
+			return err
+		}
+		subscribed[topic] = true
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+
+	lastID := ""
+	if replay := h.Replay(); replay != nil && lastEventID != "" {
+		entries, complete, err := replay.Since(r.Context(), lastEventID)
+		switch {
+		case err != nil && !errors.Is(err, context.Canceled):
+			log.Printf("SSE: replay failed for user %s: %v", userID, err)
+			writeEvent(w, "", "reset", []byte("{}"))
+		case !complete:
+			writeEvent(w, "", "reset", []byte("{}"))
+		}
+		for _, entry := range entries {
+			if subscribed[entry.Topic] {
+				writeEvent(w, entry.ID, "", entry.Payload)
+			}
+			lastID = entry.ID
+		}
+		if lastID == "" && complete {
+			lastID = lastEventID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case payload, ok := <-client.Send():
+			if !ok {
+				// Dropped by the hub for falling behind; the browser
+				// reconnects and resumes from its last id
+				return nil
+			}
+			var msg struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(payload, &msg)
+			if lastID != "" && msg.ID != "" && !idAfter(msg.ID, lastID) {
+				continue
+			}
+			writeEvent(w, msg.ID, "", payload)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one event. JSON payloads never contain newlines, so
+// a single data line is enough.
+func writeEvent(w http.ResponseWriter, id, event string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
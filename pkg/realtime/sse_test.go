@@ -0,0 +1,230 @@
+package realtime
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryReplay is a ReplayBuffer that keeps the last size messages
+type memoryReplay struct {
+	lock    sync.Mutex
+	size    int
+	seq     int
+	entries []Entry
+}
+
+func (m *memoryReplay) Append(_ context.Context, topic string, payload []byte) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.seq++
+	id := fmt.Sprintf("1000-%d", m.seq)
+	m.entries = append(m.entries, Entry{ID: id, Topic: topic, Payload: payload})
+	if len(m.entries) > m.size {
+		m.entries = m.entries[len(m.entries)-m.size:]
+	}
+	return id, nil
+}
+
+func (m *memoryReplay) Since(_ context.Context, lastID string) ([]Entry, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i, entry := range m.entries {
+		if entry.ID == lastID {
+			return append([]Entry(nil), m.entries[i+1:]...), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// openStream connects to an SSE server and returns a function that reads
+// the next event
+func openStream(t *testing.T, url, lastEventID string) func() sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev.Data != "" {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				ev.ID = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				ev.Event = line[7:]
+			case strings.HasPrefix(line, "data: "):
+				ev.Data = line[6:]
+			}
+		}
+	}()
+
+	return func() sseEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-events:
+			require.True(t, ok, "stream closed")
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return sseEvent{}
+		}
+	}
+}
+
+func sseServer(t *testing.T, hub *Hub) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := hub.ServeSSE(w, r, "u1", r.URL.Query()["topic"], r.Header.Get("Last-Event-ID"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServeSSEStreamsTopicMessages(t *testing.T) {
+	hub := NewHub(10)
+	hub.SetReplay(&memoryReplay{size: 10})
+	server := sseServer(t, hub)
+
+	next := openStream(t, server.URL+"?topic=dashboard", "")
+	require.Eventually(t, func() bool { return hub.Subscribers(TopicDashboard) == 1 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, hub.Publish(Message{Topic: EventTopic("1"), Type: "event.updated"}))
+	require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: "event.created"}))
+
+	ev := next()
+	assert.Equal(t, "1000-2", ev.ID, "only subscribed topics are streamed")
+	var msg Message
+	require.NoError(t, json.Unmarshal([]byte(ev.Data), &msg))
+	assert.Equal(t, "1000-2", msg.ID)
+	assert.Equal(t, "event.created", msg.Type)
+}
+
+func TestServeSSEResumesFromLastEventID(t *testing.T) {
+	hub := NewHub(10)
+	hub.SetReplay(&memoryReplay{size: 10})
+	server := sseServer(t, hub)
+
+	for _, typ := range []string{"event.created", "event.updated", "event.deleted"} {
+		require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: typ}))
+	}
+	require.NoError(t, hub.Publish(Message{Topic: EventTopic("1"), Type: "event.updated"}))
+	hub.Flush()
+
+	next := openStream(t, server.URL+"?topic=dashboard", "1000-1")
+	ev := next()
+	assert.Equal(t, "1000-2", ev.ID)
+	assert.Contains(t, ev.Data, "event.updated")
+	ev = next()
+	assert.Equal(t, "1000-3", ev.ID)
+	assert.Contains(t, ev.Data, "event.deleted")
+
+	require.Eventually(t, func() bool { return hub.Subscribers(TopicDashboard) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: "event.expired"}))
+	assert.Equal(t, "1000-5", next().ID)
+}
+
+func TestServeSSEResetsWhenGapIsTooOld(t *testing.T) {
+	hub := NewHub(10)
+	hub.SetReplay(&memoryReplay{size: 2})
+	server := sseServer(t, hub)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: "event.updated"}))
+	}
+	hub.Flush()
+
+	next := openStream(t, server.URL+"?topic=dashboard", "1000-1")
+	assert.Equal(t, "reset", next().Event)
+}
+
+func TestHubCloseEndsStreams(t *testing.T) {
+	hub := NewHub(10)
+	hub.SetReplay(&memoryReplay{size: 10})
+	server := sseServer(t, hub)
+
+	next := openStream(t, server.URL+"?topic=dashboard", "")
+	require.Eventually(t, func() bool { return hub.Subscribers(TopicDashboard) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, hub.Publish(Message{Topic: TopicDashboard, Type: "event.created"}))
+
+	done := make(chan struct{})
+	go func() {
+		hub.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	assert.Equal(t, "1000-1", next().ID, "queued messages are sent before the streams end")
+	assert.Equal(t, 0, hub.Clients())
+
+	// The handler returned, so the server can shut down
+	shutdown, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	server.Config.SetKeepAlivesEnabled(false)
+	assert.NoError(t, server.Config.Shutdown(shutdown))
+
+	client := hub.Register("u2", 1)
+	_, ok := <-client.Send()
+	assert.False(t, ok, "clients registered after Close are disconnected")
+}
+
+func TestServeSSERejectsTooManyTopics(t *testing.T) {
+	hub := NewHub(1)
+	server := sseServer(t, hub)
+
+	resp, err := http.Get(server.URL + "?topic=dashboard&topic=event:1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, hub.Clients())
+}
+
+func TestIDAfter(t *testing.T) {
+	assert.True(t, idAfter("1000-2", "1000-1"))
+	assert.True(t, idAfter("1001-0", "1000-9"))
+	assert.False(t, idAfter("1000-1", "1000-1"))
+	assert.False(t, idAfter("999-5", "1000-0"))
+	assert.False(t, idAfter("junk", "1000-0"))
+	assert.True(t, idAfter("1000-0", "junk"))
+}
@@ -0,0 +1,127 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong from the peer
+	pongWait = 60 * time.Second
+
+	// Pings are sent before the peer's read deadline passes
+	pingPeriod = pongWait * 9 / 10
+
+	// Client requests are small JSON objects
+	maxRequestSize = 1024
+
+	// Messages queued per connection before it counts as too slow
+	sendBuffer = 64
+)
+
+// Request is a message from a WebSocket client
+type Request struct {
+	Action string `json:"action"` // subscribe or unsubscribe
+	Topic  string `json:"topic"`
+}
+
+// Authorizer decides whether the connection's user may subscribe to topic
+type Authorizer func(topic string) error
+
+// ServeWebSocket runs an upgraded connection until it closes. Subscription
+// requests are checked with authorize before they reach the hub.
+func (h *Hub) ServeWebSocket(conn *websocket.Conn, userID string, authorize Authorizer) {
+	client := h.Register(userID, sendBuffer)
+	go h.writePump(conn, client)
+	h.readPump(conn, client, authorize)
+}
+
+// readPump handles subscription requests until the connection fails
+func (h *Hub) readPump(conn *websocket.Conn, client *Client, authorize Authorizer) {
+	defer func() {
+		h.Unregister(client)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(maxRequestSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket: read failed for user %s: %v", client.UserID, err)
+			}
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			h.replyError(client, "", "invalid request")
+			continue
+		}
+
+		switch req.Action {
+		case "subscribe":
+			if err := authorize(req.Topic); err != nil {
+				h.replyError(client, req.Topic, err.Error())
+				continue
+			}
+			if err := h.Subscribe(client, req.Topic); err != nil {
+				h.replyError(client, req.Topic, err.Error())
+				continue
+			}
+			h.Reply(client, Message{Topic: req.Topic, Type: "subscribed"})
+		case "unsubscribe":
+			h.Unsubscribe(client, req.Topic)
+			h.Reply(client, Message{Topic: req.Topic, Type: "unsubscribed"})
+		default:
+			h.replyError(client, req.Topic, "unknown action")
+		}
+	}
+}
+
+func (h *Hub) replyError(client *Client, topic, message string) {
+	h.Reply(client, Message{Topic: topic, Type: "error", Data: map[string]string{"error": message}})
+}
+
+// writePump is the only writer of the connection. It sends queued
+// messages and keepalive pings, and closes the connection when the hub
+// drops the client.
+func (h *Hub) writePump(conn *websocket.Conn, client *Client) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-client.Send():
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				h.Unregister(client)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				h.Unregister(client)
+				return
+			}
+		}
+	}
+}
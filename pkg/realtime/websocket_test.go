@@ -0,0 +1,52 @@
+package realtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeWebSocket(t *testing.T) {
+	hub := NewHub(10)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		hub.ServeWebSocket(conn, "u1", func(topic string) error {
+			if topic == UserTopic("u2") {
+				return errors.New("permission denied")
+			}
+			return nil
+		})
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteJSON(Request{Action: "subscribe", Topic: UserTopic("u2")}))
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+
+	require.NoError(t, conn.WriteJSON(Request{Action: "subscribe", Topic: EventTopic("9")}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "subscribed", msg.Type)
+
+	require.NoError(t, hub.Publish(Message{Topic: EventTopic("9"), Type: "event.deleted"}))
+	var update Message
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, Message{Topic: "event:9", Type: "event.deleted"}, update)
+
+	conn.Close()
+	assert.Eventually(t, func() bool { return hub.Clients() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,74 @@
+package rrule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var frequencyUnits = map[Frequency]string{
+	Daily:   "day",
+	Weekly:  "week",
+	Monthly: "month",
+}
+
+// Describe returns a short English summary such as "Every 2 weeks on Mon, Wed, 10 times"
+func (r *Rule) Describe() string {
+	var b strings.Builder
+
+	unit := frequencyUnits[r.Freq]
+	if r.Interval > 1 {
+		fmt.Fprintf(&b, "Every %d %ss", r.Interval, unit)
+	} else {
+		b.WriteString("Every " + unit)
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.Day.String()[:3]
+			if day.N != 0 {
+				days[i] = ordinal(day.N) + " " + days[i]
+			}
+		}
+		b.WriteString(" on " + strings.Join(days, ", "))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = ordinal(day)
+		}
+		b.WriteString(" on the " + strings.Join(days, ", "))
+	}
+
+	switch {
+	case r.Count == 1:
+		b.WriteString(", once")
+	case r.Count > 1:
+		fmt.Fprintf(&b, ", %d times", r.Count)
+	case !r.Until.IsZero():
+		b.WriteString(", until " + r.Until.Format("Jan 2, 2006"))
+	}
+	return b.String()
+}
+
+func ordinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < 0:
+		return ordinal(-n) + " to last"
+	case n%100 >= 11 && n%100 <= 13:
+		return strconv.Itoa(n) + "th"
+	}
+
+	switch n % 10 {
+	case 1:
+		return strconv.Itoa(n) + "st"
+	case 2:
+		return strconv.Itoa(n) + "nd"
+	case 3:
+		return strconv.Itoa(n) + "rd"
+	}
+	return strconv.Itoa(n) + "th"
+}
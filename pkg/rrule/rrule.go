@@ -0,0 +1,406 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// event series: DAILY, WEEKLY and MONTHLY frequencies with INTERVAL, COUNT,
+// UNTIL, BYDAY and BYMONTHDAY.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxPeriods bounds how many periods an iterator scans, so rules that can
+// never match (e.g. BYMONTHDAY=30 every 12 months from February) cannot
+// loop forever.
+const maxPeriods = 50000
+
+const untilFormat = "20060102T150405Z"
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Weekday is a BYDAY entry. N selects the nth occurrence of the weekday in
+// the month (negative counts from the end); zero means every such weekday.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+func (w Weekday) String() string {
+	if w.N != 0 {
+		return strconv.Itoa(w.N) + weekdayNames[w.Day]
+	}
+	return weekdayNames[w.Day]
+}
+
+// Rule is a parsed RRULE
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 when unbounded by count
+	Until      time.Time // zero when unbounded by date; inclusive
+	ByDay      []Weekday
+	ByMonthDay []int
+}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// A leading "RRULE:" is accepted.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			switch Frequency(strings.ToUpper(val)) {
+			case Daily, Weekly, Monthly:
+				rule.Freq = Frequency(strings.ToUpper(val))
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid interval %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid count %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := parseWeekday(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, code := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(code)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid month day %q", code)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "WKST":
+			if strings.ToUpper(val) != "MO" {
+				return nil, fmt.Errorf("unsupported week start %q", val)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	return rule, rule.Validate()
+}
+
+// Validate checks the rule is internally consistent
+func (r *Rule) Validate() error {
+	if r.Freq == "" {
+		return errors.New("recurrence rule requires FREQ")
+	}
+	if r.Interval < 1 {
+		return errors.New("recurrence interval must be at least 1")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if r.Freq != Monthly {
+		for _, day := range r.ByDay {
+			if day.N != 0 {
+				return errors.New("numbered BYDAY entries are only valid with FREQ=MONTHLY")
+			}
+		}
+		if len(r.ByMonthDay) > 0 {
+			return errors.New("BYMONTHDAY is only valid with FREQ=MONTHLY")
+		}
+	}
+	return nil
+}
+
+// String formats the rule as an RRULE value
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Bounded reports whether the rule produces a finite number of occurrences
+func (r *Rule) Bounded() bool {
+	return r.Count > 0 || !r.Until.IsZero()
+}
+
+// Iterator returns a lazy iterator over the occurrences of the rule
+// starting at dtstart. Occurrences keep dtstart's time of day and location.
+func (r *Rule) Iterator(dtstart time.Time) *Iterator {
+	return &Iterator{rule: r, dtstart: dtstart}
+}
+
+// Between returns up to limit occurrences whose start falls in [from, to).
+// A zero "to" means no upper bound.
+func (r *Rule) Between(dtstart, from, to time.Time, limit int) []time.Time {
+	var out []time.Time
+	it := r.Iterator(dtstart)
+	for len(out) < limit {
+		t, ok := it.Next()
+		if !ok || (!to.IsZero() && !t.Before(to)) {
+			break
+		}
+		if !t.Before(from) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Last returns the final occurrence of a bounded rule
+func (r *Rule) Last(dtstart time.Time) (time.Time, bool) {
+	if !r.Bounded() {
+		return time.Time{}, false
+	}
+
+	var last time.Time
+	found := false
+	it := r.Iterator(dtstart)
+	for {
+		t, ok := it.Next()
+		if !ok {
+			return last, found
+		}
+		last, found = t, true
+	}
+}
+
+// Iterator yields occurrences in chronological order
+type Iterator struct {
+	rule    *Rule
+	dtstart time.Time
+	period  int
+	pending []time.Time
+	emitted int
+	done    bool
+}
+
+// Next returns the next occurrence, or false when the series has ended
+func (it *Iterator) Next() (time.Time, bool) {
+	for !it.done {
+		if len(it.pending) > 0 {
+			t := it.pending[0]
+			it.pending = it.pending[1:]
+
+			if t.Before(it.dtstart) {
+				continue
+			}
+			if !it.rule.Until.IsZero() && t.After(it.rule.Until) {
+				it.done = true
+				break
+			}
+			it.emitted++
+			if it.rule.Count > 0 && it.emitted >= it.rule.Count {
+				it.done = true
+			}
+			return t, true
+		}
+
+		if it.period >= maxPeriods {
+			it.done = true
+			break
+		}
+		it.pending = it.rule.candidates(it.dtstart, it.period)
+		it.period++
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the sorted occurrences within the nth period of the rule
+func (r *Rule) candidates(dtstart time.Time, n int) []time.Time {
+	hour, min, sec := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, dtstart.Nanosecond(), loc)
+	}
+
+	var out []time.Time
+	switch r.Freq {
+	case Daily:
+		day := dtstart.AddDate(0, 0, n*r.Interval)
+		if len(r.ByDay) == 0 || r.matchesWeekday(day.Weekday()) {
+			out = append(out, at(day.Year(), day.Month(), day.Day()))
+		}
+
+	case Weekly:
+		offset := (int(dtstart.Weekday()) + 6) % 7 // days since Monday
+		monday := dtstart.AddDate(0, 0, -offset+n*7*r.Interval)
+		if len(r.ByDay) == 0 {
+			day := monday.AddDate(0, 0, offset)
+			out = append(out, at(day.Year(), day.Month(), day.Day()))
+			break
+		}
+		for i := 0; i < 7; i++ {
+			day := monday.AddDate(0, 0, i)
+			if r.matchesWeekday(day.Weekday()) {
+				out = append(out, at(day.Year(), day.Month(), day.Day()))
+			}
+		}
+
+	case Monthly:
+		first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, loc)
+		year, month := first.Year(), first.Month()
+		daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+
+		days := make(map[int]bool)
+		switch {
+		case len(r.ByMonthDay) > 0:
+			for _, d := range r.ByMonthDay {
+				if d < 0 {
+					d = daysInMonth + d + 1
+				}
+				if d >= 1 && d <= daysInMonth && (len(r.ByDay) == 0 || r.matchesMonthWeekday(year, month, d, daysInMonth, loc)) {
+					days[d] = true
+				}
+			}
+		case len(r.ByDay) > 0:
+			for d := 1; d <= daysInMonth; d++ {
+				if r.matchesMonthWeekday(year, month, d, daysInMonth, loc) {
+					days[d] = true
+				}
+			}
+		default:
+			if dtstart.Day() <= daysInMonth {
+				days[dtstart.Day()] = true
+			}
+		}
+
+		for d := range days {
+			out = append(out, at(year, month, d))
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	}
+	return out
+}
+
+func (r *Rule) matchesWeekday(day time.Weekday) bool {
+	for _, w := range r.ByDay {
+		if w.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthWeekday reports whether day d of the month satisfies BYDAY,
+// honouring ordinal entries such as 2TU or -1FR.
+func (r *Rule) matchesMonthWeekday(year int, month time.Month, d, daysInMonth int, loc *time.Location) bool {
+	weekday := time.Date(year, month, d, 0, 0, 0, 0, loc).Weekday()
+	for _, w := range r.ByDay {
+		if w.Day != weekday {
+			continue
+		}
+		switch {
+		case w.N == 0:
+			return true
+		case w.N > 0 && (d-1)/7+1 == w.N:
+			return true
+		case w.N < 0 && (daysInMonth-d)/7+1 == -w.N:
+			return true
+		}
+	}
+	return false
+}
+
+func parseWeekday(code string) (Weekday, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return Weekday{}, fmt.Errorf("invalid weekday %q", code)
+	}
+	day, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("invalid weekday %q", code)
+	}
+
+	n := 0
+	if prefix := code[:len(code)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, fmt.Errorf("invalid weekday %q", code)
+		}
+	}
+	return Weekday{Day: day, N: n}, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{untilFormat, "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid until %q", value)
+}
@@ -0,0 +1,146 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, rule string, dtstart time.Time, limit int) []string {
+	t.Helper()
+	r, err := Parse(rule)
+	require.NoError(t, err)
+
+	var out []string
+	it := r.Iterator(dtstart)
+	for len(out) < limit {
+		next, ok := it.Next()
+		if !ok {
+			break
+		}
+		out = append(out, next.Format("2006-01-02 15:04"))
+	}
+	return out
+}
+
+func TestParseAndString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "daily", input: "FREQ=DAILY", expected: "FREQ=DAILY"},
+		{name: "with prefix", input: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", expected: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{name: "ordinal weekday", input: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", expected: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"},
+		{name: "until", input: "FREQ=DAILY;UNTIL=20240105T100000Z", expected: "FREQ=DAILY;UNTIL=20240105T100000Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.String())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;BYSETPOS=1",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDailyWithCount(t *testing.T) {
+	start := time.Date(2024, 1, 30, 18, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"2024-01-30 18:00",
+		"2024-02-01 18:00",
+		"2024-02-03 18:00",
+	}, collect(t, "FREQ=DAILY;INTERVAL=2;COUNT=3", start, 10))
+}
+
+func TestWeeklyByDayWithUntil(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC) // Tuesday
+	assert.Equal(t, []string{
+		"2024-01-02 09:30",
+		"2024-01-04 09:30",
+		"2024-01-09 09:30",
+		"2024-01-11 09:30",
+	}, collect(t, "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20240111T093000Z", start, 10))
+}
+
+func TestMonthlyNthWeekday(t *testing.T) {
+	start := time.Date(2024, 1, 26, 17, 0, 0, 0, time.UTC) // last Friday of January
+	assert.Equal(t, []string{
+		"2024-01-26 17:00",
+		"2024-02-23 17:00",
+		"2024-03-29 17:00",
+	}, collect(t, "FREQ=MONTHLY;BYDAY=-1FR", start, 3))
+}
+
+func TestMonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{
+		"2024-01-31 12:00",
+		"2024-03-31 12:00",
+		"2024-05-31 12:00",
+	}, collect(t, "FREQ=MONTHLY", start, 3))
+}
+
+func TestBetweenAndLast(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	r, err := Parse("FREQ=DAILY;COUNT=10")
+	require.NoError(t, err)
+
+	between := r.Between(start, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), 10)
+	assert.Len(t, between, 2)
+
+	last, ok := r.Last(start)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC), last)
+
+	unbounded, _ := Parse("FREQ=WEEKLY")
+	_, ok = unbounded.Last(start)
+	assert.False(t, ok)
+}
+
+func TestImpossibleRuleTerminates(t *testing.T) {
+	start := time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)
+	assert.Empty(t, collect(t, "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30", start, 1))
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=DAILY", "Every day"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10", "Every 2 weeks on Mon, Wed, 10 times"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20241231T235959Z", "Every month on last Fri, until Dec 31, 2024"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,22,-1", "Every month on the 1st, 22nd, last"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, r.Describe())
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PendingStore holds the logins that passed the password check and wait
+// for a second factor. Unlike a session an entry is not extended by use,
+// and it goes after too many wrong codes.
+type PendingStore struct {
+	client      *redis.Client
+	ttl         time.Duration
+	maxAttempts int
+}
+
+func NewPendingStore(client *redis.Client, ttl time.Duration, maxAttempts int) *PendingStore {
+	return &PendingStore{client: client, ttl: ttl, maxAttempts: maxAttempts}
+}
+
+// failScript counts a wrong code without bringing back an entry that
+// expired or was deleted in the meantime
+var failScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return -1 end
+return redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+`)
+
+// Create stores a pending login for userID and returns its token
+func (s *PendingStore) Create(ctx context.Context, userID string) (string, error) {
+	token := generateToken()
+	key := "2fa:" + token
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user", userID, "attempts", 0)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return token, err
+}
+
+// Get returns the user id of a pending login
+func (s *PendingStore) Get(ctx context.Context, token string) (string, error) {
+	val, err := s.client.HGet(ctx, "2fa:"+token, "user").Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return val, err
+}
+
+// Fail counts a wrong code and returns how many attempts are left. The
+// pending login is deleted when none are.
+func (s *PendingStore) Fail(ctx context.Context, token string) (int, error) {
+	key := "2fa:" + token
+	attempts, err := failScript.Run(ctx, s.client, []string{key}).Int()
+	if err != nil {
+		return 0, err
+	}
+	if attempts < 0 {
+		return 0, ErrNotFound
+	}
+	left := s.maxAttempts - attempts
+	if left <= 0 {
+		return 0, s.client.Del(ctx, key).Err()
+	}
+	return left, nil
+}
+
+// Delete ends a pending login, once it succeeded or was abandoned
+func (s *PendingStore) Delete(ctx context.Context, token string) error {
+	return s.client.Del(ctx, "2fa:"+token).Err()
+}
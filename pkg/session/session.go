@@ -4,11 +4,24 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// ErrNotFound is returned for unknown and expired tokens
+var ErrNotFound = errors.New("session not found")
+
+// Manager maps session tokens to user ids. Store keeps them in Redis,
+// MemoryStore in the process for tests.
+type Manager interface {
+	Create(ctx context.Context, userID string) (string, error)
+	Get(ctx context.Context, token string) (string, error)
+	Delete(ctx context.Context, token string) error
+}
+
 type Store struct {
 	client *redis.Client
 	ttl    time.Duration
@@ -28,7 +41,9 @@ func (s *Store) Create(ctx context.Context, userID string) (string, error) {
 func (s *Store) Get(ctx context.Context, token string) (string, error) {
 	key := "session:" + token
 	val, err := s.client.Get(ctx, key).Result()
-	if err != nil {
+	if err == redis.Nil {
+		return "", ErrNotFound
+	} else if err != nil {
 		return "", err
 	}
 	s.client.Expire(ctx, key, s.ttl)
@@ -40,6 +55,50 @@ func (s *Store) Delete(ctx context.Context, token string) error {
 	return s.client.Del(ctx, key).Err()
 }
 
+// MemoryStore is a Manager that keeps sessions in a map
+type MemoryStore struct {
+	lock     sync.Mutex
+	ttl      time.Duration
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	userID  string
+	expires time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, sessions: make(map[string]memorySession)}
+}
+
+func (s *MemoryStore) Create(_ context.Context, userID string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	token := generateToken()
+	s.sessions[token] = memorySession{userID: userID, expires: time.Now().Add(s.ttl)}
+	return token, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, token string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session, ok := s.sessions[token]
+	if !ok || time.Now().After(session.expires) {
+		delete(s.sessions, token)
+		return "", ErrNotFound
+	}
+	session.expires = time.Now().Add(s.ttl)
+	s.sessions[token] = session
+	return session.userID, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, token string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sessions, token)
+	return nil
+}
+
 func generateToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The secret of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The SHA1 vectors of RFC 6238 appendix B, cut to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "at %d", tt.unix)
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := Code(rfcSecret, step+offset)
+		require.NoError(t, err)
+		got, ok := Validate(rfcSecret, code, now)
+		assert.True(t, ok, "offset %d", offset)
+		assert.Equal(t, step+offset, got, "the matching step is returned")
+	}
+	for _, offset := range []int64{-2, 2} {
+		code, err := Code(rfcSecret, step+offset)
+		require.NoError(t, err)
+		_, ok := Validate(rfcSecret, code, now)
+		assert.False(t, ok, "offset %d", offset)
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	_, ok := Validate(rfcSecret, "28708", now)
+	assert.False(t, ok, "too short")
+	_, ok = Validate(rfcSecret, "2870820", now)
+	assert.False(t, ok, "too long")
+	_, ok = Validate("not base32!", "287082", now)
+	assert.False(t, ok, "bad secret")
+
+	_, ok = Validate(strings.ToLower(rfcSecret), "287 082", now)
+	assert.True(t, ok, "lower case secrets and spaced codes are fine")
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	require.NoError(t, err)
+	b, err := NewSecret()
+	require.NoError(t, err)
+	assert.Len(t, a, 32, "160 bits in base32")
+	assert.NotEqual(t, a, b)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Event Analytics", "ada@example.com", rfcSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Event%20Analytics:ada@example.com?"), uri)
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "issuer=Event+Analytics")
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
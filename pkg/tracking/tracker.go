@@ -0,0 +1,144 @@
+package tracking
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"event-analytics/models"
+
+	"gorm.io/gorm"
+)
+
+// Tracker buffers analytics records in memory and writes them to the
+// database in batches from a single background goroutine, so callers on
+// the request path never wait on the database.
+type Tracker struct {
+	queue         chan models.AnalyticsEvent
+	batchSize     int
+	flushInterval time.Duration
+	write         func([]models.AnalyticsEvent) error
+	onFlush       []func([]models.AnalyticsEvent)
+
+	dropped   uint64
+	started   int32
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewTracker(db *gorm.DB, bufferSize, batchSize int, flushInterval time.Duration) *Tracker {
+	return &Tracker{
+		queue:         make(chan models.AnalyticsEvent, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		write: func(batch []models.AnalyticsEvent) error {
+			return db.CreateInBatches(batch, len(batch)).Error
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start launches the background writer
+func (t *Tracker) Start() {
+	atomic.StoreInt32(&t.started, 1)
+	go t.run()
+}
+
+// OnFlush registers a callback that receives every batch after it has been
+// written. Batches that failed to write are not passed on. Callbacks run on
+// the writer goroutine and must be registered before Start.
+func (t *Tracker) OnFlush(fn func([]models.AnalyticsEvent)) {
+	t.onFlush = append(t.onFlush, fn)
+}
+
+// Track queues a record without blocking. When the buffer is full or the
+// tracker is closed the record is dropped and false is returned.
+func (t *Tracker) Track(record models.AnalyticsEvent) bool {
+	select {
+	case <-t.stop:
+		atomic.AddUint64(&t.dropped, 1)
+		return false
+	default:
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	select {
+	case t.queue <- record:
+		return true
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+		return false
+	}
+}
+
+// Dropped returns the number of records discarded because the buffer was full
+func (t *Tracker) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Close stops accepting records, flushes whatever is buffered and waits
+// for the writer to exit. A tracker that was never started has no writer,
+// so its buffered records are dropped.
+func (t *Tracker) Close() {
+	t.closeOnce.Do(func() {
+		close(t.stop)
+	})
+	if atomic.LoadInt32(&t.started) == 0 {
+		return
+	}
+	<-t.done
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AnalyticsEvent, 0, t.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.write(batch); err != nil {
+			log.Printf("Tracker: failed to write %d analytics records: %v", len(batch), err)
+		} else {
+			for _, fn := range t.onFlush {
+				fn(batch)
+			}
+		}
+		batch = make([]models.AnalyticsEvent, 0, t.batchSize)
+	}
+
+	for {
+		select {
+		case record := <-t.queue:
+			batch = append(batch, record)
+			if len(batch) >= t.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			// Drain what is already buffered before exiting
+			for {
+				select {
+				case record := <-t.queue:
+					batch = append(batch, record)
+					if len(batch) >= t.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package tracking
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTracker(bufferSize, batchSize int, interval time.Duration) (*Tracker, *[][]models.AnalyticsEvent, *sync.Mutex) {
+	var mu sync.Mutex
+	var batches [][]models.AnalyticsEvent
+	t := &Tracker{
+		queue:         make(chan models.AnalyticsEvent, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: interval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	t.write = func(batch []models.AnalyticsEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, batch)
+		return nil
+	}
+	return t, &batches, &mu
+}
+
+func TestTrackerFlushesFullBatches(t *testing.T) {
+	tracker, batches, mu := newTestTracker(100, 3, time.Hour)
+	tracker.Start()
+
+	eventID := uuid.New()
+	for i := 0; i < 7; i++ {
+		assert.True(t, tracker.Track(models.AnalyticsEvent{EventID: eventID, Action: models.ActionView}))
+	}
+	tracker.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := 0
+	for _, batch := range *batches {
+		assert.LessOrEqual(t, len(batch), 3)
+		total += len(batch)
+	}
+	assert.Equal(t, 7, total)
+}
+
+func TestTrackerFlushesOnInterval(t *testing.T) {
+	tracker, batches, mu := newTestTracker(100, 50, 10*time.Millisecond)
+	tracker.Start()
+	defer tracker.Close()
+
+	tracker.Track(models.AnalyticsEvent{EventID: uuid.New(), Action: models.ActionDetailOpen})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(*batches) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestTrackerDropsWhenBufferFull(t *testing.T) {
+	// Not started, so nothing drains the queue
+	tracker, _, _ := newTestTracker(2, 10, time.Hour)
+
+	assert.True(t, tracker.Track(models.AnalyticsEvent{Action: models.ActionView}))
+	assert.True(t, tracker.Track(models.AnalyticsEvent{Action: models.ActionView}))
+	assert.False(t, tracker.Track(models.AnalyticsEvent{Action: models.ActionView}))
+	assert.Equal(t, uint64(1), tracker.Dropped())
+}
+
+func TestTrackerRejectsAfterClose(t *testing.T) {
+	tracker, _, _ := newTestTracker(10, 10, time.Hour)
+	tracker.Start()
+	tracker.Close()
+
+	assert.False(t, tracker.Track(models.AnalyticsEvent{Action: models.ActionView}))
+}
+
+func TestTrackerCloseWithoutStart(t *testing.T) {
+	tracker, _, _ := newTestTracker(10, 10, time.Hour)
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+
+	closed := make(chan struct{})
+	go func() {
+		tracker.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waited for a writer that was never started")
+	}
+	assert.False(t, tracker.Track(models.AnalyticsEvent{Action: models.ActionView}))
+}
+
+func TestTrackerCallsFlushHooks(t *testing.T) {
+	tracker, _, _ := newTestTracker(10, 2, time.Hour)
+
+	var seen int
+	tracker.OnFlush(func(batch []models.AnalyticsEvent) {
+		seen += len(batch)
+	})
+	tracker.Start()
+
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+	tracker.Close()
+
+	assert.Equal(t, 3, seen)
+}
+
+func TestTrackerSkipsFlushHooksWhenWriteFails(t *testing.T) {
+	tracker, _, _ := newTestTracker(10, 2, time.Hour)
+	tracker.write = func([]models.AnalyticsEvent) error { return errors.New("database is down") }
+
+	var seen int
+	tracker.OnFlush(func(batch []models.AnalyticsEvent) {
+		seen += len(batch)
+	})
+	tracker.Start()
+
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+	tracker.Track(models.AnalyticsEvent{Action: models.ActionView})
+	tracker.Close()
+
+	assert.Zero(t, seen, "records that were not written are not reported")
+}
@@ -0,0 +1,145 @@
+package uniques
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Period is the time window a HyperLogLog key covers
+type Period string
+
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+)
+
+// Periods lists every period a visit is counted in
+var Periods = []Period{Day, Week, Month}
+
+// How long each kind of key is kept in Redis after its last write
+var retention = map[Period]time.Duration{
+	Day:   120 * 24 * time.Hour,
+	Week:  400 * 24 * time.Hour,
+	Month: 800 * 24 * time.Hour,
+}
+
+// maxRangeDays bounds the number of day keys merged for a single range query
+const maxRangeDays = 366
+
+// Visit is a single visitor seen on an event at a point in time
+type Visit struct {
+	EventID   string
+	VisitorID string
+	At        time.Time
+}
+
+// Counter keeps approximate unique-visitor counts per event in Redis
+// HyperLogLog keys, one per day, ISO week and month (all in UTC).
+type Counter struct {
+	client *redis.Client
+}
+
+func NewCounter(client *redis.Client) *Counter {
+	return &Counter{client: client}
+}
+
+// Key returns the HyperLogLog key for an event and the period containing t
+func Key(eventID string, period Period, t time.Time) string {
+	t = t.UTC()
+	switch period {
+	case Week:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("uv:event:%s:week:%d-W%02d", eventID, year, week)
+	case Month:
+		return fmt.Sprintf("uv:event:%s:month:%s", eventID, t.Format("2006-01"))
+	default:
+		return fmt.Sprintf("uv:event:%s:day:%s", eventID, t.Format("2006-01-02"))
+	}
+}
+
+// PeriodStart returns the first instant (UTC) of the period containing t
+func PeriodStart(period Period, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case Week:
+		offset := (int(day.Weekday()) + 6) % 7 // Monday starts an ISO week
+		return day.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// DayKeys returns the day keys covering every UTC day from "from" to "to" inclusive
+func DayKeys(eventID string, from, to time.Time) []string {
+	var keys []string
+	for day := PeriodStart(Day, from); !day.After(to.UTC()) && len(keys) < maxRangeDays; day = day.AddDate(0, 0, 1) {
+		keys = append(keys, Key(eventID, Day, day))
+	}
+	return keys
+}
+
+// Add records visits in the day, week and month keys of each event using a
+// single pipeline round trip.
+func (c *Counter) Add(ctx context.Context, visits ...Visit) error {
+	if len(visits) == 0 {
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	touched := make(map[string]time.Duration)
+	for _, v := range visits {
+		if v.VisitorID == "" {
+			continue
+		}
+		for _, period := range Periods {
+			key := Key(v.EventID, period, v.At)
+			pipe.PFAdd(ctx, key, v.VisitorID)
+			touched[key] = retention[period]
+		}
+	}
+	for key, ttl := range touched {
+		pipe.Expire(ctx, key, ttl)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Count returns the approximate number of unique visitors for the period containing t
+func (c *Counter) Count(ctx context.Context, eventID string, period Period, t time.Time) (int64, error) {
+	return c.client.PFCount(ctx, Key(eventID, period, t)).Result()
+}
+
+// CountRange returns the approximate number of unique visitors across every
+// day between from and to, merging the daily sketches on the fly.
+func (c *Counter) CountRange(ctx context.Context, eventID string, from, to time.Time) (int64, error) {
+	keys := DayKeys(eventID, from, to)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return c.client.PFCount(ctx, keys...).Result()
+}
+
+// MergeRange stores the union of the daily sketches between from and to
+// into dest, so the merged range can be reused or combined further.
+func (c *Counter) MergeRange(ctx context.Context, dest, eventID string, from, to time.Time, ttl time.Duration) error {
+	keys := DayKeys(eventID, from, to)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.PFMerge(ctx, dest, keys...)
+	if ttl > 0 {
+		pipe.Expire(ctx, dest, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
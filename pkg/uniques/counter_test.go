@@ -0,0 +1,54 @@
+package uniques
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	at := time.Date(2024, 1, 3, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		period   Period
+		expected string
+	}{
+		{name: "day", period: Day, expected: "uv:event:abc:day:2024-01-03"},
+		{name: "week", period: Week, expected: "uv:event:abc:week:2024-W01"},
+		{name: "month", period: Month, expected: "uv:event:abc:month:2024-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Key("abc", tt.period, at))
+		})
+	}
+}
+
+func TestKeyUsesUTC(t *testing.T) {
+	lagos := time.FixedZone("WAT", 3600)
+	at := time.Date(2024, 1, 4, 0, 30, 0, 0, lagos) // 2024-01-03 23:30 UTC
+
+	assert.Equal(t, "uv:event:abc:day:2024-01-03", Key("abc", Day, at))
+}
+
+func TestPeriodStart(t *testing.T) {
+	at := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC) // Wednesday
+
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), PeriodStart(Day, at))
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), PeriodStart(Week, at))
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), PeriodStart(Month, at))
+}
+
+func TestDayKeys(t *testing.T) {
+	from := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []string{
+		"uv:event:abc:day:2024-01-30",
+		"uv:event:abc:day:2024-01-31",
+		"uv:event:abc:day:2024-02-01",
+	}, DayKeys("abc", from, to))
+}
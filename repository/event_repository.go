@@ -2,49 +2,81 @@ package repository
 
 import (
 	"event-analytics/models"
+
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-type EventRepository struct {
+type eventRepository struct {
 	db *gorm.DB
 }
 
-func NewEventRepository(db *gorm.DB) *EventRepository {
-	return &EventRepository{db: db}
+// NewEventRepository returns an EventRepository backed by db, which may be
+// a transaction
+func NewEventRepository(db *gorm.DB) EventRepository {
+	return &eventRepository{db: db}
 }
 
-func (r *EventRepository) Create(event *models.Event) error {
+func (r *eventRepository) Create(event *models.Event) error {
 	return r.db.Create(event).Error
 }
 
-func (r *EventRepository) FindByID(id uint) (*models.Event, error) {
+func (r *eventRepository) FindByID(id uuid.UUID) (*models.Event, error) {
 	var event models.Event
-	err := r.db.First(&event, id).Error
-	return &event, err
+	if err := r.db.First(&event, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &event, nil
 }
 
-func (r *EventRepository) FindAll(limit, offset int) ([]models.Event, error) {
-	var events []models.Event
-	err := r.db.Limit(limit).Offset(offset).Find(&events).Error
-	return events, err
+func (r *eventRepository) TitleTaken(title string, except uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Event{}).Unscoped().Where("title = ? AND id != ?", title, except).Count(&count).Error
+	return count > 0, err
 }
 
-func (r *EventRepository) FindByUserID(userID uint, limit, offset int) ([]models.Event, error) {
-	var events []models.Event
-	err := r.db.Where("created_by = ?", userID).Limit(limit).Offset(offset).Find(&events).Error
-	return events, err
+func (r *eventRepository) List(filter EventFilter) ([]models.Event, int64, error) {
+	query := r.db.Model(&models.Event{})
+	if filter.VisibleTo != nil {
+		query = query.Where("status = ? OR created_by = ?", "published", *filter.VisibleTo)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.From != nil {
+		// Series are in range until their last occurrence ends, forever when unbounded
+		query = query.Where("(COALESCE(recurrence_rule, '') = '' AND end_time >= ?) OR (COALESCE(recurrence_rule, '') <> '' AND (series_ends_at IS NULL OR series_ends_at >= ?))", *filter.From, *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("start_time < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order("start_time, id").Offset(filter.Offset)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	events := []models.Event{}
+	if err := query.Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
 }
 
-func (r *EventRepository) Update(event *models.Event) error {
+func (r *eventRepository) Update(event *models.Event) error {
 	return r.db.Save(event).Error
 }
 
-func (r *EventRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Event{}, id).Error
+func (r *eventRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.Event{}, "id = ?", id).Error
 }
 
-func (r *EventRepository) Count() (int64, error) {
-	var count int64
-	err := r.db.Model(&models.Event{}).Count(&count).Error
-	return count, err
+func (r *eventRepository) Transaction(fn func(events EventRepository, tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewEventRepository(tx), tx)
+	})
 }
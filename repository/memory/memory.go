@@ -0,0 +1,379 @@
+// Package memory implements the repository interfaces with maps, for tests
+// that should not need PostgreSQL. Records are copied in and out, so
+// callers never share state with the store.
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrDuplicate is returned for writes that break a unique column
+var ErrDuplicate = errors.New("memory: duplicate key")
+
+// New returns empty in-memory repositories
+func New() *repository.Repositories {
+	return &repository.Repositories{
+		Events: NewEventRepository(),
+		Users:  NewUserRepository(),
+		Outbox: NewOutboxRepository(),
+	}
+}
+
+// EventRepository is an in-memory repository.EventRepository. Deleted
+// events are kept with DeletedAt set, like gorm's soft delete, so their
+// titles stay taken.
+type EventRepository struct {
+	lock   sync.RWMutex
+	events map[uuid.UUID]models.Event
+}
+
+// NewEventRepository returns an empty EventRepository
+func NewEventRepository() *EventRepository {
+	return &EventRepository{events: make(map[uuid.UUID]models.Event)}
+}
+
+func (r *EventRepository) Create(event *models.Event) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.titleTaken(event.Title, uuid.Nil) {
+		return ErrDuplicate
+	}
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = event.CreatedAt
+	if event.Status == "" {
+		event.Status = "draft"
+	}
+	r.events[event.ID] = *event
+	return nil
+}
+
+func (r *EventRepository) FindByID(id uuid.UUID) (*models.Event, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	event, ok := r.events[id]
+	if !ok || event.DeletedAt.Valid {
+		return nil, repository.ErrNotFound
+	}
+	return &event, nil
+}
+
+func (r *EventRepository) TitleTaken(title string, except uuid.UUID) (bool, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.titleTaken(title, except), nil
+}
+
+func (r *EventRepository) titleTaken(title string, except uuid.UUID) bool {
+	for id, event := range r.events {
+		if id != except && event.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *EventRepository) List(filter repository.EventFilter) ([]models.Event, int64, error) {
+	r.lock.RLock()
+	events := []models.Event{}
+	for _, event := range r.events {
+		if !event.DeletedAt.Valid && matches(&event, filter) {
+			events = append(events, event)
+		}
+	}
+	r.lock.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].StartTime.Equal(events[j].StartTime) {
+			return events[i].StartTime.Before(events[j].StartTime)
+		}
+		return events[i].ID.String() < events[j].ID.String()
+	})
+
+	total := int64(len(events))
+	if filter.Offset >= len(events) {
+		return []models.Event{}, total, nil
+	}
+	events = events[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(events) {
+		events = events[:filter.Limit]
+	}
+	return events, total, nil
+}
+
+// matches applies filter like the SQL of the gorm repository
+func matches(event *models.Event, filter repository.EventFilter) bool {
+	if filter.VisibleTo != nil && event.Status != "published" && event.CreatedBy != *filter.VisibleTo {
+		return false
+	}
+	if len(filter.Statuses) > 0 {
+		found := false
+		for _, status := range filter.Statuses {
+			found = found || event.Status == status
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.From != nil {
+		if event.RecurrenceRule == "" && event.EndTime.Before(*filter.From) {
+			return false
+		}
+		if event.RecurrenceRule != "" && event.SeriesEndsAt != nil && event.SeriesEndsAt.Before(*filter.From) {
+			return false
+		}
+	}
+	if filter.To != nil && !event.StartTime.Before(*filter.To) {
+		return false
+	}
+	return true
+}
+
+func (r *EventRepository) Update(event *models.Event) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if existing, ok := r.events[event.ID]; !ok || existing.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	if r.titleTaken(event.Title, event.ID) {
+		return ErrDuplicate
+	}
+	event.UpdatedAt = time.Now()
+	r.events[event.ID] = *event
+	return nil
+}
+
+func (r *EventRepository) Delete(id uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if event, ok := r.events[id]; ok && !event.DeletedAt.Valid {
+		event.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		r.events[id] = event
+	}
+	return nil
+}
+
+// Transaction runs fn with r and a nil transaction. Nothing is rolled back
+// when fn fails.
+func (r *EventRepository) Transaction(fn func(events repository.EventRepository, tx *gorm.DB) error) error {
+	return fn(r, nil)
+}
+
+// UserRepository is an in-memory repository.UserRepository
+type UserRepository struct {
+	lock  sync.RWMutex
+	users   map[uuid.UUID]models.User
+	roles   map[uuid.UUID][]string
+	history map[uuid.UUID][]models.PasswordHistory // Newest first
+
+	twoFactorRoles map[string]bool
+}
+
+// NewUserRepository returns an empty UserRepository
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users:   make(map[uuid.UUID]models.User),
+		roles:   make(map[uuid.UUID][]string),
+		history: make(map[uuid.UUID][]models.PasswordHistory),
+
+		twoFactorRoles: make(map[string]bool),
+	}
+}
+
+func (r *UserRepository) Create(user *models.User, roles ...string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existing := range r.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	r.users[user.ID] = *user
+	r.roles[user.ID] = append([]string(nil), roles...)
+	r.history[user.ID] = []models.PasswordHistory{{UserID: user.ID, Password: user.Password, CreatedAt: user.CreatedAt}}
+	return nil
+}
+
+func (r *UserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.ID == id })
+}
+
+func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Username == username })
+}
+
+func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+	return r.find(func(user *models.User) bool { return user.Email == email })
+}
+
+func (r *UserRepository) FindByLogin(identifier string) (*models.User, error) {
+	return r.find(func(user *models.User) bool {
+		return user.Username == identifier || user.Email == identifier
+	})
+}
+
+func (r *UserRepository) find(match func(user *models.User) bool) (*models.User, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, user := range r.users {
+		if match(&user) {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *UserRepository) Update(user *models.User) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for id, existing := range r.users {
+		if id != user.ID && (existing.Username == user.Username || existing.Email == user.Email) {
+			return ErrDuplicate
+		}
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *UserRepository) ChangePassword(user *models.User) error {
+	if err := r.Update(user); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := models.PasswordHistory{UserID: user.ID, Password: user.Password, CreatedAt: user.UpdatedAt}
+	r.history[user.ID] = append([]models.PasswordHistory{entry}, r.history[user.ID]...)
+	return nil
+}
+
+func (r *UserRepository) PasswordHistory(id uuid.UUID) ([]models.PasswordHistory, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]models.PasswordHistory(nil), r.history[id]...), nil
+}
+
+func (r *UserRepository) Delete(id uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.users, id)
+	delete(r.roles, id)
+	delete(r.history, id)
+	return nil
+}
+
+func (r *UserRepository) HasRole(id uuid.UUID, role string) (bool, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, name := range r.roles[id] {
+		if name == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *UserRepository) RequiresTwoFactor(id uuid.UUID) (bool, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, name := range r.roles[id] {
+		if r.twoFactorRoles[name] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequireTwoFactor makes two-factor authentication mandatory for the
+// members of role, or optional again
+func (r *UserRepository) RequireTwoFactor(role string, required bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.twoFactorRoles[role] = required
+}
+
+// WithTx returns r, the memory store has no transactions
+func (r *UserRepository) WithTx(tx *gorm.DB) repository.UserRepository {
+	return r
+}
+
+// Transaction runs fn with r and a nil transaction. Nothing is rolled back
+// when fn fails.
+func (r *UserRepository) Transaction(fn func(users repository.UserRepository, tx *gorm.DB) error) error {
+	return fn(r, nil)
+}
+
+// OutboxRepository is an in-memory repository.OutboxRepository
+type OutboxRepository struct {
+	lock     sync.RWMutex
+	messages []models.OutboxMessage
+}
+
+// NewOutboxRepository returns an empty OutboxRepository
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{}
+}
+
+// Add stores a message, as the outbox would when an email is queued
+func (r *OutboxRepository) Add(message models.OutboxMessage) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	message.ID = uint(len(r.messages) + 1)
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	r.messages = append(r.messages, message)
+}
+
+func (r *OutboxRepository) List(status string, limit int) ([]models.OutboxMessage, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	messages := []models.OutboxMessage{}
+	for i := len(r.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		if status == "" || r.messages[i].Status == status {
+			messages = append(messages, r.messages[i])
+		}
+	}
+	return messages, nil
+}
+
+func (r *OutboxRepository) Counts() (map[string]int64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	counts := make(map[string]int64, len(models.OutboxStatuses))
+	for _, status := range models.OutboxStatuses {
+		counts[status] = 0
+	}
+	for _, message := range r.messages {
+		counts[message.Status]++
+	}
+	return counts, nil
+}
+
+func (r *OutboxRepository) Requeue(id uint) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.messages {
+		message := &r.messages[i]
+		if message.ID != id || message.Status == models.OutboxSent || message.Body == "" {
+			continue
+		}
+		message.Status = models.OutboxPending
+		message.Attempts = 0
+		message.NextAttemptAt = time.Now()
+		return nil
+	}
+	return repository.ErrNotFound
+}
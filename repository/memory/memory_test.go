@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRepositoryList(t *testing.T) {
+	repo := NewEventRepository()
+	owner, other := uuid.New(), uuid.New()
+	day := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	seriesEnd := day.Add(10 * 24 * time.Hour)
+
+	create := func(title, status string, createdBy uuid.UUID, start time.Time, rule string, seriesEndsAt *time.Time) {
+		require.NoError(t, repo.Create(&models.Event{
+			Title: title, Status: status, CreatedBy: createdBy,
+			StartTime: start, EndTime: start.Add(time.Hour),
+			RecurrenceRule: rule, SeriesEndsAt: seriesEndsAt,
+		}))
+	}
+	create("past", "published", other, day.Add(-48*time.Hour), "", nil)
+	create("series", "published", other, day.Add(-24*time.Hour), "FREQ=DAILY;COUNT=12", &seriesEnd)
+	create("own draft", "draft", owner, day.Add(24*time.Hour), "", nil)
+	create("other draft", "draft", other, day.Add(48*time.Hour), "", nil)
+
+	titles := func(filter repository.EventFilter) ([]string, int64) {
+		events, total, err := repo.List(filter)
+		require.NoError(t, err)
+		var titles []string
+		for _, event := range events {
+			titles = append(titles, event.Title)
+		}
+		return titles, total
+	}
+
+	got, total := titles(repository.EventFilter{})
+	assert.Equal(t, []string{"past", "series", "own draft", "other draft"}, got)
+	assert.EqualValues(t, 4, total)
+
+	got, _ = titles(repository.EventFilter{VisibleTo: &owner})
+	assert.ElementsMatch(t, []string{"past", "series", "own draft"}, got)
+
+	got, _ = titles(repository.EventFilter{From: &day})
+	assert.ElementsMatch(t, []string{"series", "own draft", "other draft"}, got, "series stay until their last occurrence")
+
+	to := day.Add(36 * time.Hour)
+	got, _ = titles(repository.EventFilter{From: &day, To: &to, Statuses: []string{"draft"}})
+	assert.Equal(t, []string{"own draft"}, got)
+
+	got, total = titles(repository.EventFilter{Offset: 1, Limit: 2})
+	assert.Len(t, got, 2)
+	assert.EqualValues(t, 4, total)
+}
+
+func TestEventRepositoryTitles(t *testing.T) {
+	repo := NewEventRepository()
+	event := &models.Event{Title: "Go meetup"}
+	require.NoError(t, repo.Create(event))
+	assert.NotEqual(t, uuid.Nil, event.ID)
+
+	taken, err := repo.TitleTaken("Go meetup", uuid.Nil)
+	require.NoError(t, err)
+	assert.True(t, taken)
+	taken, err = repo.TitleTaken("Go meetup", event.ID)
+	require.NoError(t, err)
+	assert.False(t, taken, "an event does not conflict with itself")
+
+	assert.ErrorIs(t, repo.Create(&models.Event{Title: "Go meetup"}), ErrDuplicate)
+
+	// Callers get copies
+	found, err := repo.FindByID(event.ID)
+	require.NoError(t, err)
+	found.Title = "Changed"
+	again, _ := repo.FindByID(event.ID)
+	assert.Equal(t, "Go meetup", again.Title)
+
+	require.NoError(t, repo.Delete(event.ID))
+	_, err = repo.FindByID(event.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	events, total, err := repo.List(repository.EventFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Zero(t, total)
+
+	// Deleted events keep their title, as the unique index covers them
+	taken, err = repo.TitleTaken("Go meetup", uuid.Nil)
+	require.NoError(t, err)
+	assert.True(t, taken)
+	assert.ErrorIs(t, repo.Create(&models.Event{Title: "Go meetup"}), ErrDuplicate)
+}
+
+func TestUserRepository(t *testing.T) {
+	repo := NewUserRepository()
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, repo.Create(user, models.RoleUser, models.RoleAdmin))
+	assert.ErrorIs(t, repo.Create(&models.User{Username: "alice", Email: "other@example.com"}), ErrDuplicate)
+
+	for _, find := range []func() (*models.User, error){
+		func() (*models.User, error) { return repo.FindByID(user.ID) },
+		func() (*models.User, error) { return repo.FindByLogin("alice") },
+		func() (*models.User, error) { return repo.FindByLogin("alice@example.com") },
+	} {
+		found, err := find()
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+	}
+	_, err := repo.FindByEmail("bob@example.com")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	admin, err := repo.HasRole(user.ID, models.RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, admin)
+	moderator, err := repo.HasRole(user.ID, models.RoleModerator)
+	require.NoError(t, err)
+	assert.False(t, moderator)
+
+	required, err := repo.RequiresTwoFactor(user.ID)
+	require.NoError(t, err)
+	assert.False(t, required)
+	repo.RequireTwoFactor(models.RoleAdmin, true)
+	required, err = repo.RequiresTwoFactor(user.ID)
+	require.NoError(t, err)
+	assert.True(t, required, "alice is an admin")
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"errors"
+
+	"event-analytics/models"
+	"event-analytics/pkg/outbox"
+
+	"gorm.io/gorm"
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository returns an OutboxRepository backed by db
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) List(status string, limit int) ([]models.OutboxMessage, error) {
+	return outbox.List(r.db, status, limit)
+}
+
+func (r *outboxRepository) Counts() (map[string]int64, error) {
+	return outbox.Counts(r.db)
+}
+
+func (r *outboxRepository) Requeue(id uint) error {
+	err := outbox.Requeue(r.db, id)
+	if errors.Is(err, outbox.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+// Package repository is the storage layer behind the controllers. The
+// interfaces are keyed by the models' UUID primary keys; New returns the
+// gorm implementations and the memory package an in-memory one for tests.
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when no record matches a lookup
+var ErrNotFound = errors.New("record not found")
+
+// EventRepository stores events. Deleted events are hidden from every
+// method.
+type EventRepository interface {
+	Create(event *models.Event) error
+	FindByID(id uuid.UUID) (*models.Event, error)
+	// TitleTaken reports whether an event other than except uses title.
+	// Deleted events keep their title, titles are unique across them too.
+	TitleTaken(title string, except uuid.UUID) (bool, error)
+	// List returns a page of the events matching filter, ordered by start
+	// time, and the number of matching events
+	List(filter EventFilter) ([]models.Event, int64, error)
+	Update(event *models.Event) error
+	Delete(id uuid.UUID) error
+	// Transaction runs fn in a transaction, handing it the repository and
+	// the transaction for rows of other tables, and commits unless fn
+	// returns an error
+	Transaction(fn func(events EventRepository, tx *gorm.DB) error) error
+}
+
+// EventFilter narrows EventRepository.List. Zero fields match everything.
+type EventFilter struct {
+	VisibleTo *uuid.UUID // Only published events and those created by this user
+	Statuses  []string
+	From      *time.Time // Events ending at or after From; series until their last occurrence ends
+	To        *time.Time // Events starting before To
+	Offset    int
+	Limit     int // No limit when zero
+}
+
+// UserRepository stores users and their roles
+type UserRepository interface {
+	// Create saves a new user, whose password is already hashed, records
+	// the password in the history and grants roles, all or nothing
+	Create(user *models.User, roles ...string) error
+	FindByID(id uuid.UUID) (*models.User, error)
+	FindByUsername(username string) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	// FindByLogin looks a user up by username or email
+	FindByLogin(identifier string) (*models.User, error)
+	Update(user *models.User) error
+	// ChangePassword saves user, whose new password is already hashed, and
+	// records the password in the history, all or nothing
+	ChangePassword(user *models.User) error
+	// PasswordHistory returns the user's passwords, newest first
+	PasswordHistory(id uuid.UUID) ([]models.PasswordHistory, error)
+	Delete(id uuid.UUID) error
+	HasRole(id uuid.UUID, role string) (bool, error)
+	// RequiresTwoFactor reports whether any of the user's roles makes
+	// two-factor authentication mandatory
+	RequiresTwoFactor(id uuid.UUID) (bool, error)
+	// WithTx returns the repository running its queries in tx, so a caller
+	// can write its own rows in the same transaction
+	WithTx(tx *gorm.DB) UserRepository
+	// Transaction runs fn in a transaction, handing it the repository and
+	// the transaction for rows of other tables, and commits unless fn
+	// returns an error
+	Transaction(fn func(users UserRepository, tx *gorm.DB) error) error
+}
+
+// OutboxRepository reads and requeues the email outbox for the admin pages
+type OutboxRepository interface {
+	// List returns the newest messages, of one status unless status is
+	// empty
+	List(status string, limit int) ([]models.OutboxMessage, error)
+	// Counts returns the number of messages of every status
+	Counts() (map[string]int64, error)
+	// Requeue makes a pending message, or a dead one that still has its
+	// body, due now with a fresh set of attempts. It returns ErrNotFound
+	// when no such message exists.
+	Requeue(id uint) error
+}
+
+// Repositories are handed to the controllers and middlewares
+type Repositories struct {
+	Events EventRepository
+	Users  UserRepository
+	Outbox OutboxRepository
+}
+
+// New returns the repositories backed by db
+func New(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Events: NewEventRepository(db),
+		Users:  NewUserRepository(db),
+		Outbox: NewOutboxRepository(db),
+	}
+}
+
+// notFound maps gorm's missing row error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
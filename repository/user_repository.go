@@ -2,43 +2,112 @@ package repository
 
 import (
 	"event-analytics/models"
+
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-type UserRepository struct {
+type userRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository(db *gorm.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository returns a UserRepository backed by db
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(user *models.User, roles ...string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error; err != nil {
+			return err
+		}
+		for _, name := range roles {
+			var role models.Role
+			if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (r *UserRepository) FindByID(id uint) (*models.User, error) {
-	var user models.User
-	err := r.db.First(&user, id).Error
-	return &user, err
+func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	return r.first("id = ?", id)
 }
 
-func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
-	var user models.User
-	err := r.db.Where("username = ?", username).First(&user).Error
-	return &user, err
+func (r *userRepository) FindByUsername(username string) (*models.User, error) {
+	return r.first("username = ?", username)
+}
+
+func (r *userRepository) FindByEmail(email string) (*models.User, error) {
+	return r.first("email = ?", email)
 }
 
-func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+func (r *userRepository) FindByLogin(identifier string) (*models.User, error) {
+	return r.first("username = ? OR email = ?", identifier, identifier)
+}
+
+func (r *userRepository) first(query string, args ...interface{}) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
-	return &user, err
+	if err := r.db.Where(query, args...).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
 }
 
-func (r *UserRepository) Update(user *models.User) error {
+func (r *userRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
-func (r *UserRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+func (r *userRepository) ChangePassword(user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error
+	})
+}
+
+func (r *userRepository) PasswordHistory(id uuid.UUID) ([]models.PasswordHistory, error) {
+	var history []models.PasswordHistory
+	err := r.db.Where("user_id = ?", id).Order("created_at DESC").Find(&history).Error
+	return history, err
+}
+
+func (r *userRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.User{}, "id = ?", id).Error
+}
+
+func (r *userRepository) HasRole(id uuid.UUID, role string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.name = ?", id, role).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepository) RequiresTwoFactor(id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.requires_two_factor = ?", id, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepository) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepository{db: tx}
+}
+
+func (r *userRepository) Transaction(fn func(users UserRepository, tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(r.WithTx(tx), tx)
+	})
 }
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"event-analytics/apidoc"
+	"event-analytics/pkg/openapi"
+	"event-analytics/repository/memory"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPICoversEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(memory.New())
+	spec := apidoc.Build(r.Routes())
+
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+openapi.Path(route.Path)] = true
+
+		op, ok := spec.Operation(route.Method, route.Path)
+		if assert.True(t, ok, "route %s %s is missing from the document", route.Method, route.Path) {
+			assert.NotEmpty(t, op.Summary, "handler %s of %s %s has no annotation in apidoc", route.Handler, route.Method, route.Path)
+		}
+	}
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, registered[strings.ToUpper(method)+" "+path], "apidoc documents %s %s, which is not registered", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentsEveryAPIRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(memory.New())
+	spec := apidoc.Build(r.Routes())
+
+	var routes int
+	for _, route := range r.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		routes++
+		op, ok := spec.Operation(route.Method, route.Path)
+		require.True(t, ok, "%s %s", route.Method, route.Path)
+		assert.Contains(t, op.Tags, "API", "%s %s", route.Method, route.Path)
+		assert.Contains(t, op.Responses, "401", "%s %s", route.Method, route.Path)
+		if assert.NotEmpty(t, op.Security, "%s %s", route.Method, route.Path) {
+			assert.NotEmpty(t, op.Security[0]["bearer"], "%s %s names its token scope", route.Method, route.Path)
+		}
+	}
+	assert.Equal(t, 7, routes)
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := apidoc.Build(SetupRouter(memory.New()).Routes())
+
+	ids := make(map[string]string)
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			name := strings.ToUpper(method) + " " + path
+			assert.NotEmpty(t, op.Responses, "%s has no responses", name)
+
+			if op.OperationID != "" {
+				other, dup := ids[op.OperationID]
+				assert.False(t, dup, "operationId %s is used by %s and %s", op.OperationID, name, other)
+				ids[op.OperationID] = name
+			}
+
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					assert.Contains(t, path, "{"+p.Name+"}", "%s declares unknown path parameter %s", name, p.Name)
+				}
+			}
+		}
+	}
+
+	// Schemas come from the Go types
+	event := spec.Components.Schemas["Event"]
+	require.NotNil(t, event)
+	assert.Equal(t, "uuid", event.Properties["id"].Format)
+	assert.Equal(t, "date-time", event.Properties["start_time"].Format)
+	assert.True(t, event.Properties["published_date"].Nullable)
+
+	input := spec.Components.Schemas["APIEventInput"]
+	require.NotNil(t, input)
+	assert.Contains(t, input.Required, "title")
+	assert.Equal(t, []string{"draft", "published"}, input.Properties["status"].Enum)
+
+	create, ok := spec.Operation(http.MethodPost, "/events/create")
+	require.True(t, ok)
+	form := create.RequestBody.Content[openapi.MIMEMultipart].Schema
+	require.NotNil(t, form)
+	assert.Contains(t, form.Required, "csrf_token")
+	assert.Contains(t, form.Required, "start_time")
+	assert.Equal(t, "binary", form.Properties["image"].Format)
+	assert.Equal(t, "array", form.Properties["repeat_days"].Type)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(memory.New())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc["openapi"])
+	assert.Contains(t, doc["paths"], "/api/v1/events/{id}")
+}
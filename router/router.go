@@ -1,9 +1,12 @@
 package router
 
 import (
+	"event-analytics/apidoc"
 	"event-analytics/controllers"
 	"event-analytics/handler"
 	"event-analytics/middlewares"
+	"event-analytics/models"
+	"event-analytics/repository"
 	"fmt"
 	"html/template"
 	"time"
@@ -34,7 +37,9 @@ func formatForDisplay(t time.Time) string {
 	return t.Format("Jan 2, 2006 3:04 PM")
 }
 
-func SetupRouter() *gin.Engine {
+// New returns an engine with the template functions and the templates
+// matching pattern loaded
+func New(templates string) *gin.Engine {
 	r := gin.Default()
 	
 	// Important: First set the template functions
@@ -45,18 +50,39 @@ func SetupRouter() *gin.Engine {
 	})
 
 	// Then load the templates
-	r.LoadHTMLGlob("../templates/*")
+	r.LoadHTMLGlob(templates)
 
 	// Set template delimiters
 	r.Delims("{{", "}}")
+	return r
+}
 
+// SetupRouter registers every route over repos on an engine reading the
+// templates relative to this package, for the router tests
+func SetupRouter(repos *repository.Repositories) *gin.Engine {
+	r := New("../templates/*")
+	Register(r, repos)
+	return r
+}
+
+// Register adds the session middleware and every route to r, with the
+// controllers reading and writing through repos. The serve command and the
+// tests both register through it, so the OpenAPI test covers the routes
+// production serves. Middleware that has to run first, such as CSRF
+// protection, is added by the caller before.
+func Register(r *gin.Engine, repos *repository.Repositories) {
 	// Serve static files
 	r.Static("/static", "./static")
 	r.Static("/uploads", "./uploads")
 
+	// Controllers get their storage injected
+	events := controllers.NewEventController(repos.Events, repos.Users)
+	auth := controllers.NewAuthController(repos.Users)
+	outbox := controllers.NewOutboxController(repos.Outbox)
+	pages := handler.NewPages(repos.Events, repos.Outbox)
 
 	// Use middleware
-	r.Use(middlewares.UserMiddleware())
+	r.Use(middlewares.UserMiddleware(repos.Users))
 	r.Use(middlewares.FlashMiddleware())
 
 	// Routes
@@ -66,38 +92,92 @@ func SetupRouter() *gin.Engine {
 	userRoutes.Use(middlewares.PreventAuthenticatedAccess())
 	{
 		userRoutes.GET("/login", handler.ShowLoginPage)
-		userRoutes.POST("/login", controllers.Login)
+		userRoutes.POST("/login", auth.Login)
+		userRoutes.GET("/two-factor", handler.ShowTwoFactorPage)
+		userRoutes.POST("/two-factor", auth.TwoFactor)
 		userRoutes.GET("/register", handler.ShowRegistrationPage)
-		userRoutes.POST("/register", controllers.Register)
+		userRoutes.POST("/register", auth.Register)
 		userRoutes.GET("/verify", controllers.Verify)
+		userRoutes.GET("/resend-verification", handler.ShowResendVerificationPage)
+		userRoutes.POST("/resend-verification", auth.ResendVerification)
 		userRoutes.GET("/forgot-password", handler.ShowForgotPasswordPage)
-		userRoutes.POST("/forgot-password", controllers.ForgotPassword)
+		userRoutes.POST("/forgot-password", auth.ForgotPassword)
 		userRoutes.GET("/reset-password", handler.ShowResetPasswordPage)
-		userRoutes.POST("/reset-password", controllers.ResetPassword)
+		userRoutes.POST("/reset-password", auth.ResetPassword)
 	}
 
 	protected := r.Group("/user")
-	protected.Use(middlewares.AuthRequired())
+	protected.Use(middlewares.AuthRequired(repos.Users), middlewares.TwoFactorEnrolled(repos.Users))
 	{
-		protected.GET("/dashboard", handler.Dashboard)
+		protected.GET("/dashboard", pages.Dashboard)
 		protected.POST("/logout", controllers.Logout)
 		protected.GET("/profile", handler.ShowProfilePage)
 		protected.POST("/profile", controllers.EditProfile)
 		protected.GET("/change-password", handler.ShowChangePasswordPage)
-		protected.POST("/change-password", controllers.ChangePassword)
+		protected.POST("/change-password", auth.ChangePassword)
+		protected.POST("/calendar-feed", controllers.RegenerateCalendarFeed)
+		protected.POST("/calendar-feed/revoke", controllers.RevokeCalendarFeed)
+		protected.POST("/api-tokens", controllers.CreateAPIToken)
+		protected.POST("/api-tokens/:id/revoke", controllers.RevokeAPIToken)
+		protected.GET("/two-factor", handler.ShowTwoFactorSetupPage)
+		protected.POST("/two-factor/enroll", auth.EnrollTwoFactor)
+		protected.POST("/two-factor/confirm", auth.ConfirmTwoFactor)
+		protected.POST("/two-factor/disable", auth.DisableTwoFactor)
+		protected.POST("/two-factor/recovery-codes", auth.RegenerateRecoveryCodes)
 	}
 
 	protected_event := r.Group("/events")
-	protected_event.Use(middlewares.AuthRequired())
+	protected_event.Use(middlewares.AuthRequired(repos.Users), middlewares.TwoFactorEnrolled(repos.Users))
 	{
 		protected_event.GET("/new", handler.ShowCreateEventPage)
-		protected_event.POST("/create", controllers.CreateEvent)
-		protected_event.GET("/:id", handler.ShowEventDetails)
-		protected_event.GET("/edit/:id", handler.ShowEditEventPage)
-		protected_event.POST("/update/:id", controllers.UpdateEvent)
-		protected_event.POST("/delete/:id", controllers.DeleteEvent)
+		protected_event.POST("/create", events.CreateEvent)
+		protected_event.GET("/import", handler.ShowImportPage)
+		protected_event.POST("/import/preview", events.PreviewImport)
+		protected_event.POST("/import/commit", events.CommitImport)
+		protected_event.GET("/:id", pages.ShowEventDetails)
+		protected_event.GET("/:id/card", pages.ShowEventCard)
+		protected_event.GET("/edit/:id", pages.ShowEditEventPage)
+		protected_event.POST("/update/:id", events.UpdateEvent)
+		protected_event.POST("/delete/:id", events.DeleteEvent)
+		protected_event.GET("/:id/analytics", pages.ShowEventAnalytics)
+		protected_event.POST("/:id/track", events.TrackInteraction)
+		protected_event.POST("/:id/rsvp", events.RSVP)
+		protected_event.POST("/:id/rsvp/cancel", events.CancelRSVP)
+		protected_event.GET("/:id/occurrences/:start/edit", pages.ShowEditOccurrencePage)
+		protected_event.POST("/:id/occurrences/:start/update", events.UpdateOccurrence)
+		protected_event.POST("/:id/occurrences/:start/cancel", events.CancelOccurrence)
 	}
 
-	r.GET("/ws", handler.WebSocketHandler)
-	return r
+	admin := r.Group("/admin")
+	admin.Use(middlewares.AuthRequired(repos.Users), middlewares.TwoFactorEnrolled(repos.Users), middlewares.RequireRole(repos.Users, models.RoleAdmin))
+	{
+		admin.GET("/outbox", pages.ShowOutboxPage)
+		admin.POST("/outbox/:id/requeue", outbox.RequeueOutboxMessage)
+		admin.GET("/two-factor", handler.ShowTwoFactorAdminPage)
+		admin.POST("/two-factor", controllers.UpdateTwoFactorRoles)
+	}
+
+	// Token authenticated, calendar clients do not send session cookies
+	r.GET("/calendar/:token", handler.CalendarFeed)
+
+	// JSON API, CSRF exempt, authenticated by an API token or the session cookie
+	api := r.Group("/api/v1")
+	api.Use(middlewares.APIAuthRequired(repos.Users), middlewares.APITwoFactorEnrolled(repos.Users), middlewares.RequireJSON())
+	{
+		api.GET("/events", middlewares.RequireScope(models.ScopeEventsRead), events.APIListEvents)
+		api.POST("/events", middlewares.RequireScope(models.ScopeEventsWrite), events.APICreateEvent)
+		api.GET("/events/:id", middlewares.RequireScope(models.ScopeEventsRead), events.APIGetEvent)
+		api.PUT("/events/:id", middlewares.RequireScope(models.ScopeEventsWrite), events.APIUpdateEvent)
+		api.DELETE("/events/:id", middlewares.RequireScope(models.ScopeEventsWrite), events.APIDeleteEvent)
+		api.GET("/events/:id/analytics", middlewares.RequireScope(models.ScopeAnalyticsRead), events.APIEventAnalytics)
+		api.POST("/events/:id/interactions", middlewares.RequireScope(models.ScopeAnalyticsWrite), events.APITrackInteraction)
+	}
+
+	// OpenAPI document for every route above, see the apidoc package
+	r.GET("/api/openapi.json", apidoc.ServeSpec(r))
+
+	// Live streams answer like the API, WebSocket and EventSource clients
+	// do not follow redirects. API tokens need the events:read scope.
+	r.GET("/ws", middlewares.APIAuthRequired(repos.Users), middlewares.APITwoFactorEnrolled(repos.Users), middlewares.RequireScope(models.ScopeEventsRead), pages.WebSocketHandler)
+	r.GET("/sse", middlewares.APIAuthRequired(repos.Users), middlewares.APITwoFactorEnrolled(repos.Users), middlewares.RequireScope(models.ScopeEventsRead), pages.StreamEvents)
 }
\ No newline at end of file
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+)
+
+// Supported rollup granularities
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// AnalyticsBucket is one point of an event's traffic time series
+type AnalyticsBucket struct {
+	Bucket         time.Time `json:"bucket"`
+	Views          int64     `json:"views"`
+	DetailOpens    int64     `json:"detail_opens"`
+	Interactions   int64     `json:"interactions"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+}
+
+// ReferrerCount is the number of hits coming from a single referrer
+type ReferrerCount struct {
+	Referrer string `json:"referrer"`
+	Count    int64  `json:"count"`
+}
+
+// EventAnalyticsSummary aggregates everything shown on the event analytics page
+type EventAnalyticsSummary struct {
+	TotalViews       int64             `json:"total_views"`
+	TotalDetailOpens int64             `json:"total_detail_opens"`
+	UniqueVisitors   int64             `json:"unique_visitors"`
+	PrePublish       int64             `json:"pre_publish"`
+	PostPublish      int64             `json:"post_publish"`
+	Hourly           []AnalyticsBucket `json:"hourly"`
+	Daily            []AnalyticsBucket `json:"daily"`
+	Referrers        []ReferrerCount   `json:"referrers"`
+}
+
+// nextBucket returns the start of the bucket following t
+func nextBucket(t time.Time, granularity string) time.Time {
+	if granularity == GranularityDay {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(time.Hour)
+}
+
+// truncateToBucket aligns t to the start of its bucket in UTC, the zone
+// the rollup buckets are computed in whatever the server's zone
+func truncateToBucket(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	if granularity == GranularityDay {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(time.Hour)
+}
+
+// EventRollup loads the per-bucket counts of an event covering [from, to)
+// from the hourly or daily summary table maintained by RollupAnalytics.
+func EventRollup(eventID uuid.UUID, granularity string, from, to time.Time) ([]AnalyticsBucket, error) {
+	table, ok := rollupTables[granularity]
+	if !ok {
+		return nil, fmt.Errorf("unsupported granularity %q", granularity)
+	}
+
+	var buckets []AnalyticsBucket
+	err := config.DB.Table(table).
+		Select("bucket_start AS bucket, views, detail_opens, interactions, unique_visitors").
+		Where("event_id = ? AND bucket_start >= ? AND bucket_start < ?", eventID, truncateToBucket(from, granularity), to).
+		Order("bucket_start").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return fillBuckets(buckets, granularity, from, to), nil
+}
+
+// fillBuckets returns one bucket per step between from and to, using the
+// computed values where present and zeros for gaps so charts stay continuous.
+func fillBuckets(buckets []AnalyticsBucket, granularity string, from, to time.Time) []AnalyticsBucket {
+	byStart := make(map[int64]AnalyticsBucket, len(buckets))
+	for _, b := range buckets {
+		byStart[truncateToBucket(b.Bucket, granularity).Unix()] = b
+	}
+
+	var filled []AnalyticsBucket
+	for t := truncateToBucket(from, granularity); t.Before(to); t = nextBucket(t, granularity) {
+		b, ok := byStart[t.Unix()]
+		if !ok {
+			b = AnalyticsBucket{}
+		}
+		b.Bucket = t
+		filled = append(filled, b)
+	}
+	return filled
+}
+
+// UniqueVisitors returns the approximate number of unique visitors of an
+// event between from and to using the HyperLogLog sketches, falling back to
+// an exact count over the raw table when Redis is unavailable.
+func UniqueVisitors(eventID uuid.UUID, from, to time.Time) (int64, error) {
+	if config.UniqueVisitors != nil {
+		count, err := config.UniqueVisitors.CountRange(context.Background(), eventID.String(), from, to)
+		if err == nil {
+			return count, nil
+		}
+	}
+
+	var count int64
+	err := config.DB.Model(&models.AnalyticsEvent{}).
+		Where("event_id = ? AND created_at >= ? AND created_at < ?", eventID, from, to).
+		Distinct("visitor_id").
+		Count(&count).Error
+	return count, err
+}
+
+// TopReferrers returns the most common referrers for an event
+func TopReferrers(eventID uuid.UUID, limit int) ([]ReferrerCount, error) {
+	var referrers []ReferrerCount
+	err := config.DB.Model(&models.AnalyticsEvent{}).
+		Select("COALESCE(NULLIF(referrer, ''), '(direct)') AS referrer, COUNT(*) AS count").
+		Where("event_id = ?", eventID).
+		Group("COALESCE(NULLIF(referrer, ''), '(direct)')").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&referrers).Error
+	return referrers, err
+}
+
+// GetEventAnalyticsSummary builds the full analytics report for an event
+func GetEventAnalyticsSummary(event *models.Event, days int) (*EventAnalyticsSummary, error) {
+	summary := &EventAnalyticsSummary{}
+
+	var totals struct {
+		Views       int64
+		DetailOpens int64
+		PrePublish  int64
+		PostPublish int64
+	}
+	err := config.DB.Model(&models.AnalyticsDailyRollup{}).
+		Select(`COALESCE(SUM(views), 0) AS views,
+			COALESCE(SUM(detail_opens), 0) AS detail_opens,
+			COALESCE(SUM(pre_publish), 0) AS pre_publish,
+			COALESCE(SUM(post_publish), 0) AS post_publish`).
+		Where("event_id = ?", event.ID).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalViews = totals.Views
+	summary.TotalDetailOpens = totals.DetailOpens
+	summary.PrePublish = totals.PrePublish
+	summary.PostPublish = totals.PostPublish
+
+	now := time.Now()
+	if summary.Hourly, err = EventRollup(event.ID, GranularityHour, now.Add(-47*time.Hour).Truncate(time.Hour), now); err != nil {
+		return nil, err
+	}
+	dayStart := truncateToBucket(now, GranularityDay).AddDate(0, 0, -(days - 1))
+	if summary.Daily, err = EventRollup(event.ID, GranularityDay, dayStart, now); err != nil {
+		return nil, err
+	}
+	if summary.UniqueVisitors, err = UniqueVisitors(event.ID, dayStart, now); err != nil {
+		return nil, err
+	}
+	if summary.Referrers, err = TopReferrers(event.ID, 10); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
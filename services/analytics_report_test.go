@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillBuckets(t *testing.T) {
+	from := time.Date(2024, 1, 15, 10, 20, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	buckets := []AnalyticsBucket{
+		{Bucket: time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC), Views: 3, UniqueVisitors: 2},
+		{Bucket: time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC), Views: 1, UniqueVisitors: 1},
+	}
+
+	filled := fillBuckets(buckets, GranularityHour, from, to)
+
+	assert.Len(t, filled, 4)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), filled[0].Bucket)
+	assert.Equal(t, int64(0), filled[0].Views)
+	assert.Equal(t, int64(3), filled[1].Views)
+	assert.Equal(t, int64(0), filled[2].Views)
+	assert.Equal(t, int64(1), filled[3].Views)
+}
+
+func TestFillBucketsDaily(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+
+	buckets := []AnalyticsBucket{
+		{Bucket: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), DetailOpens: 5},
+	}
+
+	filled := fillBuckets(buckets, GranularityDay, from, to)
+
+	assert.Len(t, filled, 3)
+	assert.Equal(t, int64(5), filled[1].DetailOpens)
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), filled[2].Bucket)
+}
+
+func TestAlignRange(t *testing.T) {
+	from := time.Date(2024, 1, 15, 10, 20, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 15, 12, 5, 0, 0, time.UTC)
+
+	alignedFrom, alignedTo := alignRange(GranularityHour, from, to)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), alignedFrom)
+	assert.Equal(t, time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC), alignedTo)
+
+	alignedFrom, alignedTo = alignRange(GranularityDay, from, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), alignedFrom)
+	assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), alignedTo)
+}
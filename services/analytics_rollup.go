@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"gorm.io/gorm"
+)
+
+// Summary table backing each rollup granularity
+var rollupTables = map[string]string{
+	GranularityHour: "analytics_hourly_rollups",
+	GranularityDay:  "analytics_daily_rollups",
+}
+
+// alignRange widens [from, to) so both ends fall on bucket boundaries
+func alignRange(granularity string, from, to time.Time) (time.Time, time.Time) {
+	from = truncateToBucket(from, granularity)
+	if aligned := truncateToBucket(to, granularity); !aligned.Equal(to) {
+		to = nextBucket(aligned, granularity)
+	}
+	return from, to
+}
+
+// RollupAnalytics recomputes the summary rows of every event for the buckets
+// overlapping [from, to) from the raw tracking table. Existing rows in the
+// range are replaced inside one transaction, so re-running it is idempotent.
+// It returns the number of rollup rows written.
+func RollupAnalytics(granularity string, from, to time.Time) (int64, error) {
+	table, ok := rollupTables[granularity]
+	if !ok {
+		return 0, fmt.Errorf("unsupported granularity %q", granularity)
+	}
+	bucket, err := config.Dialect.TruncateTime(granularity, "created_at")
+	if err != nil {
+		return 0, err
+	}
+	from, to = alignRange(granularity, from, to)
+
+	var rows int64
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Serialise concurrent rollups of the same table across replicas
+		if err := config.Dialect.LockTx(tx, table); err != nil {
+			return err
+		}
+
+		if err := tx.Exec(
+			fmt.Sprintf("DELETE FROM %s WHERE bucket_start >= ? AND bucket_start < ?", table), from, to,
+		).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s (event_id, bucket_start, views, detail_opens, edits, deletes, interactions,
+				unique_visitors, pre_publish, post_publish, updated_at)
+			SELECT event_id, %s,
+				COUNT(*) FILTER (WHERE action = ?),
+				COUNT(*) FILTER (WHERE action = ?),
+				COUNT(*) FILTER (WHERE action = ?),
+				COUNT(*) FILTER (WHERE action = ?),
+				COUNT(*) FILTER (WHERE action = ?),
+				COUNT(DISTINCT visitor_id),
+				COUNT(*) FILTER (WHERE event_status = ?),
+				COUNT(*) FILTER (WHERE event_status <> ?),
+				?
+			FROM analytics_events
+			WHERE created_at >= ? AND created_at < ?
+			GROUP BY 1, 2`, table, bucket),
+			models.ActionView, models.ActionDetailOpen, models.ActionEdit, models.ActionDelete, models.ActionInteraction,
+			"draft", "draft",
+			time.Now(),
+			from, to,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return nil
+	})
+
+	return rows, err
+}
+
+// BackfillRollups recomputes hourly and daily rollups for every day between
+// from and to, one day per transaction so large ranges don't hold long locks.
+func BackfillRollups(from, to time.Time, progress func(day time.Time, rows int64)) error {
+	from, to = alignRange(GranularityDay, from, to)
+
+	for day := from; day.Before(to); day = nextBucket(day, GranularityDay) {
+		next := nextBucket(day, GranularityDay)
+
+		hourly, err := RollupAnalytics(GranularityHour, day, next)
+		if err != nil {
+			return fmt.Errorf("hourly rollup for %s: %w", day.Format("2006-01-02"), err)
+		}
+		daily, err := RollupAnalytics(GranularityDay, day, next)
+		if err != nil {
+			return fmt.Errorf("daily rollup for %s: %w", day.Format("2006-01-02"), err)
+		}
+
+		if progress != nil {
+			progress(day, hourly+daily)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const visitorCookie = "visitor_id"
+
+// VisitorID returns the anonymous visitor identifier for the request,
+// issuing a long-lived cookie the first time a browser is seen. The id is
+// kept in the context, so a request tracking several events issues one.
+func VisitorID(c *gin.Context) string {
+	if id := c.GetString(visitorCookie); id != "" {
+		return id
+	}
+
+	id, err := c.Cookie(visitorCookie)
+	if err != nil || id == "" {
+		id = uuid.New().String()
+		c.SetCookie(visitorCookie, id, 365*24*60*60, "/", "", false, true)
+	}
+	c.Set(visitorCookie, id)
+	return id
+}
+
+// TrackEventAction queues an analytics record for the event. It never
+// blocks the request; records are dropped if the tracker is saturated.
+func TrackEventAction(c *gin.Context, event *models.Event, action, name string) {
+	if config.Tracker == nil || event == nil {
+		return
+	}
+
+	record := models.AnalyticsEvent{
+		EventID:     event.ID,
+		VisitorID:   VisitorID(c),
+		Action:      action,
+		Name:        name,
+		EventStatus: event.Status,
+		Referrer:    truncate(c.Request.Referer(), 512),
+		UserAgent:   truncate(c.Request.UserAgent(), 512),
+		IPAddress:   c.ClientIP(),
+	}
+
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(*models.User); ok && user != nil {
+			userID := user.ID
+			record.UserID = &userID
+		}
+	}
+
+	config.Tracker.Track(record)
+}
+
+// TrackEventViews records a dashboard impression for every listed event
+func TrackEventViews(c *gin.Context, events []models.Event) {
+	for i := range events {
+		TrackEventAction(c, &events[i], models.ActionView, "")
+	}
+}
+
+// truncate shortens value to at most length characters
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return value
+}
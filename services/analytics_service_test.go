@@ -0,0 +1,36 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisitorIDIsIssuedOncePerRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/dashboard", nil)
+
+	id := VisitorID(c)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, VisitorID(c), "every event on a page belongs to the same visitor")
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	// A returning browser keeps its id
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/dashboard", nil)
+	c.Request.AddCookie(&http.Cookie{Name: visitorCookie, Value: id})
+	assert.Equal(t, id, VisitorID(c))
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestTruncateKeepsCharactersWhole(t *testing.T) {
+	assert.Equal(t, "héllo", truncate("héllo", 5))
+	assert.Equal(t, "hé", truncate("héllo", 2))
+	assert.Equal(t, "日本", truncate("日本語", 2))
+}
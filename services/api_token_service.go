@@ -0,0 +1,143 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// APITokenPrefix marks personal access tokens so they are easy to spot
+	// in logs and secret scanners
+	APITokenPrefix = "eat_"
+
+	apiTokenHintLength = 8
+
+	// apiTokenTouchInterval limits last-used updates to one write per
+	// token per interval
+	apiTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIToken = errors.New("invalid API token")
+	ErrNoScopes        = errors.New("select at least one scope")
+)
+
+// normalizeScopes validates scopes and returns them deduplicated in the
+// order of models.Scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		known := false
+		for _, s := range models.Scopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, errors.New("unknown scope " + scope)
+		}
+		requested[scope] = true
+	}
+
+	var normalized []string
+	for _, s := range models.Scopes {
+		if requested[s] {
+			normalized = append(normalized, s)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ErrNoScopes
+	}
+	return normalized, nil
+}
+
+// CreateAPIToken issues a new token for the user. The plain token is only
+// available here.
+func CreateAPIToken(userID uuid.UUID, name string, scopes []string) (string, *models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("token name is required")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	apiToken := &models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Hint:      token[:len(APITokenPrefix)+apiTokenHintLength],
+		TokenHash: hashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if err := config.DB.Create(apiToken).Error; err != nil {
+		return "", nil, err
+	}
+	return token, apiToken, nil
+}
+
+// ListAPITokens returns the user's tokens, newest first
+func ListAPITokens(userID uuid.UUID) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := config.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeAPIToken deletes one of the user's tokens
+func RevokeAPIToken(userID, tokenID uuid.UUID) error {
+	result := config.DB.Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIToken resolves a bearer token to its token record and
+// owner, and records the use
+func AuthenticateAPIToken(token string) (*models.User, *models.APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, nil, ErrInvalidAPIToken
+	}
+
+	var apiToken models.APIToken
+	if err := config.DB.Where("token_hash = ?", hashToken(token)).First(&apiToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", apiToken.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenTouchInterval {
+		config.DB.Model(&apiToken).UpdateColumn("last_used_at", now)
+		apiToken.LastUsedAt = &now
+	}
+	return &user, &apiToken, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"event-analytics/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "ordered and deduplicated",
+			scopes: []string{models.ScopeAnalyticsRead, models.ScopeEventsWrite, models.ScopeEventsRead, models.ScopeEventsWrite},
+			want:   []string{models.ScopeEventsRead, models.ScopeEventsWrite, models.ScopeAnalyticsRead},
+		},
+		{name: "empty", scopes: nil, wantErr: true},
+		{name: "unknown", scopes: []string{models.ScopeEventsRead, "admin"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeScopes(tt.scopes)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAPITokenHasScope(t *testing.T) {
+	token := models.APIToken{Scopes: "events:read analytics:write"}
+	assert.True(t, token.HasScope(models.ScopeEventsRead))
+	assert.True(t, token.HasScope(models.ScopeAnalyticsWrite))
+	assert.False(t, token.HasScope(models.ScopeEventsWrite))
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lifetimes of the tokens sent by email
+const (
+	VerificationTokenTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+// ErrInvalidToken is returned for unknown, expired and already used
+// verification and reset tokens alike, so callers can't tell them apart
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// newEmailToken returns a random token for a link in an email
+func newEmailToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateVerificationToken stores a verification token for the user through
+// tx, the transaction creating the user, and returns the plain token
+func CreateVerificationToken(tx *gorm.DB, userID uuid.UUID) (string, error) {
+	token, err := newEmailToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&models.VerificationToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(VerificationTokenTTL),
+	}).Error
+	return token, err
+}
+
+// VerifyEmail uses up a verification token and marks its user verified
+func VerifyEmail(token string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var verification models.VerificationToken
+		if err := useToken(tx, &verification, token); err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", verification.UserID).Update("is_verified", true).Error
+	})
+}
+
+// CreatePasswordReset stores a reset token for email through tx and
+// returns the plain token
+func CreatePasswordReset(tx *gorm.DB, email string) (string, error) {
+	token, err := newEmailToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&models.PasswordReset{
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	}).Error
+	return token, err
+}
+
+// PasswordResetEmail returns the email a usable reset token was sent to,
+// without using it up
+func PasswordResetEmail(token string) (string, error) {
+	var reset models.PasswordReset
+	err := config.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+		First(&reset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrInvalidToken
+	}
+	return reset.Email, err
+}
+
+// UsePasswordReset uses up a reset token through tx, the transaction
+// changing the password, and returns the email it was sent to. Rolling tx
+// back makes the token usable again.
+func UsePasswordReset(tx *gorm.DB, token string) (string, error) {
+	var reset models.PasswordReset
+	if err := useToken(tx, &reset, token); err != nil {
+		return "", err
+	}
+	return reset.Email, nil
+}
+
+// useToken marks the unused, unexpired token of record's table used and
+// loads the row into record. The conditional update lets only one of two
+// concurrent requests have the token.
+func useToken(tx *gorm.DB, record interface{}, token string) error {
+	hash, now := hashToken(token), time.Now()
+	result := tx.Model(record).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidToken
+	}
+	return tx.Where("token_hash = ?", hash).First(record).Error
+}
+
+// PurgeExpiredTokens deletes the verification and reset tokens that are
+// used or expired and returns how many went
+func PurgeExpiredTokens() (int64, error) {
+	var purged int64
+	now := time.Now()
+	for _, model := range []interface{}{&models.VerificationToken{}, &models.PasswordReset{}} {
+		result := config.DB.Where("used_at IS NOT NULL OR expires_at <= ?", now).Delete(model)
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+	}
+	return purged, nil
+}
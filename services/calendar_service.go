@@ -0,0 +1,195 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/ical"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	calendarProdID    = "-//event-analytics//Events//EN"
+	calendarUIDDomain = "event-analytics"
+
+	// exceptionHorizon bounds how far ahead "this and future" edits are
+	// expanded into per-occurrence overrides for calendar clients
+	exceptionHorizon = 2 * 365 * 24 * time.Hour
+)
+
+// CalendarStatus maps an event status onto a VEVENT STATUS
+func CalendarStatus(status string) string {
+	if status == "draft" {
+		return ical.StatusTentative
+	}
+	return ical.StatusConfirmed
+}
+
+// EventToICal converts an event into VEVENTs: the event itself, plus one
+// RECURRENCE-ID component per edited occurrence of a series. Cancelled
+// occurrences become EXDATEs.
+func EventToICal(event *models.Event, overrides []models.EventOccurrenceOverride, baseURL string, now time.Time) ([]ical.Event, error) {
+	master := ical.Event{
+		UID:          event.ID.String() + "@" + calendarUIDDomain,
+		DTStamp:      now,
+		Created:      event.CreatedAt,
+		LastModified: event.UpdatedAt,
+		Start:        event.StartTime,
+		End:          event.EndTime,
+		Summary:      event.Title,
+		Description:  event.Description,
+		Location:     event.Location,
+		URL:          baseURL + "/events/" + event.ID.String(),
+		Status:       CalendarStatus(event.Status),
+		RRule:        event.RecurrenceRule,
+	}
+	if event.RecurrenceRule == "" || len(overrides) == 0 {
+		return []ical.Event{master}, nil
+	}
+
+	horizon := now.Add(exceptionHorizon)
+	for _, o := range overrides {
+		if !o.ThisAndFuture && o.OccurrenceStart.After(horizon) {
+			horizon = o.OccurrenceStart
+		}
+	}
+
+	var changed []Occurrence
+	err := expand(event, overrides, func(occ Occurrence) bool {
+		if occ.OriginalStart.After(horizon) {
+			return false
+		}
+		if occ.Cancelled {
+			master.ExDates = append(master.ExDates, occ.OriginalStart)
+		} else if occ.Overridden {
+			changed = append(changed, occ)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := []ical.Event{master}
+	for _, occ := range changed {
+		instance := master
+		instance.RRule = ""
+		instance.ExDates = nil
+		instance.RecurrenceID = occ.OriginalStart
+		instance.Start = occ.StartTime
+		instance.End = occ.EndTime
+		instance.Summary = occ.Title
+		instance.Description = occ.Description
+		instance.Location = occ.Location
+		events = append(events, instance)
+	}
+	return events, nil
+}
+
+// BuildCalendar converts events into an iCalendar document
+func BuildCalendar(name string, events []models.Event, baseURL string) (*ical.Calendar, error) {
+	var ids []uuid.UUID
+	for _, event := range events {
+		if event.RecurrenceRule != "" {
+			ids = append(ids, event.ID)
+		}
+	}
+	overrides, err := LoadOverrides(ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &ical.Calendar{
+		ProdID:   calendarProdID,
+		Name:     name,
+		Timezone: "UTC",
+		Refresh:  time.Hour,
+	}
+	now := time.Now()
+	for i := range events {
+		vevents, err := EventToICal(&events[i], overrides[events[i].ID], baseURL, now)
+		if err != nil {
+			return nil, err
+		}
+		cal.Events = append(cal.Events, vevents...)
+	}
+	return cal, nil
+}
+
+// FeedEvents returns the events in a user's calendar feed: every published
+// or past event, plus the user's own drafts
+func FeedEvents(userID uuid.UUID) ([]models.Event, error) {
+	var events []models.Event
+	err := config.DB.
+		Where("status IN ? OR (status = ? AND created_by = ?)", []string{"published", "expired"}, "draft", userID).
+		Order("start_time").
+		Find(&events).Error
+	return events, err
+}
+
+// hashToken returns the hex SHA-256 of a secret token, the form in which
+// feed and API tokens are stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateCalendarFeedToken creates a new feed token for the user,
+// revoking any previous one. The plain token is only available here.
+func GenerateCalendarFeedToken(userID uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	feed := models.CalendarFeed{UserID: userID, TokenHash: hashToken(token)}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"token_hash": feed.TokenHash, "last_accessed_at": nil, "updated_at": time.Now()}),
+	}).Create(&feed).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetCalendarFeed returns the user's feed, or nil if none has been generated
+func GetCalendarFeed(userID uuid.UUID) (*models.CalendarFeed, error) {
+	var feeds []models.CalendarFeed
+	if err := config.DB.Where("user_id = ?", userID).Limit(1).Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+	if len(feeds) == 0 {
+		return nil, nil
+	}
+	return &feeds[0], nil
+}
+
+// RevokeCalendarFeed deletes the user's feed token
+func RevokeCalendarFeed(userID uuid.UUID) error {
+	return config.DB.Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error
+}
+
+// CalendarFeedUser resolves a feed token to its owner and records the access
+func CalendarFeedUser(token string) (*models.User, error) {
+	var feed models.CalendarFeed
+	if err := config.DB.Where("token_hash = ?", hashToken(token)).First(&feed).Error; err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", feed.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	config.DB.Model(&feed).UpdateColumn("last_accessed_at", time.Now())
+	return &user, nil
+}
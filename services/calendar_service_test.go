@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/pkg/ical"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarStatus(t *testing.T) {
+	assert.Equal(t, ical.StatusTentative, CalendarStatus("draft"))
+	assert.Equal(t, ical.StatusConfirmed, CalendarStatus("published"))
+	assert.Equal(t, ical.StatusConfirmed, CalendarStatus("expired"))
+}
+
+func TestEventToICal(t *testing.T) {
+	event := weeklyEvent()
+	event.ID = uuid.MustParse("7f1c5e7a-0000-4000-8000-000000000001")
+	event.Status = "published"
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	vevents, err := EventToICal(event, nil, "https://events.example.com", now)
+	require.NoError(t, err)
+	require.Len(t, vevents, 1)
+	assert.Equal(t, "7f1c5e7a-0000-4000-8000-000000000001@event-analytics", vevents[0].UID)
+	assert.Equal(t, "https://events.example.com/events/7f1c5e7a-0000-4000-8000-000000000001", vevents[0].URL)
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=5", vevents[0].RRule)
+	assert.Equal(t, now, vevents[0].DTStamp)
+
+	overrides := []models.EventOccurrenceOverride{
+		{OccurrenceStart: time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC), Cancelled: true},
+		{OccurrenceStart: time.Date(2024, 1, 22, 18, 0, 0, 0, time.UTC), ThisAndFuture: true, Location: "Hall B"},
+	}
+	vevents, err = EventToICal(event, overrides, "", now)
+	require.NoError(t, err)
+	require.Len(t, vevents, 3)
+	assert.Equal(t, []time.Time{time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC)}, vevents[0].ExDates)
+	for i, day := range []int{22, 29} {
+		instance := vevents[i+1]
+		assert.Equal(t, vevents[0].UID, instance.UID)
+		assert.Equal(t, time.Date(2024, 1, day, 18, 0, 0, 0, time.UTC), instance.RecurrenceID)
+		assert.Equal(t, "Hall B", instance.Location)
+		assert.Empty(t, instance.RRule)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"log"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/realtime"
+
+	"github.com/google/uuid"
+)
+
+// Domain events published to config.Hub when events change
+const (
+	EventCreated   = "event.created"
+	EventUpdated   = "event.updated"
+	EventDeleted   = "event.deleted"
+	EventPublished = "event.published"
+	EventExpired   = "event.expired"
+)
+
+// EventChange is the payload of an event domain event. It carries no
+// content, subscribers fetch what the user may see, since drafts are only
+// visible to their owner.
+type EventChange struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+}
+
+// PublishEventChange announces a change to event on the dashboard and
+// event:<id> topics
+func PublishEventChange(changeType string, event *models.Event) {
+	PublishEventChanges(changeType, []models.Event{*event})
+}
+
+// PublishEventChanges announces the same change for several events
+func PublishEventChanges(changeType string, events []models.Event) {
+	for i := range events {
+		change := EventChange{ID: events[i].ID, Status: events[i].Status}
+		for _, topic := range []string{realtime.TopicDashboard, realtime.EventTopic(events[i].ID.String())} {
+			if err := config.Hub.Publish(realtime.Message{Topic: topic, Type: changeType, Data: change}); err != nil {
+				log.Printf("Live: failed to publish %s for event %s: %v", changeType, events[i].ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/realtime"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishEventChange(t *testing.T) {
+	event := models.Event{ID: uuid.New(), Title: "Secret draft", Status: "draft"}
+
+	dashboard := config.Hub.Register("dashboard-viewer", 4)
+	defer config.Hub.Unregister(dashboard)
+	follower := config.Hub.Register("event-follower", 4)
+	defer config.Hub.Unregister(follower)
+	require.NoError(t, config.Hub.Subscribe(dashboard, realtime.TopicDashboard))
+	require.NoError(t, config.Hub.Subscribe(follower, realtime.EventTopic(event.ID.String())))
+
+	PublishEventChange(EventUpdated, &event)
+
+	for _, client := range []*realtime.Client{dashboard, follower} {
+		require.Len(t, client.Send(), 1)
+		payload := <-client.Send()
+		assert.NotContains(t, string(payload), event.Title, "content is fetched per user, not broadcast")
+
+		var msg struct {
+			Type string      `json:"type"`
+			Data EventChange `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &msg))
+		assert.Equal(t, EventUpdated, msg.Type)
+		assert.Equal(t, EventChange{ID: event.ID, Status: "draft"}, msg.Data)
+	}
+}
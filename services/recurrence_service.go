@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/rrule"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Edit scopes for a single occurrence of a series
+const (
+	ScopeThis   = "this"
+	ScopeFuture = "future"
+)
+
+var (
+	ErrNotRecurring       = errors.New("event is not recurring")
+	ErrOccurrenceNotFound = errors.New("occurrence not found")
+	ErrFirstOccurrence    = errors.New("cannot end a series at its first occurrence")
+)
+
+// Occurrence is one instance of an event series with overrides applied
+type Occurrence struct {
+	models.Event
+	OriginalStart time.Time // RECURRENCE-ID, stable across edits
+	Overridden    bool
+	Cancelled     bool
+}
+
+// Key identifies the occurrence in URLs
+func (o Occurrence) Key() int64 {
+	return o.OriginalStart.Unix()
+}
+
+// ApplyRecurrence validates and normalises value into event.RecurrenceRule
+// and recomputes SeriesEndsAt. An empty value makes the event a one-off.
+func ApplyRecurrence(event *models.Event, value string) error {
+	event.RecurrenceRule = ""
+	event.SeriesEndsAt = nil
+	if value == "" {
+		return nil
+	}
+
+	rule, err := rrule.Parse(value)
+	if err != nil {
+		return err
+	}
+	event.RecurrenceRule = rule.String()
+
+	if last, ok := rule.Last(event.StartTime); ok {
+		end := last.Add(event.EndTime.Sub(event.StartTime))
+		event.SeriesEndsAt = &end
+	}
+	return nil
+}
+
+// DescribeRecurrence returns a human readable summary of the event's rule
+func DescribeRecurrence(event *models.Event) string {
+	if event.RecurrenceRule == "" {
+		return ""
+	}
+	rule, err := rrule.Parse(event.RecurrenceRule)
+	if err != nil {
+		return ""
+	}
+	return rule.Describe()
+}
+
+// expand walks the series in order, calling fn for every occurrence
+// (including cancelled ones) until fn returns false.
+func expand(event *models.Event, overrides []models.EventOccurrenceOverride, fn func(Occurrence) bool) error {
+	if event.RecurrenceRule == "" {
+		fn(Occurrence{Event: *event, OriginalStart: event.StartTime})
+		return nil
+	}
+
+	rule, err := rrule.Parse(event.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+
+	exact := make(map[int64]models.EventOccurrenceOverride)
+	var future []models.EventOccurrenceOverride
+	for _, o := range overrides {
+		if o.ThisAndFuture {
+			future = append(future, o)
+		} else {
+			exact[o.OccurrenceStart.Unix()] = o
+		}
+	}
+	sort.Slice(future, func(i, j int) bool {
+		return future[i].OccurrenceStart.Before(future[j].OccurrenceStart)
+	})
+
+	duration := event.EndTime.Sub(event.StartTime)
+	it := rule.Iterator(event.StartTime)
+	for {
+		start, ok := it.Next()
+		if !ok {
+			return nil
+		}
+
+		occ := Occurrence{Event: *event, OriginalStart: start}
+		occ.StartTime = start
+		occ.EndTime = start.Add(duration)
+
+		// The latest "this and future" edit at or before this occurrence applies first
+		for i := len(future) - 1; i >= 0; i-- {
+			if !future[i].OccurrenceStart.After(start) {
+				applyOverride(&occ, future[i])
+				break
+			}
+		}
+		if o, ok := exact[start.Unix()]; ok {
+			applyOverride(&occ, o)
+		}
+
+		if !fn(occ) {
+			return nil
+		}
+	}
+}
+
+// applyOverride copies the overridden fields onto occ. Time changes are
+// applied as a shift from the overridden occurrence, so a "this and
+// future" move keeps every later occurrence on the same new schedule.
+func applyOverride(occ *Occurrence, o models.EventOccurrenceOverride) {
+	occ.Overridden = true
+	occ.Cancelled = o.Cancelled
+	if o.Title != "" {
+		occ.Title = o.Title
+	}
+	if o.Description != "" {
+		occ.Description = o.Description
+	}
+	if o.Location != "" {
+		occ.Location = o.Location
+	}
+	if o.StartTime != nil {
+		occ.StartTime = occ.OriginalStart.Add(o.StartTime.Sub(o.OccurrenceStart))
+		if o.EndTime != nil {
+			occ.EndTime = occ.StartTime.Add(o.EndTime.Sub(*o.StartTime))
+		}
+	}
+}
+
+// ExpandOccurrences returns up to limit non-cancelled occurrences that have
+// not ended by from.
+func ExpandOccurrences(event *models.Event, overrides []models.EventOccurrenceOverride, from time.Time, limit int) ([]Occurrence, error) {
+	var out []Occurrence
+	err := expand(event, overrides, func(occ Occurrence) bool {
+		if !occ.Cancelled && occ.EndTime.After(from) {
+			out = append(out, occ)
+		}
+		return len(out) < limit
+	})
+	return out, err
+}
+
+// FindOccurrence returns the occurrence whose original start is originalStart
+func FindOccurrence(event *models.Event, overrides []models.EventOccurrenceOverride, originalStart time.Time) (*Occurrence, error) {
+	if event.RecurrenceRule == "" {
+		return nil, ErrNotRecurring
+	}
+
+	var found *Occurrence
+	err := expand(event, overrides, func(occ Occurrence) bool {
+		if occ.OriginalStart.Unix() == originalStart.Unix() {
+			found = &occ
+		}
+		return found == nil && occ.OriginalStart.Before(originalStart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrOccurrenceNotFound
+	}
+	return found, nil
+}
+
+// LoadOverrides returns the occurrence overrides of the given events
+func LoadOverrides(eventIDs ...uuid.UUID) (map[uuid.UUID][]models.EventOccurrenceOverride, error) {
+	result := make(map[uuid.UUID][]models.EventOccurrenceOverride)
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	var overrides []models.EventOccurrenceOverride
+	if err := config.DB.Where("event_id IN ?", eventIDs).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		result[o.EventID] = append(result[o.EventID], o)
+	}
+	return result, nil
+}
+
+// GetOccurrence loads the overrides of an event and finds one occurrence
+func GetOccurrence(event *models.Event, originalStart time.Time) (*Occurrence, error) {
+	overrides, err := LoadOverrides(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return FindOccurrence(event, overrides[event.ID], originalStart)
+}
+
+// ClearOverrides removes every occurrence override of an event
+func ClearOverrides(eventID uuid.UUID) error {
+	return config.DB.Where("event_id = ?", eventID).Delete(&models.EventOccurrenceOverride{}).Error
+}
+
+// UpcomingOccurrences returns the next limit occurrences of an event
+func UpcomingOccurrences(event *models.Event, limit int) ([]Occurrence, error) {
+	overrides, err := LoadOverrides(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return ExpandOccurrences(event, overrides[event.ID], time.Now(), limit)
+}
+
+// ApplyNextOccurrences shows recurring events in the list as their next
+// upcoming occurrence and fills in the Recurrence summary.
+func ApplyNextOccurrences(events []models.Event) error {
+	var ids []uuid.UUID
+	for _, event := range events {
+		if event.RecurrenceRule != "" {
+			ids = append(ids, event.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	overrides, err := LoadOverrides(ids...)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range events {
+		event := &events[i]
+		if event.RecurrenceRule == "" {
+			continue
+		}
+		event.Recurrence = DescribeRecurrence(event)
+
+		next, err := ExpandOccurrences(event, overrides[event.ID], now, 1)
+		if err != nil || len(next) == 0 {
+			continue
+		}
+		event.Title = next[0].Title
+		event.Location = next[0].Location
+		event.StartTime = next[0].StartTime
+		event.EndTime = next[0].EndTime
+	}
+	return nil
+}
+
+// SaveOccurrenceOverride stores an edit of one occurrence. A "this and
+// future" edit replaces any overrides from that occurrence onwards.
+func SaveOccurrenceOverride(override *models.EventOccurrenceOverride) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if override.ThisAndFuture {
+			if err := tx.Where("event_id = ? AND occurrence_start >= ?", override.EventID, override.OccurrenceStart).
+				Delete(&models.EventOccurrenceOverride{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "event_id"}, {Name: "occurrence_start"}, {Name: "this_and_future"}},
+			DoUpdates: clause.AssignmentColumns([]string{"cancelled", "title", "description", "location", "start_time", "end_time", "updated_at"}),
+		}).Create(override).Error
+	})
+}
+
+// CancelOccurrence cancels one occurrence, or ends the series before it
+// when scope is ScopeFuture.
+func CancelOccurrence(event *models.Event, originalStart time.Time, scope string) error {
+	if scope != ScopeFuture {
+		return SaveOccurrenceOverride(&models.EventOccurrenceOverride{
+			EventID:         event.ID,
+			OccurrenceStart: originalStart,
+			Cancelled:       true,
+		})
+	}
+
+	if !originalStart.After(event.StartTime) {
+		return ErrFirstOccurrence
+	}
+
+	rule, err := rrule.Parse(event.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+	rule.Count = 0
+	rule.Until = originalStart.Add(-time.Second).UTC()
+	if err := ApplyRecurrence(event, rule.String()); err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND occurrence_start >= ?", event.ID, originalStart).
+			Delete(&models.EventOccurrenceOverride{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(event).Select("recurrence_rule", "series_ends_at").Updates(event).Error
+	})
+}
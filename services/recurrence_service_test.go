@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"event-analytics/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func weeklyEvent() *models.Event {
+	start := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+	return &models.Event{
+		Title:          "Meetup",
+		Location:       "Hall A",
+		StartTime:      start,
+		EndTime:        start.Add(2 * time.Hour),
+		RecurrenceRule: "FREQ=WEEKLY;COUNT=5",
+	}
+}
+
+func occurrenceStarts(occurrences []Occurrence) []string {
+	var out []string
+	for _, occ := range occurrences {
+		out = append(out, occ.StartTime.Format("01-02 15:04"))
+	}
+	return out
+}
+
+func TestApplyRecurrence(t *testing.T) {
+	event := weeklyEvent()
+	require.NoError(t, ApplyRecurrence(event, "RRULE:FREQ=WEEKLY;COUNT=3"))
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=3", event.RecurrenceRule)
+	require.NotNil(t, event.SeriesEndsAt)
+	assert.Equal(t, time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC), *event.SeriesEndsAt)
+
+	require.NoError(t, ApplyRecurrence(event, "FREQ=DAILY"))
+	assert.Nil(t, event.SeriesEndsAt)
+
+	assert.Error(t, ApplyRecurrence(event, "FREQ=YEARLY"))
+}
+
+func TestExpandOccurrences(t *testing.T) {
+	event := weeklyEvent()
+	movedStart := time.Date(2024, 1, 8, 19, 0, 0, 0, time.UTC)
+	movedEnd := movedStart.Add(3 * time.Hour)
+
+	overrides := []models.EventOccurrenceOverride{
+		// Jan 8 moves one hour later and runs for three hours
+		{OccurrenceStart: time.Date(2024, 1, 8, 18, 0, 0, 0, time.UTC), StartTime: &movedStart, EndTime: &movedEnd},
+		// Jan 15 is cancelled
+		{OccurrenceStart: time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC), Cancelled: true},
+		// From Jan 22 on, the series moves to a new venue
+		{OccurrenceStart: time.Date(2024, 1, 22, 18, 0, 0, 0, time.UTC), ThisAndFuture: true, Location: "Hall B"},
+	}
+
+	all, err := ExpandOccurrences(event, overrides, time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"01-01 18:00", "01-08 19:00", "01-22 18:00", "01-29 18:00"}, occurrenceStarts(all))
+	assert.Equal(t, movedEnd, all[1].EndTime)
+	assert.Equal(t, "Hall A", all[1].Location)
+	assert.Equal(t, "Hall B", all[2].Location)
+	assert.Equal(t, "Hall B", all[3].Location)
+
+	upcoming, err := ExpandOccurrences(event, overrides, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"01-22 18:00"}, occurrenceStarts(upcoming))
+}
+
+func TestExpandOccurrencesShiftsFutureTimes(t *testing.T) {
+	event := weeklyEvent()
+	newStart := time.Date(2024, 1, 16, 17, 30, 0, 0, time.UTC)
+	newEnd := newStart.Add(time.Hour)
+
+	overrides := []models.EventOccurrenceOverride{
+		{OccurrenceStart: time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC), ThisAndFuture: true, StartTime: &newStart, EndTime: &newEnd},
+	}
+
+	all, err := ExpandOccurrences(event, overrides, time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"01-01 18:00", "01-08 18:00", "01-16 17:30", "01-23 17:30", "01-30 17:30"}, occurrenceStarts(all))
+	assert.Equal(t, time.Date(2024, 1, 30, 18, 30, 0, 0, time.UTC), all[4].EndTime)
+}
+
+func TestFindOccurrence(t *testing.T) {
+	event := weeklyEvent()
+
+	occ, err := FindOccurrence(event, nil, time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1705341600), occ.Key())
+
+	_, err = FindOccurrence(event, nil, time.Date(2024, 1, 16, 18, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrOccurrenceNotFound)
+
+	_, err = FindOccurrence(&models.Event{}, nil, time.Now())
+	assert.ErrorIs(t, err, ErrNotRecurring)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// GrantRole gives the user registered with email the named role. It
+// reports false when the user already had the role.
+func GrantRole(email, roleName string) (*models.User, bool, error) {
+	var user models.User
+	err := config.DB.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, ErrUserNotFound
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	granted, err := grantRole(config.DB, user.ID, roleName)
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, granted, nil
+}
+
+// grantRole adds a user_roles row unless it already exists
+func grantRole(tx *gorm.DB, userID uuid.UUID, roleName string) (bool, error) {
+	var role models.Role
+	err := tx.Where("name = ?", roleName).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("role %q does not exist", roleName)
+	} else if err != nil {
+		return false, err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserRole{UserID: userID, RoleID: role.ID})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRSVPStatus = errors.New("invalid RSVP status")
+	ErrRSVPClosed        = errors.New("RSVPs are only open for published events")
+)
+
+// RSVPCounts is the number of attendees per RSVP status for an event
+type RSVPCounts struct {
+	Going      int64
+	Interested int64
+	NotGoing   int64
+	Waitlisted int64
+}
+
+// RSVPResult describes the outcome of an RSVP change
+type RSVPResult struct {
+	Status   string            // Status actually stored for the user
+	Promoted []models.Attendee // Waitlisted attendees moved to going as a result
+}
+
+// PromotionHook runs in the transaction that moves attendees off an
+// event's waitlist, so their notifications are committed with the change
+type PromotionHook func(tx *gorm.DB, event *models.Event, promoted []models.Attendee) error
+
+// IsValidRSVPStatus reports whether status is one of the values a user may request
+func IsValidRSVPStatus(status string) bool {
+	switch status {
+	case models.RSVPGoing, models.RSVPInterested, models.RSVPNotGoing:
+		return true
+	}
+	return false
+}
+
+// resolveRSVPStatus decides the status to store for a requested RSVP given
+// the user's current status and how many seats are already taken.
+func resolveRSVPStatus(requested, current string, going int64, capacity *int) string {
+	if requested != models.RSVPGoing {
+		return requested
+	}
+	if current == models.RSVPGoing {
+		return models.RSVPGoing
+	}
+	if capacity != nil && going >= int64(*capacity) {
+		return models.RSVPWaitlisted
+	}
+	return models.RSVPGoing
+}
+
+// lockEvent reloads the event with a row lock so RSVP changes for the same
+// event are serialised across concurrent requests and replicas.
+func lockEvent(tx *gorm.DB, eventID uuid.UUID) (*models.Event, error) {
+	var event models.Event
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, "id = ?", eventID).Error
+	return &event, err
+}
+
+// promoteWaitlist moves waitlisted attendees to going, oldest first, until
+// the event is full, then calls notify when it is not nil. It must run
+// inside a transaction holding the event lock.
+func promoteWaitlist(tx *gorm.DB, event *models.Event, notify PromotionHook) ([]models.Attendee, error) {
+	query := tx.Where("event_id = ? AND status = ?", event.ID, models.RSVPWaitlisted).
+		Order("waitlisted_at, id")
+
+	if event.Capacity != nil {
+		var going int64
+		if err := tx.Model(&models.Attendee{}).
+			Where("event_id = ? AND status = ?", event.ID, models.RSVPGoing).
+			Count(&going).Error; err != nil {
+			return nil, err
+		}
+		free := int64(*event.Capacity) - going
+		if free <= 0 {
+			return nil, nil
+		}
+		query = query.Limit(int(free))
+	}
+
+	var promoted []models.Attendee
+	if err := query.Find(&promoted).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range promoted {
+		promoted[i].Status = models.RSVPGoing
+		promoted[i].WaitlistedAt = nil
+		if err := tx.Model(&promoted[i]).Updates(map[string]interface{}{
+			"status":        models.RSVPGoing,
+			"waitlisted_at": nil,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if notify != nil && len(promoted) > 0 {
+		if err := notify(tx, event, promoted); err != nil {
+			return nil, err
+		}
+	}
+	return promoted, nil
+}
+
+// SetRSVP creates or updates a user's RSVP for an event. Requests to attend a
+// full event are placed on the waitlist, and seats released by the change
+// are handed to the next waitlisted attendees, who are passed to notify.
+func SetRSVP(eventID, userID uuid.UUID, status string, notify PromotionHook) (*RSVPResult, error) {
+	if !IsValidRSVPStatus(status) {
+		return nil, ErrInvalidRSVPStatus
+	}
+
+	result := &RSVPResult{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+		if event.Status != "published" {
+			return ErrRSVPClosed
+		}
+
+		var attendee models.Attendee
+		err = tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&attendee).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		current := attendee.Status
+
+		var going int64
+		if err := tx.Model(&models.Attendee{}).
+			Where("event_id = ? AND status = ?", eventID, models.RSVPGoing).
+			Count(&going).Error; err != nil {
+			return err
+		}
+
+		result.Status = resolveRSVPStatus(status, current, going, event.Capacity)
+
+		attendee.EventID = eventID
+		attendee.UserID = userID
+		attendee.Status = result.Status
+		if result.Status == models.RSVPWaitlisted {
+			if attendee.WaitlistedAt == nil {
+				now := time.Now()
+				attendee.WaitlistedAt = &now
+			}
+		} else {
+			attendee.WaitlistedAt = nil
+		}
+		if err := tx.Save(&attendee).Error; err != nil {
+			return err
+		}
+
+		if current == models.RSVPGoing && result.Status != models.RSVPGoing {
+			result.Promoted, err = promoteWaitlist(tx, event, notify)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelRSVP removes a user's RSVP for an event and promotes the next
+// waitlisted attendee, passed to notify, when a seat is released.
+func CancelRSVP(eventID, userID uuid.UUID, notify PromotionHook) ([]models.Attendee, error) {
+	var promoted []models.Attendee
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+
+		var attendee models.Attendee
+		err = tx.Where("event_id = ? AND user_id = ?", eventID, userID).First(&attendee).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&attendee).Error; err != nil {
+			return err
+		}
+
+		if attendee.Status == models.RSVPGoing {
+			promoted, err = promoteWaitlist(tx, event, notify)
+		}
+		return err
+	})
+	return promoted, err
+}
+
+// FillFreedSeats promotes waitlisted attendees after an event's capacity
+// has been raised or removed, passing them to notify.
+func FillFreedSeats(eventID uuid.UUID, notify PromotionHook) ([]models.Attendee, error) {
+	var promoted []models.Attendee
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+		promoted, err = promoteWaitlist(tx, event, notify)
+		return err
+	})
+	return promoted, err
+}
+
+// WaitlistPosition returns the 1-based position of a user on an event's
+// waitlist, or 0 if the user is not waitlisted.
+func WaitlistPosition(eventID, userID uuid.UUID) (int64, error) {
+	var attendee models.Attendee
+	err := config.DB.Where("event_id = ? AND user_id = ? AND status = ?", eventID, userID, models.RSVPWaitlisted).
+		First(&attendee).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var ahead int64
+	err = config.DB.Model(&models.Attendee{}).
+		Where("event_id = ? AND status = ? AND (waitlisted_at < ? OR (waitlisted_at = ? AND id < ?))",
+			eventID, models.RSVPWaitlisted, attendee.WaitlistedAt, attendee.WaitlistedAt, attendee.ID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// GetUserRSVP returns the user's RSVP status for an event, or "" if none
+func GetUserRSVP(eventID, userID uuid.UUID) (string, error) {
+	var attendee models.Attendee
+	err := config.DB.Where("event_id = ? AND user_id = ?", eventID, userID).First(&attendee).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return attendee.Status, nil
+}
+
+// GetRSVPCounts returns RSVP counts keyed by event ID
+func GetRSVPCounts(eventIDs []uuid.UUID) (map[uuid.UUID]RSVPCounts, error) {
+	counts := make(map[uuid.UUID]RSVPCounts, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		EventID uuid.UUID
+		Status  string
+		Count   int64
+	}
+	err := config.DB.Model(&models.Attendee{}).
+		Select("event_id, status, COUNT(*) AS count").
+		Where("event_id IN ?", eventIDs).
+		Group("event_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		c := counts[row.EventID]
+		switch row.Status {
+		case models.RSVPGoing:
+			c.Going = row.Count
+		case models.RSVPInterested:
+			c.Interested = row.Count
+		case models.RSVPNotGoing:
+			c.NotGoing = row.Count
+		case models.RSVPWaitlisted:
+			c.Waitlisted = row.Count
+		}
+		counts[row.EventID] = c
+	}
+	return counts, nil
+}
+
+// ApplyRSVPCounts fills the virtual RSVP count fields of each event
+func ApplyRSVPCounts(events []models.Event) error {
+	ids := make([]uuid.UUID, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	counts, err := GetRSVPCounts(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].Going = counts[events[i].ID].Going
+		events[i].Interested = counts[events[i].ID].Interested
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidRSVPStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected bool
+	}{
+		{status: "going", expected: true},
+		{status: "interested", expected: true},
+		{status: "not_going", expected: true},
+		{status: "maybe", expected: false},
+		{status: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsValidRSVPStatus(tt.status))
+		})
+	}
+}
+
+func TestResolveRSVPStatus(t *testing.T) {
+	capacity := 2
+
+	tests := []struct {
+		name      string
+		requested string
+		current   string
+		going     int64
+		capacity  *int
+		expected  string
+	}{
+		{name: "unlimited capacity", requested: "going", going: 100, expected: "going"},
+		{name: "seat available", requested: "going", going: 1, capacity: &capacity, expected: "going"},
+		{name: "event full", requested: "going", going: 2, capacity: &capacity, expected: "waitlisted"},
+		{name: "already going keeps seat", requested: "going", current: "going", going: 2, capacity: &capacity, expected: "going"},
+		{name: "waitlisted stays waitlisted", requested: "going", current: "waitlisted", going: 2, capacity: &capacity, expected: "waitlisted"},
+		{name: "interested ignores capacity", requested: "interested", going: 2, capacity: &capacity, expected: "interested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resolveRSVPStatus(tt.requested, tt.current, tt.going, tt.capacity))
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+
+	"gorm.io/gorm"
+)
+
+// DemoUser is a seeded account
+type DemoUser struct {
+	Username  string
+	Email     string
+	FirstName string
+	Roles     []string
+}
+
+// DemoUsers are created by SeedDemo
+var DemoUsers = []DemoUser{
+	{Username: "demo_admin", Email: "admin@example.com", FirstName: "Ada", Roles: []string{models.RoleUser, models.RoleAdmin}},
+	{Username: "demo_alice", Email: "alice@example.com", FirstName: "Alice", Roles: []string{models.RoleUser}},
+	{Username: "demo_bob", Email: "bob@example.com", FirstName: "Bob", Roles: []string{models.RoleUser}},
+}
+
+// SeedResult reports what SeedDemo created. Password signs in every user
+// in Created; it is generated for each run and not stored anywhere else.
+type SeedResult struct {
+	Created  []DemoUser
+	Password string
+	Events   int
+}
+
+// ErrDemoUserConflict is returned when the username or email of a demo
+// user belongs to another account
+var ErrDemoUserConflict = errors.New("demo user conflicts with an existing account")
+
+// demoEvent is a seeded event owned by DemoUsers[owner]
+type demoEvent struct {
+	owner      int
+	event      models.Event
+	recurrence string
+}
+
+// demoEvents returns the seeded events, scheduled relative to now
+func demoEvents(now time.Time) []demoEvent {
+	start := now.Truncate(time.Hour)
+	day := 24 * time.Hour
+	publishAt := start.Add(2 * day)
+	capacity := 25
+
+	return []demoEvent{
+		{owner: 1, event: models.Event{
+			Title:       "Go Meetup: Concurrency Patterns",
+			Description: "Talks and live coding on channels, worker pools and context cancellation.",
+			StartTime:   start.Add(7 * day),
+			EndTime:     start.Add(7*day + 2*time.Hour),
+			Location:    "Community Hall, Room 2",
+			Status:      "published",
+			Capacity:    &capacity,
+		}},
+		{owner: 1, event: models.Event{
+			Title:       "Weekly Study Group",
+			Description: "An open hour to work through exercises together.",
+			StartTime:   start.Add(3 * day),
+			EndTime:     start.Add(3*day + time.Hour),
+			Location:    "Library, Second Floor",
+			Status:      "published",
+		}, recurrence: "FREQ=WEEKLY;COUNT=8"},
+		{owner: 2, event: models.Event{
+			Title:         "Product Launch Preview",
+			Description:   "A first look at the spring release, published two days ahead.",
+			StartTime:     start.Add(14 * day),
+			EndTime:       start.Add(14*day + 90*time.Minute),
+			Location:      "Online",
+			Status:        "draft",
+			PublishedDate: &publishAt,
+		}},
+		{owner: 2, event: models.Event{
+			Title:       "Winter Hack Night",
+			Description: "Past event kept for the analytics pages.",
+			StartTime:   start.Add(-30 * day),
+			EndTime:     start.Add(-30*day + 4*time.Hour),
+			Location:    "Makerspace",
+			Status:      "expired",
+		}},
+	}
+}
+
+// SeedDemo creates the demo users, with a random password, and their
+// events. Users with both the username and the email of a demo user, and
+// events with the same title, are left alone, so running it again only
+// fills in what is missing. It fails without changes if another account
+// holds only the username or only the email of a demo user.
+func SeedDemo(now time.Time) (*SeedResult, error) {
+	password, err := newDemoPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SeedResult{Password: password}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		users := make([]models.User, len(DemoUsers))
+		for i, demo := range DemoUsers {
+			created, err := seedUser(tx, demo, password, &users[i])
+			if err != nil {
+				return err
+			}
+			if created {
+				result.Created = append(result.Created, demo)
+			}
+		}
+
+		for _, seed := range demoEvents(now) {
+			var count int64
+			if err := tx.Model(&models.Event{}).Unscoped().Where("title = ?", seed.event.Title).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+
+			event := seed.event
+			event.CreatedBy = users[seed.owner].ID
+			if event.Status == "published" {
+				publishedAt := now
+				event.PublishedDate = &publishedAt
+			}
+			if err := ApplyRecurrence(&event, seed.recurrence); err != nil {
+				return err
+			}
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+			result.Events++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// seedUser loads a demo user or registers it with its roles. Roles are
+// only granted to users it creates, an existing account keeps its own.
+func seedUser(tx *gorm.DB, demo DemoUser, password string, user *models.User) (bool, error) {
+	var existing []models.User
+	if err := tx.Where("username = ? OR email = ?", demo.Username, demo.Email).Find(&existing).Error; err != nil {
+		return false, err
+	}
+	switch {
+	case len(existing) == 1 && existing[0].Username == demo.Username && existing[0].Email == demo.Email:
+		*user = existing[0]
+		return false, nil
+	case len(existing) > 0:
+		return false, fmt.Errorf("%w: %s / %s", ErrDemoUserConflict, demo.Username, demo.Email)
+	}
+
+	*user = models.User{
+		Username:   demo.Username,
+		Email:      demo.Email,
+		FirstName:  demo.FirstName,
+		LastName:   "Demo",
+		Password:   password,
+		IsVerified: true,
+	}
+	if err := user.HashPassword(); err != nil {
+		return false, err
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error; err != nil {
+		return false, err
+	}
+	for _, role := range demo.Roles {
+		if _, err := grantRole(tx, user.ID, role); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// newDemoPassword returns a random password of 16 URL-safe characters
+func newDemoPassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
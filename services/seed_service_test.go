@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemoEventsAreValid(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	titles := make(map[string]bool)
+
+	for _, seed := range demoEvents(now) {
+		t.Run(seed.event.Title, func(t *testing.T) {
+			assert.False(t, titles[seed.event.Title], "titles are unique")
+			titles[seed.event.Title] = true
+
+			assert.Less(t, seed.owner, len(DemoUsers))
+			assert.True(t, seed.event.EndTime.After(seed.event.StartTime))
+			assert.Contains(t, []string{"draft", "published", "expired"}, seed.event.Status)
+
+			event := seed.event
+			require.NoError(t, ApplyRecurrence(&event, seed.recurrence))
+			if seed.recurrence != "" {
+				assert.NotNil(t, event.SeriesEndsAt, "seeded series are bounded")
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/totp"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// TwoFactorIssuer names the app in authenticator apps
+	TwoFactorIssuer = "Event Analytics"
+
+	// RecoveryCodeCount is how many recovery codes a user gets at a time
+	RecoveryCodeCount = 10
+)
+
+// TwoFactorRoles are the roles admins can require two-factor
+// authentication for
+var TwoFactorRoles = []string{models.RoleAdmin, models.RoleModerator}
+
+var (
+	// ErrInvalidCode is returned for wrong, expired and replayed TOTP codes
+	// and for unknown and used recovery codes alike
+	ErrInvalidCode = errors.New("invalid two-factor code")
+
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+)
+
+// BeginTwoFactorEnrollment gives the user a new TOTP secret to add to an
+// authenticator app. It takes effect once ConfirmTwoFactor sees a code
+// made from it.
+func BeginTwoFactorEnrollment(userID uuid.UUID) (string, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	result := config.DB.Model(&models.User{}).
+		Where("id = ? AND totp_enabled_at IS NULL", userID).
+		Update("totp_secret", secret)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrTwoFactorEnabled
+	}
+	return secret, nil
+}
+
+// PendingTwoFactorSecret returns the secret the user is enrolling with, or
+// an empty string when they are not
+func PendingTwoFactorSecret(userID uuid.UUID) (string, error) {
+	var user models.User
+	if err := config.DB.Select("totp_secret", "totp_enabled_at").First(&user, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+	if user.TwoFactorEnabled() {
+		return "", nil
+	}
+	return user.TOTPSecret, nil
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once code matches
+// the secret being enrolled, and returns the first set of recovery codes
+func ConfirmTwoFactor(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled() {
+			return ErrTwoFactorEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTwoFactorNotEnabled
+		}
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled_at IS NULL AND totp_secret = ?", userID, user.TOTPSecret).
+			Updates(map[string]interface{}{"totp_enabled_at": time.Now(), "totp_last_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTwoFactorEnabled
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTwoFactor turns two-factor authentication off, forgetting the
+// secret and the recovery codes
+func DisableTwoFactor(userID uuid.UUID) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":     nil,
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// VerifyTwoFactor checks a TOTP code, or a recovery code and uses it up.
+// A TOTP code is accepted once: the step it belongs to must be later than
+// that of the last accepted code.
+func VerifyTwoFactor(userID uuid.UUID, code string) error {
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		result := config.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", userID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	result := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with a new
+// set and returns it. The plain codes are only available here.
+func RegenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("totp_enabled_at").First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return ErrTwoFactorNotEnabled
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RecoveryCodesLeft returns how many unused recovery codes the user has
+func RecoveryCodesLeft(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a new
+// set through tx
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))}
+	}
+	return codes, tx.Create(&rows).Error
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCode returns 50 random bits as two groups of five letters and
+// digits, e.g. "k3mzq-7bd2x"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := recoveryEncoding.EncodeToString(b)[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode drops the case, dashes and spaces users may type
+// differently
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// RolesRequiringTwoFactor returns the roles admins can require two-factor
+// authentication for, by name, and whether they do
+func RolesRequiringTwoFactor() (map[string]bool, error) {
+	var roles []models.Role
+	if err := config.DB.Where("name IN ?", TwoFactorRoles).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	required := make(map[string]bool, len(TwoFactorRoles))
+	for _, role := range roles {
+		required[role.Name] = role.RequiresTwoFactor
+	}
+	return required, nil
+}
+
+// SetRoleRequiresTwoFactor makes two-factor authentication mandatory for
+// the members of one of TwoFactorRoles, or optional again
+func SetRoleRequiresTwoFactor(name string, required bool) error {
+	known := false
+	for _, role := range TwoFactorRoles {
+		known = known || role == name
+	}
+	if !known {
+		return errors.New("two-factor authentication can't be required for role " + name)
+	}
+	result := config.DB.Model(&models.Role{}).Where("name = ?", name).Update("requires_two_factor", required)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("role " + name + " does not exist")
+	}
+	return nil
+}
@@ -1,3 +1,141 @@
 document.addEventListener("DOMContentLoaded", () => {
     console.log("Event Tracker Loaded!");
 });
+
+// Report custom interactions on elements marked with data-track inside an
+// element carrying data-event-id. sendBeacon keeps this off the critical path
+// and survives navigation away from the page.
+function trackInteraction(eventId, name, csrfToken) {
+    const data = new FormData();
+    data.append("name", name);
+    data.append("csrf_token", csrfToken || "");
+
+    const url = `/events/${eventId}/track`;
+    if (navigator.sendBeacon) {
+        navigator.sendBeacon(url, data);
+        return;
+    }
+    fetch(url, { method: "POST", body: data, keepalive: true, credentials: "same-origin" });
+}
+
+document.addEventListener("click", (e) => {
+    const target = e.target.closest("[data-track]");
+    if (!target) {
+        return;
+    }
+    const scope = target.closest("[data-event-id]");
+    if (!scope) {
+        return;
+    }
+    trackInteraction(scope.dataset.eventId, target.dataset.track, scope.dataset.csrfToken);
+});
+
+// Keep the dashboard cards current. The server only announces which event
+// changed; the card itself is fetched so drafts and edit buttons follow the
+// same rules as a page load.
+function liveDashboard(container) {
+    const cardId = (id) => `event-card-${id}`;
+
+    async function refreshCard(change, insert) {
+        const existing = document.getElementById(cardId(change.id));
+        if (!existing && !insert) {
+            return;
+        }
+
+        const response = await fetch(`/events/${change.id}/card`, { credentials: "same-origin" });
+        if (response.status === 404) {
+            existing?.remove();
+            return;
+        }
+        if (!response.ok) {
+            return;
+        }
+
+        const template = document.createElement("template");
+        template.innerHTML = (await response.text()).trim();
+        const card = template.content.firstElementChild;
+        if (!card) {
+            return;
+        }
+        // Look the card up again, another update may have inserted it meanwhile
+        const current = document.getElementById(cardId(change.id));
+        if (current) {
+            current.replaceWith(card);
+        } else {
+            container.prepend(card);
+        }
+    }
+
+    function handle(message) {
+        const change = message.data;
+        switch (message.type) {
+            case "event.deleted":
+                document.getElementById(cardId(change.id))?.remove();
+                break;
+            case "event.created":
+            case "event.published":
+                refreshCard(change, true);
+                break;
+            case "event.updated":
+            case "event.expired":
+                refreshCard(change, false);
+                break;
+        }
+    }
+
+    function receive(data) {
+        try {
+            handle(JSON.parse(data));
+        } catch (err) {
+            console.error("Live update failed", err);
+        }
+    }
+
+    // Server-Sent Events for networks whose proxies break WebSocket
+    // upgrades. The browser reconnects by itself and sends Last-Event-ID so
+    // the server replays what was missed; "reset" means it could not.
+    function stream() {
+        const source = new EventSource("/sse?topic=dashboard");
+        source.addEventListener("message", (e) => receive(e.data));
+        source.addEventListener("reset", () => location.reload());
+    }
+
+    let retry = 1000;
+    let failures = 0;
+    function connect() {
+        const scheme = location.protocol === "https:" ? "wss" : "ws";
+        const socket = new WebSocket(`${scheme}://${location.host}/ws`);
+        let opened = false;
+
+        socket.addEventListener("open", () => {
+            opened = true;
+            failures = 0;
+            retry = 1000;
+            socket.send(JSON.stringify({ action: "subscribe", topic: "dashboard" }));
+        });
+        socket.addEventListener("message", (e) => receive(e.data));
+        socket.addEventListener("close", () => {
+            // Give up on WebSockets after the upgrade failed twice in a row
+            if (!opened && ++failures >= 2 && "EventSource" in window) {
+                stream();
+                return;
+            }
+            // Back off up to 30 seconds, e.g. while the server restarts
+            setTimeout(connect, retry);
+            retry = Math.min(retry * 2, 30000);
+        });
+    }
+
+    if ("WebSocket" in window) {
+        connect();
+    } else if ("EventSource" in window) {
+        stream();
+    }
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+    const container = document.getElementById("eventContainer");
+    if (container) {
+        liveDashboard(container);
+    }
+});
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupAnalytics(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	event := CreateTestEvent(t, user.ID)
+
+	hour := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	records := []models.AnalyticsEvent{
+		{EventID: event.ID, VisitorID: "a", Action: models.ActionView, EventStatus: "published", CreatedAt: hour.Add(5 * time.Minute)},
+		{EventID: event.ID, VisitorID: "a", Action: models.ActionView, EventStatus: "published", CreatedAt: hour.Add(40 * time.Minute)},
+		{EventID: event.ID, VisitorID: "b", Action: models.ActionDetailOpen, EventStatus: "draft", CreatedAt: hour.Add(59 * time.Minute)},
+		{EventID: event.ID, VisitorID: "c", Action: models.ActionView, EventStatus: "published", CreatedAt: hour.Add(3 * time.Hour)},
+	}
+	require.NoError(t, testDB.Create(&records).Error)
+
+	from, to := hour, hour.Add(24*time.Hour)
+	for i := 0; i < 2; i++ {
+		rows, err := services.RollupAnalytics(services.GranularityHour, from, to)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), rows, "re-running replaces the rows")
+	}
+
+	buckets, err := services.EventRollup(event.ID, services.GranularityHour, from, hour.Add(4*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, buckets, 4)
+	assert.Equal(t, int64(2), buckets[0].Views)
+	assert.Equal(t, int64(1), buckets[0].DetailOpens)
+	assert.Equal(t, int64(2), buckets[0].UniqueVisitors)
+	assert.Equal(t, int64(0), buckets[1].Views)
+	assert.Equal(t, int64(1), buckets[3].Views)
+
+	rows, err := services.RollupAnalytics(services.GranularityDay, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows)
+	var daily models.AnalyticsDailyRollup
+	require.NoError(t, testDB.Where("event_id = ?", event.ID).First(&daily).Error)
+	assert.True(t, daily.BucketStart.Equal(hour.Truncate(24*time.Hour)))
+	assert.Equal(t, int64(3), daily.Views)
+	assert.Equal(t, int64(1), daily.PrePublish)
+	assert.Equal(t, int64(3), daily.PostPublish)
+}
+
+func TestRollupAnalyticsOutsideUTC(t *testing.T) {
+	ClearTestData(testDB)
+	local := time.Local
+	time.Local = time.FixedZone("JST", 9*60*60)
+	t.Cleanup(func() {
+		time.Local = local
+		ClearTestData(testDB)
+	})
+	user := CreateTestUser(t)
+	event := CreateTestEvent(t, user.ID)
+
+	// The first record falls on April 30th in UTC but May 1st in Tokyo
+	hour := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	records := []models.AnalyticsEvent{
+		{EventID: event.ID, VisitorID: "a", Action: models.ActionView, EventStatus: "published", CreatedAt: hour.Add(-14 * time.Hour)},
+		{EventID: event.ID, VisitorID: "b", Action: models.ActionView, EventStatus: "published", CreatedAt: hour},
+	}
+	require.NoError(t, testDB.Create(&records).Error)
+
+	from := hour.In(time.Local)
+	for i := 0; i < 2; i++ {
+		_, err := services.RollupAnalytics(services.GranularityDay, from, from.Add(24*time.Hour))
+		require.NoError(t, err, "run %d", i+1)
+		_, err = services.RollupAnalytics(services.GranularityHour, from, from.Add(24*time.Hour))
+		require.NoError(t, err, "run %d", i+1)
+	}
+
+	var daily []models.AnalyticsDailyRollup
+	require.NoError(t, testDB.Where("event_id = ?", event.ID).Find(&daily).Error)
+	require.Len(t, daily, 1)
+	assert.True(t, daily[0].BucketStart.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)), "buckets are UTC days")
+	assert.Equal(t, int64(1), daily[0].Views)
+}
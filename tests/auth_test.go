@@ -4,6 +4,8 @@ import (
 	"context"
 	"event-analytics/config"
 	"event-analytics/models"
+	"event-analytics/pkg/mailer"
+	"event-analytics/pkg/session"
 	"io/ioutil"
 	// "log"
 	"net/http"
@@ -11,8 +13,10 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/gin-gonic/gin"
 )
@@ -182,8 +186,11 @@ func TestLogout(t *testing.T) {
 	}
 	assert.NotEmpty(t, sessionToken, "Session token should not be empty after login")
 
-	// Verify the session token exists in Redis
-	storedValue, err := config.RedisClient.Get(context.Background(), sessionToken).Result()
+	// Verify the session token exists in Redis. The cookie value is query
+	// escaped, the store is keyed by the token itself.
+	token, err := url.QueryUnescape(sessionToken)
+	assert.NoError(t, err)
+	storedValue, err := config.SessionStore.Get(context.Background(), token)
 	assert.NoError(t, err, "Session token should exist in Redis")
 	assert.Equal(t, storedValue, user.ID.String(), "Stored user ID should match")
 
@@ -196,8 +203,8 @@ func TestLogout(t *testing.T) {
     assert.Equal(t, http.StatusFound, logoutRes.Code, "Logout should redirect to login page")
 
     // Verify the session token is deleted from Redis
-    _, err = config.RedisClient.Get(context.Background(), sessionToken).Result()
-    assert.Error(t, err, "Session token should be deleted from Redis")
+    _, err = config.SessionStore.Get(context.Background(), token)
+    assert.ErrorIs(t, err, session.ErrNotFound, "Session token should be deleted from Redis")
 
     // Verify the session token cookie is cleared
     logoutCookies := logoutRes.Result().Cookies()
@@ -212,4 +219,58 @@ func TestLogout(t *testing.T) {
     
     // Check if redirected to login page
     assert.Equal(t, "/auth/login", logoutRes.Header().Get("Location"), "Should redirect to login page")
-}
\ No newline at end of file
+}
+// sentMail waits for the email sent in the background by a request
+func sentMail(t *testing.T, count int) []mailer.Message {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(Mailbox.Messages()) >= count }, time.Second, 5*time.Millisecond)
+	return Mailbox.Messages()
+}
+
+func TestRegistrationSendsVerificationEmail(t *testing.T) {
+	ClearTestData(testDB)
+	Mailbox.Reset()
+	r := SetupTestRouter()
+	require.NoError(t, testDB.Create(&models.Role{Name: models.RoleUser}).Error)
+
+	form := url.Values{
+		"username": {"mailuser"},
+		"email":    {"mailuser@example.com"},
+		"password": {"Password@1"},
+	}
+	req := httptest.NewRequest("POST", "/auth/register", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	sent := sentMail(t, 1)
+	require.Len(t, sent, 1)
+	assert.Equal(t, "mailuser@example.com", sent[0].To)
+	assert.Equal(t, "Verify Your Email", sent[0].Subject)
+	assert.Contains(t, sent[0].From, "Event Analytics")
+}
+
+func TestForgotPasswordSendsResetEmail(t *testing.T) {
+	ClearTestData(testDB)
+	Mailbox.Reset()
+	user := CreateTestUser(t)
+	r := SetupTestRouter()
+
+	send := func(email string) int {
+		form := url.Values{"email": {email}}
+		req := httptest.NewRequest("POST", "/auth/forgot-password", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send("nobody@example.com"))
+	require.Equal(t, http.StatusOK, send(user.Email))
+
+	sent := sentMail(t, 1)
+	require.Len(t, sent, 1, "unknown addresses get no email")
+	assert.Equal(t, user.Email, sent[0].To)
+	assert.Equal(t, "Reset your password", sent[0].Subject)
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var emailToken = regexp.MustCompile(`token=([^"&]+)`)
+
+// tokenFromMail returns the token of the link in the last email sent
+func tokenFromMail(t *testing.T, count int) string {
+	t.Helper()
+	sent := sentMail(t, count)
+	match := emailToken.FindStringSubmatch(sent[len(sent)-1].HTML)
+	require.NotNil(t, match, "no token link in:\n%s", sent[len(sent)-1].HTML)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func postForm(t *testing.T, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	SetupTestRouter().ServeHTTP(w, req)
+	return w
+}
+
+func get(t *testing.T, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	SetupTestRouter().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestVerificationTokenIsHashedAndSingleUse(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	require.NoError(t, testDB.Create(&models.Role{Name: models.RoleUser}).Error)
+
+	w := postForm(t, "/auth/register", url.Values{"username": {"verifyme"}, "email": {"verifyme@example.com"}, "password": {"Password@1"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	token := tokenFromMail(t, 1)
+
+	var stored models.VerificationToken
+	require.NoError(t, testDB.First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash, "only the hash is stored")
+	assert.Len(t, stored.TokenHash, 64)
+	assert.WithinDuration(t, time.Now().Add(services.VerificationTokenTTL), stored.ExpiresAt, time.Minute)
+
+	w = get(t, "/auth/verify?token=unknown")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "unknown tokens are rejected")
+
+	w = get(t, "/auth/verify?token="+url.QueryEscape(token))
+	require.Equal(t, http.StatusOK, w.Code)
+	var user models.User
+	require.NoError(t, testDB.Where("username = ?", "verifyme").First(&user).Error)
+	assert.True(t, user.IsVerified)
+
+	w = get(t, "/auth/verify?token="+url.QueryEscape(token))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "a token works once")
+}
+
+func TestExpiredVerificationTokenIsRejected(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+
+	token, err := services.CreateVerificationToken(testDB, user.ID)
+	require.NoError(t, err)
+	require.NoError(t, testDB.Model(&models.VerificationToken{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	w := get(t, "/auth/verify?token="+url.QueryEscape(token))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, testDB.First(user, "id = ?", user.ID).Error)
+	assert.False(t, user.IsVerified)
+}
+
+func TestResendVerification(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	user := CreateTestUser(t)
+
+	w := postForm(t, "/auth/resend-verification", url.Values{"email": {user.Email}})
+	require.Equal(t, http.StatusOK, w.Code)
+	sent := sentMail(t, 1)
+	assert.Equal(t, "Verify Your Email", sent[0].Subject)
+	token := tokenFromMail(t, 1)
+
+	// The body of a sent message is not kept
+	require.Eventually(t, func() bool {
+		var message models.OutboxMessage
+		return testDB.First(&message).Error == nil && message.Status == models.OutboxSent && message.Body == ""
+	}, time.Second, 5*time.Millisecond)
+
+	w = get(t, "/auth/verify?token="+url.QueryEscape(token))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, testDB.First(user, "id = ?", user.ID).Error)
+	assert.True(t, user.IsVerified)
+
+	// Verified accounts and unknown addresses get the same answer, and no email
+	for _, email := range []string{user.Email, "nobody@example.com"} {
+		w = postForm(t, "/auth/resend-verification", url.Values{"email": {email}})
+		assert.Equal(t, http.StatusOK, w.Code, email)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, Mailbox.Messages(), 1)
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	user := CreateTestUser(t)
+
+	for i := 0; i < 3; i++ {
+		w := postForm(t, "/auth/resend-verification", url.Values{"email": {user.Email}})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	sentMail(t, 3)
+
+	w := postForm(t, "/auth/resend-verification", url.Values{"email": {user.Email}})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, Mailbox.Messages(), 3)
+
+	var tokens int64
+	require.NoError(t, testDB.Model(&models.VerificationToken{}).Where("user_id = ?", user.ID).Count(&tokens).Error)
+	assert.EqualValues(t, 3, tokens)
+}
+
+func TestPasswordResetTokenIsSingleUseAndExpires(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	user := CreateTestUser(t)
+	require.NoError(t, testDB.Create(&models.PasswordHistory{UserID: user.ID, Password: user.Password}).Error)
+
+	w := postForm(t, "/auth/forgot-password", url.Values{"email": {user.Email}})
+	require.Equal(t, http.StatusOK, w.Code)
+	token := tokenFromMail(t, 1)
+
+	w = get(t, "/auth/reset-password?token="+url.QueryEscape(token))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	reset := url.Values{"token": {token}, "password": {"NewPassword@1"}, "confirm_password": {"NewPassword@1"}}
+	w = postForm(t, "/auth/reset-password", reset)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/auth/login?success=password_reset", w.Header().Get("Location"))
+
+	w = postForm(t, "/auth/reset-password", reset)
+	assert.Equal(t, "/auth/login?error=invalid_reset_token", w.Header().Get("Location"), "a token works once")
+	w = get(t, "/auth/reset-password?token="+url.QueryEscape(token))
+	assert.Equal(t, "/auth/login?error=invalid_token", w.Header().Get("Location"))
+
+	// An expired token no longer opens the page or resets the password
+	expired, err := services.CreatePasswordReset(testDB, user.Email)
+	require.NoError(t, err)
+	require.NoError(t, testDB.Model(&models.PasswordReset{}).Where("used_at IS NULL").
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	w = get(t, "/auth/reset-password?token="+url.QueryEscape(expired))
+	assert.Equal(t, "/auth/login?error=invalid_token", w.Header().Get("Location"))
+	reset.Set("token", expired)
+	reset.Set("password", "OtherPassword@1")
+	reset.Set("confirm_password", "OtherPassword@1")
+	w = postForm(t, "/auth/reset-password", reset)
+	assert.Equal(t, "/auth/login?error=invalid_reset_token", w.Header().Get("Location"))
+}
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+
+	_, err := services.CreateVerificationToken(testDB, user.ID)
+	require.NoError(t, err)
+	used, err := services.CreatePasswordReset(testDB, user.Email)
+	require.NoError(t, err)
+	_, err = services.UsePasswordReset(testDB, used)
+	require.NoError(t, err)
+	_, err = services.CreatePasswordReset(testDB, user.Email)
+	require.NoError(t, err)
+	require.NoError(t, testDB.Create(&models.PasswordReset{
+		Email: user.Email, TokenHash: strings.Repeat("0", 64), ExpiresAt: time.Now().Add(-time.Second),
+	}).Error)
+
+	purged, err := services.PurgeExpiredTokens()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged, "the used and the expired reset go")
+
+	var verifications, resets int64
+	require.NoError(t, testDB.Model(&models.VerificationToken{}).Count(&verifications).Error)
+	require.NoError(t, testDB.Model(&models.PasswordReset{}).Count(&resets).Error)
+	assert.Equal(t, int64(1), verifications)
+	assert.Equal(t, int64(1), resets)
+}
@@ -1,13 +1,10 @@
 package tests
 
 import (
-	"context"
-	"event-analytics/config"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,9 +40,7 @@ func TestShowEventDetails_EventNotFound(t *testing.T) {
 	r := SetupTestRouter()
 	
 	// Create session
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/999", nil)
 	req.AddCookie(&http.Cookie{
@@ -70,9 +65,7 @@ func TestShowEventDetails_Success(t *testing.T) {
 	r := SetupTestRouter()
 	
 	// Create session
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/"+event.ID.String(), nil)
 	req.AddCookie(&http.Cookie{
@@ -107,9 +100,7 @@ func TestShowCreateEventPage_Success(t *testing.T) {
 	r := SetupTestRouter()
 	
 	// Create session
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/new", nil)
 	req.AddCookie(&http.Cookie{
@@ -142,9 +133,7 @@ func TestShowEditEventPage_EventNotFound(t *testing.T) {
 	r := SetupTestRouter()
 	
 	// Create session
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/edit/999", nil)
 	req.AddCookie(&http.Cookie{
@@ -170,9 +159,7 @@ func TestShowEditEventPage_UnauthorizedUser(t *testing.T) {
 	event := CreateTestEvent(t, owner.ID)
 	
 	// Create session for unauthorized user
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, unauthorizedUser.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, unauthorizedUser)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/edit/"+event.ID.String(), nil)
 	req.AddCookie(&http.Cookie{
@@ -195,9 +182,7 @@ func TestShowEditEventPage_Success(t *testing.T) {
 	r := SetupTestRouter()
 	
 	// Create session
-	sessionToken := uuid.New().String()
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 	
 	req := httptest.NewRequest(http.MethodGet, "/events/edit/"+event.ID.String(), nil)
 	req.AddCookie(&http.Cookie{
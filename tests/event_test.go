@@ -1,9 +1,7 @@
 package tests
 
 import (
-	"context"
 	"errors"
-	"event-analytics/config"
 	"event-analytics/models"
 	"time"
 
@@ -19,7 +17,6 @@ import (
 	"testing"
 
 	// "github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -47,11 +44,7 @@ func TestCreateEvent_MissingFields(t *testing.T) {
 	r := SetupTestRouter()
 
 	// Create session token
-	sessionToken := uuid.New().String()
-
-	// Store session in Redis
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 
 	// Create the request
 	form := url.Values{}
@@ -85,11 +78,7 @@ func TestCreateEvent_InvalidDateFormat(t *testing.T) {
 	r := SetupTestRouter()
 
 	// Create session token
-	sessionToken := uuid.New().String()
-
-	// Store session in Redis
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 
 	form := url.Values{
 		"title":        {"Test Event"},
@@ -124,11 +113,7 @@ func TestCreateEvent_NameUniqueness(t *testing.T) {
 	r := SetupTestRouter()
 
 	// Create session token
-	sessionToken := uuid.New().String()
-
-	// Store session in Redis
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 
 	// Create existing event
 	existingEvent := &models.Event{
@@ -163,7 +148,7 @@ func TestCreateEvent_NameUniqueness(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusFound, w.Code)
-	assert.Contains(t, w.Header().Get("Location"), "/events/new?error=Event title must be unique")
+	assert.Contains(t, w.Header().Get("Location"), "/events/new?error=Event+title+must+be+unique")
 }
 
 func TestCreateEvent_ValidEvent(t *testing.T) {
@@ -176,11 +161,7 @@ func TestCreateEvent_ValidEvent(t *testing.T) {
 	r := SetupTestRouter()
 
 	// Create session token
-	sessionToken := uuid.New().String()
-
-	// Store session in Redis
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 
 	startTime := time.Now().Add(time.Hour * 24) // Tomorrow
 	endTime := startTime.Add(time.Hour * 2)     // 2 hours later
@@ -225,11 +206,7 @@ func TestCreateEvent_Success(t *testing.T) {
 	r := SetupTestRouter()
 
 	// Create session token
-	sessionToken := uuid.New().String()
-
-	// Store session in Redis
-	err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-	assert.NoError(t, err)
+	sessionToken := CreateTestSession(t, user)
 
 	// Create the request with valid event data
 	form := url.Values{
@@ -284,9 +261,7 @@ func TestUpdateEvent_NotFound(t *testing.T) {
     user := CreateTestUser(t)
     
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, user)
 
     form := url.Values{
         "title":       {"Updated Event"},
@@ -328,9 +303,7 @@ func TestUpdateEvent_UnauthorizedUser(t *testing.T) {
     assert.NoError(t, testDB.Create(event).Error)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, unauthorizedUser.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, unauthorizedUser)
 
     form := url.Values{
         "title":       {"Updated Event"},
@@ -384,9 +357,7 @@ func TestUpdateEvent_DuplicateTitle(t *testing.T) {
     assert.NoError(t, testDB.Create(event2).Error)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, user)
 
     // Try to update event2 with event1's title
     form := url.Values{
@@ -409,7 +380,36 @@ func TestUpdateEvent_DuplicateTitle(t *testing.T) {
     r.ServeHTTP(w, req)
 
     assert.Equal(t, http.StatusFound, w.Code)
-    assert.Contains(t, w.Header().Get("Location"), "/events/edit/"+event2.ID.String()+"?error=Event title must be unique")
+    assert.Contains(t, w.Header().Get("Location"), "/events/edit/"+event2.ID.String()+"?error=Event+title+must+be+unique")
+}
+
+func TestUpdateEvent_RecurrenceErrorIsEscaped(t *testing.T) {
+    ClearTestData(testDB)
+    t.Cleanup(func() { ClearTestData(testDB) })
+    r := SetupTestRouter()
+    user := CreateTestUser(t)
+    event := CreateTestEvent(t, user.ID)
+
+    form := url.Values{
+        "title":           {event.Title},
+        "description":     {event.Description},
+        "location":        {event.Location},
+        "start_time":      {"2025-01-10T10:00"},
+        "end_time":        {"2025-01-10T11:00"},
+        "status":          {"published"},
+        "recurrence_rule": {`FREQ=WEEKLY;COUNT=a&b#c`},
+    }
+    req := httptest.NewRequest("POST", "/events/update/"+event.ID.String(), strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.AddCookie(&http.Cookie{Name: "session_token", Value: CreateTestSession(t, user)})
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusFound, w.Code)
+    location, err := url.Parse(w.Header().Get("Location"))
+    assert.NoError(t, err)
+    assert.Equal(t, "/events/edit/"+event.ID.String(), location.Path)
+    assert.Equal(t, `Invalid recurrence rule: invalid count "a&b#c"`, location.Query().Get("error"))
 }
 
 func TestUpdateEvent_Success(t *testing.T) {
@@ -428,9 +428,7 @@ func TestUpdateEvent_Success(t *testing.T) {
     assert.NoError(t, testDB.Create(event).Error)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, user)
 
     form := url.Values{
         "title":       {"Updated Event"},
@@ -482,9 +480,7 @@ func TestDeleteEvent_NotFound(t *testing.T) {
     user := CreateTestUser(t)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, user)
 
     req := httptest.NewRequest("POST", "/events/delete/999", nil)
     req.AddCookie(&http.Cookie{
@@ -497,7 +493,7 @@ func TestDeleteEvent_NotFound(t *testing.T) {
 
     assert.Equal(t, http.StatusFound, w.Code)
 	// Event not found
-    assert.Contains(t, w.Header().Get("Location"), "/user/dashboard?error=Error fetching event")
+    assert.Contains(t, w.Header().Get("Location"), "/user/dashboard?error=Event not found")
 }
 
 func TestDeleteEvent_UnauthorizedUser(t *testing.T) {
@@ -517,9 +513,7 @@ func TestDeleteEvent_UnauthorizedUser(t *testing.T) {
     assert.NoError(t, testDB.Create(event).Error)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, unauthorizedUser.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, unauthorizedUser)
 
     req := httptest.NewRequest("POST", "/events/delete/"+event.ID.String(), nil)
     req.AddCookie(&http.Cookie{
@@ -550,9 +544,7 @@ func TestDeleteEvent_Success(t *testing.T) {
     assert.NoError(t, testDB.Create(event).Error)
 
     r := SetupTestRouter()
-    sessionToken := uuid.New().String()
-    err := config.RedisClient.Set(context.Background(), sessionToken, user.ID.String(), 0).Err()
-    assert.NoError(t, err)
+    sessionToken := CreateTestSession(t, user)
 
     req := httptest.NewRequest("POST", "/events/delete/"+event.ID.String(), nil)
     req.AddCookie(&http.Cookie{
@@ -571,4 +563,45 @@ func TestDeleteEvent_Success(t *testing.T) {
     result := testDB.First(&deletedEvent, "id = ?", event.ID)
     assert.Error(t, result.Error)
     assert.True(t, errors.Is(result.Error, gorm.ErrRecordNotFound))
-}
\ No newline at end of file
+}
+func TestCreateEvent_TitleOfDeletedEvent(t *testing.T) {
+    ClearTestData(testDB)
+    user := CreateTestUser(t)
+
+    // Deleted events keep their row and their title in the unique index
+    event := &models.Event{
+        Title:       "Test Event",
+        Description: "Deleted event",
+        Location:    "Test Location",
+        StartTime:   time.Now(),
+        EndTime:     time.Now().Add(time.Hour * 2),
+        CreatedBy:   user.ID,
+        Status:      "published",
+    }
+    assert.NoError(t, testDB.Create(event).Error)
+    assert.NoError(t, testDB.Delete(event).Error)
+
+    r := SetupTestRouter()
+    sessionToken := CreateTestSession(t, user)
+
+    form := url.Values{
+        "title":       {"Test Event"},
+        "description": {"Test description"},
+        "location":    {"Test Location"},
+        "start_time":  {time.Now().Format("2006-01-02T15:04")},
+        "end_time":    {time.Now().Add(time.Hour * 2).Format("2006-01-02T15:04")},
+        "status":      {"published"},
+    }
+    req := httptest.NewRequest("POST", "/events/create", strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.AddCookie(&http.Cookie{
+        Name:  "session_token",
+        Value: sessionToken,
+    })
+    w := httptest.NewRecorder()
+
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusFound, w.Code)
+    assert.Contains(t, w.Header().Get("Location"), "/events/new?error=Event+title+must+be+unique", "the title check sees deleted events, as the unique index does")
+}
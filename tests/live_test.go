@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveStreamsAcceptAPITokens(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	reader, _, err := services.CreateAPIToken(user.ID, "dashboard", []string{models.ScopeEventsRead})
+	require.NoError(t, err)
+	writer, _, err := services.CreateAPIToken(user.ID, "tracker", []string{models.ScopeAnalyticsWrite})
+	require.NoError(t, err)
+
+	stream := func(token string) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest("GET", "/sse?topic=dashboard", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		SetupTestRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	w := stream(reader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+
+	w = stream(writer)
+	assert.Equal(t, http.StatusForbidden, w.Code, "the token lacks events:read")
+
+	w = stream("eat_unknown")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"event-analytics/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	migrator, err := config.NewMigrator()
+	require.NoError(t, err)
+	ctx := context.Background()
+	latest := migrator.Migrations()[len(migrator.Migrations())-1]
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, "%s is applied by the test setup", status.Migration)
+		assert.False(t, status.Modified)
+	}
+
+	// A dry run reports the migration but leaves it applied
+	migrator.DryRun = true
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, latest.Version, reverted[0].Version)
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, statuses[len(statuses)-1].Applied)
+
+	migrator.DryRun = false
+	reverted, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, statuses[len(statuses)-1].Applied)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, latest.Version, applied[0].Version)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "nothing left to apply")
+}
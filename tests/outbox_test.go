@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationQueuesEmailInTheSameTransaction(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	r := SetupTestRouter()
+
+	// Without the 'user' role the registration fails and takes the email with it
+	form := url.Values{"username": {"norole"}, "email": {"norole@example.com"}, "password": {"Password@1"}}
+	req := httptest.NewRequest("POST", "/auth/register", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var users, messages, tokens int64
+	require.NoError(t, testDB.Model(&models.User{}).Count(&users).Error)
+	require.NoError(t, testDB.Model(&models.OutboxMessage{}).Count(&messages).Error)
+	require.NoError(t, testDB.Table("verification_tokens").Count(&tokens).Error)
+	assert.Zero(t, users)
+	assert.Zero(t, messages)
+	assert.Zero(t, tokens)
+}
+
+func TestOutboxPage(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	r := SetupTestRouter()
+	user := CreateTestUser(t)
+	session := CreateTestSession(t, user)
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/admin/outbox?status=dead", nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: session})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/user/dashboard?error=Permission+denied", w.Header().Get("Location"))
+
+	admin := models.Role{Name: models.RoleAdmin}
+	require.NoError(t, testDB.Create(&admin).Error)
+	require.NoError(t, testDB.Create(&models.UserRole{UserID: user.ID, RoleID: admin.ID}).Error)
+
+	dead := models.OutboxMessage{
+		Recipient: "dead@example.com", Subject: "Verify Your Email", Body: "<p>Hi</p>",
+		Status: models.OutboxDead, Attempts: 8, NextAttemptAt: time.Now(), LastError: "550 mailbox unavailable",
+	}
+	require.NoError(t, testDB.Create(&dead).Error)
+
+	w = get()
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "dead@example.com")
+	assert.Contains(t, w.Body.String(), "550 mailbox unavailable")
+}
+
+func TestRequeueOutboxMessage(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	Mailbox.Reset()
+	r := SetupTestRouter()
+	user := CreateTestUser(t)
+	admin := models.Role{Name: models.RoleAdmin}
+	require.NoError(t, testDB.Create(&admin).Error)
+	require.NoError(t, testDB.Create(&models.UserRole{UserID: user.ID, RoleID: admin.ID}).Error)
+	session := CreateTestSession(t, user)
+
+	dead := models.OutboxMessage{
+		Recipient: "dead@example.com", Subject: "Verify Your Email", Body: "<p>Hi</p>",
+		Status: models.OutboxDead, Attempts: 8, NextAttemptAt: time.Now(),
+	}
+	require.NoError(t, testDB.Create(&dead).Error)
+
+	requeue := func(id uint) string {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/admin/outbox/%d/requeue", id), nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: session})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code)
+		return w.Header().Get("Location")
+	}
+
+	assert.Equal(t, "/admin/outbox", requeue(dead.ID))
+	sent := sentMail(t, 1)
+	assert.Equal(t, "dead@example.com", sent[0].To)
+	require.Eventually(t, func() bool {
+		var msg models.OutboxMessage
+		return testDB.First(&msg, dead.ID).Error == nil && msg.Status == models.OutboxSent
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Contains(t, requeue(dead.ID), "error=", "sent messages can't be requeued")
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentCancellationsPromoteOnePerSeat(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	owner := CreateTestUser(t)
+	event := CreateTestEvent(t, owner.ID)
+	capacity := 3
+	require.NoError(t, testDB.Model(event).Update("capacity", capacity).Error)
+
+	attendee := func(i int, status string, waitlistedAt *time.Time) uuid.UUID {
+		user := models.User{Username: fmt.Sprintf("attendee%d", i), Email: fmt.Sprintf("attendee%d@example.com", i), Password: "x"}
+		require.NoError(t, testDB.Create(&user).Error)
+		require.NoError(t, testDB.Create(&models.Attendee{EventID: event.ID, UserID: user.ID, Status: status, WaitlistedAt: waitlistedAt}).Error)
+		return user.ID
+	}
+	var going, waitlisted []uuid.UUID
+	for i := 0; i < capacity; i++ {
+		going = append(going, attendee(i, models.RSVPGoing, nil))
+	}
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		waitlisted = append(waitlisted, attendee(capacity+i, models.RSVPWaitlisted, &at))
+	}
+
+	// Every going attendee cancels twice at once, the second time finding
+	// nothing to cancel
+	var mu sync.Mutex
+	var promoted []uuid.UUID
+	var wg sync.WaitGroup
+	for _, userID := range append(going, going...) {
+		wg.Add(1)
+		go func(userID uuid.UUID) {
+			defer wg.Done()
+			moved, err := services.CancelRSVP(event.ID, userID, nil)
+			assert.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			for _, a := range moved {
+				promoted = append(promoted, a.UserID)
+			}
+		}(userID)
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, waitlisted[:capacity], promoted, "the oldest on the waitlist, each promoted once")
+	counts, err := services.GetRSVPCounts([]uuid.UUID{event.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(capacity), counts[event.ID].Going)
+	assert.Equal(t, int64(2), counts[event.ID].Waitlisted)
+}
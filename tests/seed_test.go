@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedRoles(t *testing.T) {
+	for _, name := range []string{models.RoleAdmin, models.RoleUser} {
+		require.NoError(t, config.DB.Create(&models.Role{Name: name}).Error)
+	}
+}
+
+func TestSeedDemoUsesRandomPassword(t *testing.T) {
+	ClearTestData(config.DB)
+	seedRoles(t)
+
+	first, err := services.SeedDemo(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, first.Created, len(services.DemoUsers))
+	assert.Len(t, first.Password, 16)
+
+	var admin models.User
+	require.NoError(t, config.DB.Where("email = ?", "admin@example.com").First(&admin).Error)
+	assert.NoError(t, admin.CheckPassword(first.Password))
+
+	// Running it again creates nothing and keeps the first password
+	second, err := services.SeedDemo(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, second.Created)
+	assert.Zero(t, second.Events)
+	assert.NotEqual(t, first.Password, second.Password)
+	require.NoError(t, config.DB.Where("email = ?", "admin@example.com").First(&admin).Error)
+	assert.NoError(t, admin.CheckPassword(first.Password))
+}
+
+func TestSeedDemoKeepsExistingAccounts(t *testing.T) {
+	ClearTestData(config.DB)
+	seedRoles(t)
+
+	// A pre-existing account with the demo admin's name and email is not
+	// made an admin
+	existing := &models.User{Username: "demo_admin", Email: "admin@example.com", Password: "password123"}
+	require.NoError(t, existing.HashPassword())
+	require.NoError(t, config.DB.Create(existing).Error)
+
+	result, err := services.SeedDemo(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, result.Created, len(services.DemoUsers)-1)
+
+	var roles int64
+	require.NoError(t, config.DB.Model(&models.UserRole{}).Where("user_id = ?", existing.ID).Count(&roles).Error)
+	assert.Zero(t, roles)
+}
+
+func TestSeedDemoRefusesConflictingAccounts(t *testing.T) {
+	ClearTestData(config.DB)
+	seedRoles(t)
+
+	// Someone else registered with the demo admin's email
+	other := &models.User{Username: "ada", Email: "admin@example.com", Password: "password123"}
+	require.NoError(t, other.HashPassword())
+	require.NoError(t, config.DB.Create(other).Error)
+
+	_, err := services.SeedDemo(time.Now())
+	assert.ErrorIs(t, err, services.ErrDemoUserConflict)
+
+	var users int64
+	require.NoError(t, config.DB.Model(&models.User{}).Count(&users).Error)
+	assert.Equal(t, int64(1), users, "nothing is seeded")
+	var roles int64
+	require.NoError(t, config.DB.Model(&models.UserRole{}).Where("user_id = ?", other.ID).Count(&roles).Error)
+	assert.Zero(t, roles)
+}
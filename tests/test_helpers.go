@@ -2,25 +2,33 @@ package tests
 
 import (
 	"context"
+	"database/sql"
 	"event-analytics/config"
-	"event-analytics/controllers"
-	"event-analytics/handler"
-	"event-analytics/middlewares"
+	"event-analytics/migrations"
 	"event-analytics/models"
+	"event-analytics/pkg/dialect"
+	"event-analytics/pkg/mailer"
+	"event-analytics/pkg/migrate"
+	"event-analytics/pkg/outbox"
+	"event-analytics/pkg/ratelimit"
+	"event-analytics/pkg/session"
+	"event-analytics/repository"
+	"event-analytics/router"
+	"event-analytics/utils"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
-	"text/template"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -30,108 +38,37 @@ var (
 
 var testDB *gorm.DB
 
-func TestingSuite(m *testing.M) {
-	// Load environment variables
-	err := godotenv.Load("../.env")
-	if err != nil {
-		log.Printf("Error loading .env file: %v", err)
-	}
-
-	// Get main database URL
-	mainDBURL := os.Getenv("DATABASE_URL")
-	if mainDBURL == "" {
-		log.Fatal("DATABASE_URL environment variable is not set")
-	}
-
-	// Create test database name
-	testDBName := "event_analytics_test"
-
-	// Connect to default postgres database to create/drop test database
-	defaultDB, err := gorm.Open(postgres.Open(mainDBURL), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to default database: %v", err)
-	}
-
-	sqlDB, err := defaultDB.DB()
-	if err != nil {
-		log.Fatalf("Failed to get underlying SQL DB: %v", err)
-	}
-
-	// Drop test database if it exists
-	sqlDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE);", testDBName))
-
-	// Create test database
-	_, err = sqlDB.Exec(fmt.Sprintf("CREATE DATABASE %s;", testDBName))
-	if err != nil {
-		log.Fatalf("Failed to create test database: %v", err)
-	}
-	if err != nil {
-		log.Fatalf("Failed to create test database: %v", err)
-	}
+// testDBName is the database created for the tests on a PostgreSQL server
+const testDBName = "event_analytics_test"
 
-	// Close connection to default database
-	sqlDB.Close()
+// dropTestDB removes what SetupTestDB created
+var dropTestDB = func() {}
 
-	// Create DSN for test database
-	testDBURL := getTestDatabaseURL(mainDBURL, testDBName)
+// redisServer stands in for Redis, so the tests need no server
+var redisServer *miniredis.Miniredis
 
-	// Connect to test database
-	testDB, err = gorm.Open(postgres.Open(testDBURL), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to test database: %v", err)
-	}
+// Mailbox captures the email the app sends during the tests
+var Mailbox = mailer.NewMemory()
 
-	// Run migrations
-	err = testDB.AutoMigrate(
-		&models.User{},
-		&models.Event{},
-		&models.VerificationToken{},
-		&models.PasswordReset{},
-		&models.Role{},
-		&models.UserRole{},
-		&models.PasswordHistory{},
-		&models.UserLog{},
-	)
-	if err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
-	}
-
-	// Replace the main DB with test DB
-	config.DB = testDB
-
-	// Run tests
-	code := m.Run()
+func TestingSuite(m *testing.M) {
+	os.Exit(TestMainHelper(m))
+}
 
-	// Cleanup: Close connection and drop test database
-	sqlDB, err = testDB.DB()
+// ClearTestData removes all rows from the test database, keeping the schema
+func ClearTestData(db *gorm.DB) {
+	tables, err := db.Migrator().GetTables()
 	if err != nil {
-		log.Printf("Warning: failed to get underlying SQL DB: %v", err)
-	} else {
-		sqlDB.Close()
+		log.Printf("Warning: failed to list tables: %v", err)
+		return
 	}
-
-	// Reconnect to default database to drop test database
-	defaultDB, err = gorm.Open(postgres.Open(mainDBURL), &gorm.Config{})
-	if err != nil {
-		log.Printf("Warning: failed to connect to default database for cleanup: %v", err)
-	} else {
-		defaultDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE);", testDBName))
+	for _, table := range tables {
+		if table == migrate.Table || strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+			log.Printf("Warning: failed to clear %s: %v", table, err)
+		}
 	}
-
-	ClearTestData(testDB)
-
-	if config.RedisClient != nil {
-        config.RedisClient.FlushAll(context.Background())
-        config.RedisClient.Close()
-    }
-
-	os.Exit(code)
-}
-
-// ClearTestData removes all data from the test database
-func ClearTestData(db *gorm.DB) {
-	// PostgreSQL specific cleanup
-	db.Exec("DO $$ DECLARE r RECORD; BEGIN FOR r IN (SELECT tablename FROM pg_tables WHERE schemaname = current_schema()) LOOP EXECUTE 'TRUNCATE TABLE ' || quote_ident(r.tablename) || ' CASCADE'; END LOOP; END $$;")
 }
 
 func getTestDatabaseURL(originalURL, testDBName string) string {
@@ -145,92 +82,11 @@ func getTestDatabaseURL(originalURL, testDBName string) string {
 	return db_name
 }
 
-func formatAsDate(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	year, month, day := t.Date()
-	return fmt.Sprintf("%d/%02d/%02d", year, month, day)
-}
-
-func formatDatetime(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("2006-01-02T15:04")
-}
-
-func formatForDisplay(t time.Time) string {
-	if t.IsZero() {
-		return ""
-	}
-	return t.Format("Jan 2, 2006 3:04 PM")
-}
-
 func SetupTestRouter() *gin.Engine {
-
-	config.RedisClient = redis.NewClient(&redis.Options{
-		// Addr: "localhost:6379", // Adjust to your Redis server
-		Addr: os.Getenv("REDIS_ADDR"),
-		Password: "",
-        DB:       0,
-	})
-	
 	gin.SetMode(gin.TestMode)
 
-	r := gin.Default()
-
-	// Important: First set the template functions
-	r.SetFuncMap(template.FuncMap{
-		"formatDate":     formatAsDate,      // for basic date formatting
-		"formatDatetime": formatDatetime,    // for datetime-local inputs
-		"formatDisplay":  formatForDisplay,  // for user-friendly display
-	})
-	
-	r.LoadHTMLGlob("../templates/*.html")
-
-	// Use middleware
-	r.Use(middlewares.UserMiddleware())
-	r.Use(middlewares.FlashMiddleware())
-
-	// Routes
-	r.GET("/", middlewares.PreventAuthenticatedAccess(), handler.ShowLoginPage)
-
-	userRoutes := r.Group("/auth")
-	userRoutes.Use(middlewares.PreventAuthenticatedAccess())
-	{
-		userRoutes.GET("/login", handler.ShowLoginPage)
-		userRoutes.POST("/login", controllers.Login)
-		userRoutes.GET("/register", handler.ShowRegistrationPage)
-		userRoutes.POST("/register", controllers.Register)
-		userRoutes.GET("/verify", controllers.Verify)
-		userRoutes.GET("/forgot-password", handler.ShowForgotPasswordPage)
-		userRoutes.POST("/forgot-password", controllers.ForgotPassword)
-		userRoutes.GET("/reset-password", handler.ShowResetPasswordPage)
-		userRoutes.POST("/reset-password", controllers.ResetPassword)
-	}
-
-	protected := r.Group("/user")
-	protected.Use(middlewares.AuthRequired())
-	{
-		protected.GET("/dashboard", handler.Dashboard)
-		protected.POST("/logout", controllers.Logout)
-		protected.GET("/profile", handler.ShowProfilePage)
-		protected.POST("/profile", controllers.EditProfile)
-		protected.GET("/change-password", handler.ShowChangePasswordPage)
-		protected.POST("/change-password", controllers.ChangePassword)
-	}
-
-	protected_event := r.Group("/events")
-	protected_event.Use(middlewares.AuthRequired())
-	{
-		protected_event.GET("/new", handler.ShowCreateEventPage)
-		protected_event.POST("/create", controllers.CreateEvent)
-		protected_event.GET("/:id", handler.ShowEventDetails)
-		protected_event.GET("/edit/:id", handler.ShowEditEventPage)
-		protected_event.POST("/update/:id", controllers.UpdateEvent)
-		protected_event.POST("/delete/:id", controllers.DeleteEvent)
-	}
+	r := router.New("../templates/*.html")
+	router.Register(r, repository.New(config.DB))
 	return r
 }
 
@@ -278,7 +134,16 @@ func CreateTestEvent(t *testing.T, userID uuid.UUID) *models.Event {
 	return event
 }
 
-// SetupTestDB initializes the test database
+// CreateTestSession signs user in and returns the session_token cookie value
+func CreateTestSession(t *testing.T, user *models.User) string {
+	token, err := config.SessionStore.Create(context.Background(), user.ID.String())
+	assert.NoError(t, err)
+	return token
+}
+
+// SetupTestDB opens the database named by TEST_DATABASE_URL. Without it the
+// tests run on an SQLite file in a temporary directory; on a PostgreSQL
+// server they get a fresh event_analytics_test database.
 func SetupTestDB() (*gorm.DB, error) {
 	// Load environment variables
 	err := godotenv.Load("../.env")
@@ -286,78 +151,95 @@ func SetupTestDB() (*gorm.DB, error) {
 		log.Printf("Error loading .env file: %v", err)
 	}
 
-	// Get main database URL
-	mainDBURL := os.Getenv("DATABASE_URL")
-	if mainDBURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL environment variable is not set")
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dir, err := os.MkdirTemp("", "event-analytics-test")
+		if err != nil {
+			return nil, err
+		}
+		dropTestDB = func() { os.RemoveAll(dir) }
+		dsn = "sqlite:" + filepath.Join(dir, "test.db")
 	}
 
-	// Create test database name
-	testDBName := "event_analytics_test"
-
-	// Connect to default postgres database to create/drop test database
-	defaultDB, err := gorm.Open(postgres.Open(mainDBURL), &gorm.Config{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to default database: %v", err)
+	config.Dialect = dialect.FromDSN(dsn)
+	if config.Dialect.Name() == dialect.Postgres {
+		if dsn, err = createPostgresTestDB(dsn); err != nil {
+			return nil, err
+		}
 	}
+	return config.Dialect.Open(dsn, &gorm.Config{})
+}
 
-	sqlDB, err := defaultDB.DB()
+// createPostgresTestDB recreates the test database on the server of dsn and
+// returns its DSN
+func createPostgresTestDB(dsn string) (string, error) {
+	defaultDB, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying SQL DB: %v", err)
+		return "", fmt.Errorf("failed to connect to default database: %v", err)
 	}
-	defer sqlDB.Close()
+	defer defaultDB.Close()
 
 	// Drop test database if it exists
-	sqlDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE);", testDBName))
+	defaultDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE);", testDBName))
 
-	// Create test database
-	_, err = sqlDB.Exec(fmt.Sprintf("CREATE DATABASE %s;", testDBName))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create test database: %v", err)
+	if _, err := defaultDB.Exec(fmt.Sprintf("CREATE DATABASE %s;", testDBName)); err != nil {
+		return "", fmt.Errorf("failed to create test database: %v", err)
 	}
 
-	// Create DSN for test database
-	testDBURL := getTestDatabaseURL(mainDBURL, testDBName)
+	dropTestDB = func() {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			log.Printf("Warning: failed to connect to default database for cleanup: %v", err)
+			return
+		}
+		defer db.Close()
+		db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE);", testDBName))
+	}
+	return getTestDatabaseURL(dsn, testDBName), nil
+}
 
-	// Connect to test database
-	testDB, err := gorm.Open(postgres.Open(testDBURL), &gorm.Config{})
+// runMigrations builds the schema from the SQL files the app uses
+func runMigrations(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to test database: %v", err)
+		return err
 	}
-
-	return testDB, nil
+	files, err := migrations.For(config.Dialect.Name())
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(sqlDB, config.Dialect, files)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(context.Background())
+	return err
 }
 
-// SetupTestRedis initializes the test Redis client
+// SetupTestRedis starts an in-process Redis server and returns a client
+// for it
 func SetupTestRedis() (*redis.Client, error) {
-	return redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_ADDR"),
-		Password: "",
-		DB:       0,
-	}), nil
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+	redisServer = server
+	return redis.NewClient(&redis.Options{Addr: server.Addr()}), nil
 }
 
 // TestMainHelper is a helper function that implements the common TestMain logic
 func TestMainHelper(m *testing.M) int {
 	var err error
-	
+
 	// Setup test database
 	TestDB, err = SetupTestDB()
 	if err != nil {
 		log.Fatalf("Failed to setup test database: %v", err)
 	}
+	testDB = TestDB
 
 	// Run migrations
-	err = TestDB.AutoMigrate(
-		&models.User{},
-		&models.Event{},
-		&models.VerificationToken{},
-		&models.PasswordReset{},
-		&models.Role{},
-		&models.UserRole{},
-		&models.PasswordHistory{},
-		&models.UserLog{},
-	)
+	err = runMigrations(TestDB)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
@@ -365,14 +247,25 @@ func TestMainHelper(m *testing.M) int {
 	// Set the global DB instance
 	config.DB = TestDB
 
-	// Setup Redis
+	// Setup Redis and the sessions kept in it
 	config.RedisClient, err = SetupTestRedis()
 	if err != nil {
 		log.Fatalf("Failed to setup test Redis: %v", err)
 	}
+	config.SessionStore = session.NewStore(config.RedisClient, time.Hour)
+	config.TwoFactorPending = session.NewPendingStore(config.RedisClient, 5*time.Minute, 5)
+	config.TwoFactorFailures = ratelimit.NewLimiter(config.RedisClient, "ratelimit:two-factor:", 10, 15*time.Minute)
+	config.VerificationResends = ratelimit.NewLimiter(config.RedisClient, "ratelimit:verification:", 3, time.Hour)
+	config.Mailer = Mailbox
+	utils.TemplateRoot = ".."
+
+	// Send queued email to the Mailbox as soon as it is committed
+	config.Outbox = outbox.NewDispatcher(TestDB, Mailbox, config.Settings.Mail.From(), outbox.Options{PollInterval: 10 * time.Millisecond})
+	config.Outbox.Start()
 
 	// Run tests
 	code := m.Run()
+	config.Outbox.Close()
 
 	// Cleanup
 	CleanupTests()
@@ -388,9 +281,12 @@ func CleanupTests() {
 			sqlDB.Close()
 		}
 	}
+	dropTestDB()
 
 	if config.RedisClient != nil {
-		config.RedisClient.FlushAll(context.Background())
 		config.RedisClient.Close()
 	}
-}
\ No newline at end of file
+	if redisServer != nil {
+		redisServer.Close()
+	}
+}
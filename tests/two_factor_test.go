@@ -0,0 +1,301 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"event-analytics/config"
+	"event-analytics/models"
+	"event-analytics/pkg/problem"
+	"event-analytics/pkg/session"
+	"event-analytics/pkg/totp"
+	"event-analytics/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedIn sends a form, or a GET without one, with the session cookie
+func signedIn(t *testing.T, session, method, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: session})
+	w := httptest.NewRecorder()
+	SetupTestRouter().ServeHTTP(w, req)
+	return w
+}
+
+// cookie returns the unescaped value of the named cookie a response sets
+func cookie(t *testing.T, w *httptest.ResponseRecorder, name string) string {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.MaxAge >= 0 {
+			value, err := url.QueryUnescape(c.Value)
+			require.NoError(t, err)
+			return value
+		}
+	}
+	return ""
+}
+
+func currentCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+// enroll turns two-factor authentication on for user through the profile
+// routes and returns the secret and the recovery codes
+func enroll(t *testing.T, user *models.User, session string) (string, []string) {
+	t.Helper()
+	w := signedIn(t, session, "POST", "/user/two-factor/enroll", nil)
+	require.Equal(t, "/user/two-factor", w.Header().Get("Location"))
+
+	w = signedIn(t, session, "GET", "/user/two-factor", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<svg", "the QR code is drawn inline")
+	var stored models.User
+	require.NoError(t, testDB.First(&stored, "id = ?", user.ID).Error)
+	require.NotEmpty(t, stored.TOTPSecret)
+	assert.Contains(t, w.Body.String(), stored.TOTPSecret)
+	assert.Nil(t, stored.TOTPEnabledAt, "not enabled before a code is confirmed")
+
+	w = signedIn(t, session, "POST", "/user/two-factor/confirm", url.Values{"code": {"000000"}})
+	require.Contains(t, w.Header().Get("Location"), "/user/two-factor?error=")
+
+	w = signedIn(t, session, "POST", "/user/two-factor/confirm", url.Values{"code": {currentCode(t, stored.TOTPSecret, -1)}})
+	require.Equal(t, "/user/profile", w.Header().Get("Location"))
+	codes := strings.Split(cookie(t, w, "recovery_codes"), ",")
+	require.Len(t, codes, services.RecoveryCodeCount)
+	return stored.TOTPSecret, codes
+}
+
+// login posts the password and returns the pending_2fa cookie
+func login(t *testing.T, user *models.User) string {
+	t.Helper()
+	w := postForm(t, "/auth/login", url.Values{"identifier": {user.Username}, "password": {"password123"}})
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Equal(t, "/auth/two-factor", w.Header().Get("Location"))
+	assert.Empty(t, cookie(t, w, "session_token"), "no session before the second step")
+	pending := cookie(t, w, "pending_2fa")
+	require.NotEmpty(t, pending)
+	return pending
+}
+
+func secondStep(pending, code string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/auth/two-factor", strings.NewReader(url.Values{"code": {code}}.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "pending_2fa", Value: pending})
+	w := httptest.NewRecorder()
+	SetupTestRouter().ServeHTTP(w, req)
+	return w
+}
+
+func TestTwoFactorEnrollmentAndLogin(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	require.NoError(t, testDB.Model(user).Update("is_verified", true).Error)
+	secret, codes := enroll(t, user, CreateTestSession(t, user))
+
+	var hashes []models.RecoveryCode
+	require.NoError(t, testDB.Where("user_id = ?", user.ID).Find(&hashes).Error)
+	require.Len(t, hashes, services.RecoveryCodeCount)
+	assert.Len(t, hashes[0].CodeHash, 64)
+	for _, h := range hashes {
+		assert.NotContains(t, codes, h.CodeHash, "only hashes are stored")
+	}
+
+	pending := login(t, user)
+	w := secondStep(pending, "000000")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "4 attempts left")
+
+	// The code that confirmed the enrollment can't be replayed
+	var stored models.User
+	require.NoError(t, testDB.First(&stored, "id = ?", user.ID).Error)
+	used, err := totp.Code(secret, stored.TOTPLastStep)
+	require.NoError(t, err)
+	w = secondStep(pending, used)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = secondStep(pending, currentCode(t, secret, 0))
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+	require.NotEmpty(t, cookie(t, w, "session_token"))
+
+	w = secondStep(pending, currentCode(t, secret, 1))
+	assert.Equal(t, "/auth/login?error=two_factor_expired", w.Header().Get("Location"), "a pending login works once")
+
+	// A recovery code stands in for the app, once
+	recovery := strings.ToUpper(codes[3])
+	w = secondStep(login(t, user), recovery)
+	assert.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+	w = secondStep(login(t, user), recovery)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	left, err := services.RecoveryCodesLeft(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(services.RecoveryCodeCount-1), left)
+}
+
+func TestTwoFactorLoginGivesUpAfterFiveCodes(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	require.NoError(t, testDB.Model(user).Update("is_verified", true).Error)
+	secret, _ := enroll(t, user, CreateTestSession(t, user))
+
+	pending := login(t, user)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, secondStep(pending, "000000").Code)
+	}
+	w := secondStep(pending, "000000")
+	assert.Equal(t, "/auth/login?error=two_factor_failed", w.Header().Get("Location"))
+
+	w = secondStep(pending, currentCode(t, secret, 0))
+	assert.Equal(t, "/auth/login?error=two_factor_expired", w.Header().Get("Location"))
+	assert.Empty(t, cookie(t, w, "session_token"))
+}
+
+func TestDisableAndRegenerateTwoFactor(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	session := CreateTestSession(t, user)
+	_, codes := enroll(t, user, session)
+
+	w := signedIn(t, session, "POST", "/user/two-factor/recovery-codes", url.Values{"code": {"000000"}})
+	assert.Contains(t, w.Header().Get("Location"), "error=")
+
+	w = signedIn(t, session, "POST", "/user/two-factor/recovery-codes", url.Values{"code": {codes[0]}})
+	require.Equal(t, "/user/profile", w.Header().Get("Location"))
+	fresh := strings.Split(cookie(t, w, "recovery_codes"), ",")
+	require.Len(t, fresh, services.RecoveryCodeCount)
+
+	w = signedIn(t, session, "POST", "/user/two-factor/disable", url.Values{"code": {codes[1]}})
+	assert.Contains(t, w.Header().Get("Location"), "error=", "the old codes were replaced")
+
+	w = signedIn(t, session, "POST", "/user/two-factor/disable", url.Values{"code": {fresh[0]}})
+	require.Equal(t, "/user/profile", w.Header().Get("Location"))
+	var stored models.User
+	require.NoError(t, testDB.First(&stored, "id = ?", user.ID).Error)
+	assert.Nil(t, stored.TOTPEnabledAt)
+	assert.Empty(t, stored.TOTPSecret)
+	var remaining int64
+	require.NoError(t, testDB.Model(&models.RecoveryCode{}).Where("user_id = ?", user.ID).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+}
+
+func TestAdminsCanRequireTwoFactor(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	admin := models.Role{Name: models.RoleAdmin}
+	require.NoError(t, testDB.Create(&admin).Error)
+	require.NoError(t, testDB.Create(&models.Role{Name: models.RoleModerator}).Error)
+	require.NoError(t, testDB.Create(&models.UserRole{UserID: user.ID, RoleID: admin.ID}).Error)
+	session := CreateTestSession(t, user)
+
+	w := signedIn(t, session, "GET", "/admin/two-factor", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="require_moderator"`)
+
+	w = signedIn(t, session, "POST", "/admin/two-factor", url.Values{"require_admin": {"on"}})
+	require.Equal(t, "/admin/two-factor", w.Header().Get("Location"))
+	required, err := services.RolesRequiringTwoFactor()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{models.RoleAdmin: true, models.RoleModerator: false}, required)
+
+	// The admin now has to enroll before doing anything else
+	for _, path := range []string{"/admin/two-factor", "/user/dashboard", "/events/new"} {
+		w = signedIn(t, session, "GET", path, nil)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/user/profile?error="), "%s: %s", path, w.Header().Get("Location"))
+	}
+	w = signedIn(t, session, "GET", "/user/profile", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, codes := enroll(t, user, session)
+	w = signedIn(t, session, "GET", "/admin/two-factor", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = signedIn(t, session, "POST", "/user/two-factor/disable", url.Values{"code": {codes[0]}})
+	assert.Contains(t, w.Header().Get("Location"), "error=", "a required second factor can't be turned off")
+	left, err := services.RecoveryCodesLeft(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(services.RecoveryCodeCount), left, "the code was not used up")
+}
+
+func TestTwoFactorRequiredForAPIAndStreams(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	admin := models.Role{Name: models.RoleAdmin}
+	require.NoError(t, testDB.Create(&admin).Error)
+	require.NoError(t, testDB.Create(&models.UserRole{UserID: user.ID, RoleID: admin.ID}).Error)
+	require.NoError(t, services.SetRoleRequiresTwoFactor(models.RoleAdmin, true))
+	session := CreateTestSession(t, user)
+
+	// The API and the live streams refuse with a problem document instead
+	// of redirecting to the profile page
+	for _, path := range []string{"/api/v1/events", "/sse?topic=dashboard", "/ws"} {
+		w := signedIn(t, session, "GET", path, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), path)
+		assert.Contains(t, w.Body.String(), "two-factor authentication", path)
+	}
+
+	enroll(t, user, session)
+	w := signedIn(t, session, "GET", "/api/v1/events", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTwoFactorLockoutSurvivesLogin(t *testing.T) {
+	ClearTestData(testDB)
+	t.Cleanup(func() { ClearTestData(testDB) })
+	user := CreateTestUser(t)
+	require.NoError(t, testDB.Model(user).Update("is_verified", true).Error)
+	secret, _ := enroll(t, user, CreateTestSession(t, user))
+
+	// A right code forgets the wrong ones before it
+	pending := login(t, user)
+	for i := 0; i < 4; i++ {
+		require.Equal(t, http.StatusUnauthorized, secondStep(pending, "000000").Code)
+	}
+	w := secondStep(pending, currentCode(t, secret, 0))
+	require.Equal(t, "/user/dashboard", w.Header().Get("Location"))
+
+	// Logging in again does not give a fresh set of guesses
+	for attempt := 0; attempt < 2; attempt++ {
+		pending = login(t, user)
+		for i := 0; i < 4; i++ {
+			require.Equal(t, http.StatusUnauthorized, secondStep(pending, "000000").Code)
+		}
+		w = secondStep(pending, "000000")
+		require.Equal(t, "/auth/login?error=two_factor_failed", w.Header().Get("Location"))
+	}
+
+	w = postForm(t, "/auth/login", url.Values{"identifier": {user.Username}, "password": {"password123"}})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Empty(t, cookie(t, w, "pending_2fa"))
+	assert.Empty(t, cookie(t, w, "session_token"))
+
+	// The lock lifts with the window, and a login that was pending when
+	// the limit ran out is ended without checking its code
+	redisServer.FastForward(15 * time.Minute)
+	pending = login(t, user)
+	for i := 0; i < 10; i++ {
+		_, err := config.TwoFactorFailures.Allow(context.Background(), user.ID.String())
+		require.NoError(t, err)
+	}
+	w = secondStep(pending, currentCode(t, secret, 0))
+	assert.Equal(t, "/auth/login?error=two_factor_locked", w.Header().Get("Location"))
+	assert.Empty(t, cookie(t, w, "session_token"))
+	_, err := config.TwoFactorPending.Get(context.Background(), pending)
+	assert.ErrorIs(t, err, session.ErrNotFound)
+}
@@ -10,9 +10,8 @@ import (
 
 	"fmt"
 	"net/http"
-	"os"
 
-	"gopkg.in/gomail.v2"
+	"event-analytics/pkg/outbox"
 
 	"context"
 	"errors"
@@ -20,6 +19,7 @@ import (
 	"event-analytics/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
 	"bytes"
 	"html/template"
@@ -53,27 +53,11 @@ func GenerateRandomToken() string {
     return generateRandomString(32, charset)
 }
 
-// SendEmailAsync sends an email asynchronously
-func SendEmailAsync(c *http.Request, to, subject, body string) {
-	go func() {
-		err := SendEmail(c, to, subject, body)
-		if err != nil {
-			fmt.Println("Failed to send email:", err)
-		}
-	}()
-}
-
-func SendEmail(c *http.Request, to, subject, body string) error {
-    m := gomail.NewMessage()
-    // Set From address with angle brackets
-    m.SetHeader("From", fmt.Sprintf("%s <%s>", os.Getenv("MAIL_SENDER"), os.Getenv("MAIL_USERNAME")))
-    m.SetHeader("To", to)
-    m.SetHeader("Subject", subject)
-    m.SetBody("text/html", body)
-
-    d := gomail.NewDialer(os.Getenv("MAIL_HOST"), 587, os.Getenv("MAIL_USERNAME"), os.Getenv("MAIL_PASSWORD"))
-
-    return d.DialAndSend(m)
+// QueueEmail writes an HTML email to the outbox through tx, the
+// transaction of the change the email is about. Call config.Outbox.Notify
+// once tx is committed to send it without waiting for the next poll.
+func QueueEmail(tx *gorm.DB, to, subject, body string) error {
+    return outbox.Enqueue(tx, to, subject, body)
 }
 
 func GetUserFromSession(c *gin.Context) (*models.User, error) {
@@ -83,9 +67,9 @@ func GetUserFromSession(c *gin.Context) (*models.User, error) {
         return nil, errors.New("session token not found")
     }
 
-    userID, err := config.RedisClient.Get(c, sessionToken).Result()
+    userID, err := config.SessionStore.Get(c, sessionToken)
     if err != nil {
-        log.Printf("GetUserFromSession: Failed to get user ID from Redis: %v", err)
+        log.Printf("GetUserFromSession: Failed to get user ID from the session store: %v", err)
         return nil, errors.New("invalid or expired session token")
     }
 
@@ -130,8 +114,13 @@ func ValidateSessionToken(c *gin.Context, token string) (string, error) {
     return userID, nil
 }
 
+// TemplateRoot is the directory email template paths are relative to, the
+// working directory unless tests run from elsewhere
+var TemplateRoot = "."
+
+// RenderTemplate renders an email template to a string, empty on failure
 func RenderTemplate(filePath string, data interface{}) string {
-	tmpl, err := template.ParseFiles(filePath)
+	tmpl, err := template.ParseFiles(filepath.Join(TemplateRoot, filePath))
 	if err != nil {
 		log.Printf("Failed to parse template: %v", err)
 		return ""
@@ -217,7 +206,7 @@ func UpdateUserProfile(userID string, updates map[string]interface{}) error {
 }
 
 func InitializeRoles() {
-	roles := []string{"admin", "user", "moderator"} // Define your roles here
+	roles := []string{models.RoleAdmin, models.RoleUser, models.RoleModerator}
 
 	for _, role := range roles {
 		var existingRole models.Role